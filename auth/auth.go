@@ -0,0 +1,80 @@
+/*
+Package auth provides the principal and the JWT verification of the auth middlewares
+
+	verifier := &auth.Verifier{
+		Keys:     auth.NewJWKSURL("https://login.example.com/.well-known/jwks.json", time.Hour),
+		Issuer:   "https://login.example.com/",
+		Audience: "api",
+	}
+	server.Use(middlewares.JWT(verifier))
+
+	server.Get("/me", func(req *core.Request, res *core.Response) {
+		user := auth.FromRequest(req)
+		res.JSON(user)
+	})
+*/
+package auth
+
+import (
+	"errors"
+	"slices"
+	"squirrel/core"
+)
+
+// LocalKey is the request local holding the principal, {{.user}} in templates
+const LocalKey = "user"
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	ID          string         `json:"id"`                    // user name, token subject
+	Method      string         `json:"method"`                // "basic", "bearer" or "jwt"
+	Roles       []string       `json:"roles,omitempty"`       // e.g. "admin"
+	Permissions []string       `json:"permissions,omitempty"` // e.g. "posts:delete"
+	Scopes      []string       `json:"scopes,omitempty"`      // e.g. "orders:read"
+	Claims      Claims         `json:"claims,omitempty"`      // claims of the jwt
+	Extra       map[string]any `json:"extra,omitempty"`       // anything the validate callbacks want to keep
+}
+
+// String returns the id, so principals work as keys (middlewares.RateLimitByUser)
+func (p *Principal) String() string {
+	return p.ID
+}
+
+// principal.HasRole(role)
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// principal.HasPermission(permission)
+func (p *Principal) HasPermission(permission string) bool {
+	return p != nil && slices.Contains(p.Permissions, permission)
+}
+
+// principal.HasScope(scope)
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+// auth.FromRequest(req)
+// returns the principal set by the auth middlewares, nil for anonymous requests
+func FromRequest(req *core.Request) *Principal {
+	p, _ := req.Get(LocalKey).(*Principal)
+	return p
+}
+
+// auth.SetPrincipal(req, p)
+// attaches the principal to the request, used by the auth middlewares
+func SetPrincipal(req *core.Request, p *Principal) {
+	req.Set(LocalKey, p)
+}
+
+var (
+	ErrMalformedToken   = errors.New("auth: malformed token")
+	ErrUnsupportedAlg   = errors.New("auth: unsupported algorithm")
+	ErrInvalidSignature = errors.New("auth: invalid signature")
+	ErrUnknownKey       = errors.New("auth: unknown key")
+	ErrTokenExpired     = errors.New("auth: token expired")
+	ErrTokenNotValidYet = errors.New("auth: token not valid yet")
+	ErrInvalidIssuer    = errors.New("auth: invalid issuer")
+	ErrInvalidAudience  = errors.New("auth: invalid audience")
+)
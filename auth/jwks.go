@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+	JSON Web Key Sets (RFC 7517)
+
+	- loaded from a local file or an url, e.g. /.well-known/jwks.json of the identity provider
+	- cached for ttl, a token with an unknown kid refreshes the set early (at most every 30s),
+	  so rotated keys work without a restart
+	- one fetch at a time, outside the lock: requests with a known key keep using the cached set,
+	  after failures the next fetch waits (1s doubling up to 5m) and the old keys are kept
+	- RSA, EC (P-256) and oct (hmac secrets) keys
+*/
+
+// JWKS is a KeySource backed by a JSON Web Key Set
+type JWKS struct {
+	load func() ([]byte, error)
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]jwk // by kid
+	fetched  time.Time
+	inflight chan struct{} // closed when the running fetch is done
+	failures int           // failed fetches in a row
+	retryAt  time.Time     // no fetch before, after failures
+	err      error         // of the last fetch
+}
+
+// a parsed key with the algorithm it may be used for
+type jwk struct {
+	alg string
+	key any
+}
+
+const (
+	// minimum time between two refreshes caused by unknown kids
+	jwksMinRefresh = 30 * time.Second
+	// wait after a failed fetch, doubled with every further failure
+	jwksMinBackoff = time.Second
+	jwksMaxBackoff = 5 * time.Minute
+)
+
+// auth.NewJWKSFile(path)
+// loads the key set from a file, it's read again when a token has an unknown kid
+func NewJWKSFile(path string) (*JWKS, error) {
+	j := &JWKS{load: func() ([]byte, error) { return os.ReadFile(path) }}
+	keys, err := j.fetch()
+	if err != nil {
+		return nil, err
+	}
+	j.keys, j.fetched = keys, time.Now()
+	return j, nil
+}
+
+// auth.NewJWKSURL(url, ttl)
+// fetches the key set from the url on first use and caches it for ttl (0 means an hour)
+func NewJWKSURL(url string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return &JWKS{
+		ttl: ttl,
+		load: func() ([]byte, error) {
+			resp, err := client.Get(url)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("auth: fetching %s: %s", url, resp.Status)
+			}
+			return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		},
+	}
+}
+
+func (j *JWKS) Key(alg, kid string) (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	k, ok := j.lookup(kid)
+	stale := j.keys == nil || (j.ttl > 0 && time.Since(j.fetched) > j.ttl)
+	if stale || (!ok && time.Since(j.fetched) > jwksMinRefresh) {
+		if err := j.refresh(ok); err != nil && j.keys == nil {
+			return nil, err
+		}
+		// on errors the old keys are kept
+		k, ok = j.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("%w: key %q is for %s", ErrUnsupportedAlg, kid, k.alg)
+	}
+	return k.key, nil
+}
+
+// tokens without kid are accepted when the set has a single key
+func (j *JWKS) lookup(kid string) (jwk, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k, true
+		}
+	}
+	k, ok := j.keys[kid]
+	return k, ok
+}
+
+// reads the key set again, the caller holds the lock
+// the lock is released during the fetch, callers arriving meanwhile wait for it,
+// unless they already have their key (cached) and don't need to
+func (j *JWKS) refresh(cached bool) error {
+	if ch := j.inflight; ch != nil {
+		if cached {
+			return nil
+		}
+		j.mu.Unlock()
+		<-ch
+		j.mu.Lock()
+		return j.err
+	}
+	if time.Now().Before(j.retryAt) {
+		return j.err
+	}
+
+	ch := make(chan struct{})
+	j.inflight = ch
+	j.mu.Unlock()
+	keys, err := j.fetch()
+	j.mu.Lock()
+	j.inflight = nil
+	close(ch)
+
+	j.err = err
+	if err != nil {
+		j.failures++
+		backoff := min(jwksMinBackoff<<min(j.failures-1, 20), jwksMaxBackoff)
+		j.retryAt = time.Now().Add(backoff)
+		return err
+	}
+	j.keys, j.fetched = keys, time.Now()
+	j.failures, j.retryAt = 0, time.Time{}
+	return nil
+}
+
+func (j *JWKS) fetch() (map[string]jwk, error) {
+	data, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// keys that can't be used (encryption keys, other curves) are skipped
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: parsing jwks: %w", err)
+	}
+
+	keys := map[string]jwk{}
+	var errs []error
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		k, err := raw.parse()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("auth: jwks key %q: %w", raw.Kid, err))
+			continue
+		}
+		if k.key != nil {
+			keys[raw.Kid] = k
+		}
+	}
+	if len(keys) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return keys, nil
+}
+
+func (raw rawJWK) parse() (jwk, error) {
+	b64 := base64.RawURLEncoding
+
+	switch raw.Kty {
+	case "RSA":
+		n, err1 := b64.DecodeString(raw.N)
+		e, err2 := b64.DecodeString(raw.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return jwk{}, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return jwk{}, errors.New("invalid rsa exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+		if key.N.BitLen() < 2048 {
+			return jwk{}, errors.New("rsa key shorter than 2048 bits")
+		}
+		return jwk{alg: raw.Alg, key: key}, nil
+
+	case "EC":
+		if raw.Crv != "P-256" {
+			return jwk{}, nil
+		}
+		x, err1 := b64.DecodeString(raw.X)
+		y, err2 := b64.DecodeString(raw.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return jwk{}, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return jwk{}, errors.New("invalid P-256 point")
+		}
+		// ecdh checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return jwk{}, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return jwk{alg: raw.Alg, key: key}, nil
+
+	case "oct":
+		secret, err := b64.DecodeString(raw.K)
+		if err != nil {
+			return jwk{}, err
+		}
+		return jwk{alg: raw.Alg, key: secret}, nil
+	}
+	return jwk{}, nil
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// key set of hmac secrets, kid => secret
+func octJWKS(secrets map[string]string) []byte {
+	var keys []string
+	for kid, secret := range secrets {
+		k := base64.RawURLEncoding.EncodeToString([]byte(secret))
+		keys = append(keys, fmt.Sprintf(`{"kty":"oct","kid":%q,"alg":"HS256","k":%q}`, kid, k))
+	}
+	return []byte(`{"keys":[` + strings.Join(keys, ",") + `]}`)
+}
+
+// a JWKS loading whatever set is current, counting the loads
+type testSource struct {
+	mu    sync.Mutex
+	set   []byte
+	err   error
+	loads atomic.Int32
+}
+
+func (s *testSource) jwks() *JWKS {
+	return &JWKS{ttl: time.Hour, load: func() ([]byte, error) {
+		s.loads.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.set, s.err
+	}}
+}
+
+func TestJWKSKid(t *testing.T) {
+	tests := []struct {
+		name string
+		set  map[string]string
+		alg  string
+		kid  string
+		err  error
+	}{
+		{"known kid", map[string]string{"a": "secret a", "b": "secret b"}, "HS256", "b", nil},
+		{"unknown kid", map[string]string{"a": "secret a"}, "HS256", "c", ErrUnknownKey},
+		{"no kid, single key", map[string]string{"a": "secret a"}, "HS256", "", nil},
+		{"no kid, several keys", map[string]string{"a": "secret a", "b": "secret b"}, "HS256", "", ErrUnknownKey},
+		{"alg of the key differs", map[string]string{"a": "secret a"}, "RS256", "a", ErrUnsupportedAlg},
+	}
+	for _, tt := range tests {
+		src := &testSource{set: octJWKS(tt.set)}
+		key, err := src.jwks().Key(tt.alg, tt.kid)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+		if err == nil && tt.kid != "" && string(key.([]byte)) != tt.set[tt.kid] {
+			t.Errorf("%s: got the key %q", tt.name, key)
+		}
+	}
+}
+
+func TestJWKSVerify(t *testing.T) {
+	src := &testSource{set: octJWKS(map[string]string{"a": "secret a", "b": "secret b"})}
+	v := Verifier{Keys: src.jwks()}
+
+	if _, err := v.Verify(mustSign(t, Claims{"sub": "42"}, "HS256", "b", []byte("secret b"))); err != nil {
+		t.Errorf("token of key b: %v", err)
+	}
+	// signed with a, claiming to be b
+	if _, err := v.Verify(mustSign(t, Claims{"sub": "42"}, "HS256", "b", []byte("secret a"))); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong kid: err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	src := &testSource{set: octJWKS(map[string]string{"a": "secret a"})}
+	j := src.jwks()
+
+	if _, err := j.Key("HS256", "a"); err != nil {
+		t.Fatal(err)
+	}
+	// the identity provider rotates in key b
+	src.mu.Lock()
+	src.set = octJWKS(map[string]string{"a": "secret a", "b": "secret b"})
+	src.mu.Unlock()
+
+	// within jwksMinRefresh unknown kids don't refetch
+	if _, err := j.Key("HS256", "b"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey right after a fetch", err)
+	}
+	if n := src.loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+
+	j.mu.Lock()
+	j.fetched = time.Now().Add(-jwksMinRefresh - time.Second)
+	j.mu.Unlock()
+	if _, err := j.Key("HS256", "b"); err != nil {
+		t.Errorf("rotated key: %v", err)
+	}
+	if n := src.loads.Load(); n != 2 {
+		t.Errorf("loads = %d, want 2", n)
+	}
+}
+
+func TestJWKSBackoff(t *testing.T) {
+	src := &testSource{err: errors.New("connection refused")}
+	j := src.jwks()
+
+	for i := 0; i < 5; i++ {
+		if _, err := j.Key("HS256", "a"); err == nil {
+			t.Fatal("no error without keys")
+		}
+	}
+	if n := src.loads.Load(); n != 1 {
+		t.Errorf("loads during backoff = %d, want 1", n)
+	}
+
+	// after the backoff the next request fetches again
+	src.mu.Lock()
+	src.set, src.err = octJWKS(map[string]string{"a": "secret a"}), nil
+	src.mu.Unlock()
+	j.mu.Lock()
+	j.retryAt = time.Now().Add(-time.Second)
+	j.mu.Unlock()
+	if _, err := j.Key("HS256", "a"); err != nil {
+		t.Errorf("after backoff: %v", err)
+	}
+
+	// a failing refresh keeps the old keys
+	src.mu.Lock()
+	src.err = errors.New("connection refused")
+	src.mu.Unlock()
+	j.mu.Lock()
+	j.fetched = time.Now().Add(-2 * time.Hour)
+	j.mu.Unlock()
+	if _, err := j.Key("HS256", "a"); err != nil {
+		t.Errorf("stale keys after a failed refresh: %v", err)
+	}
+}
+
+func TestJWKSSingleFetch(t *testing.T) {
+	release := make(chan struct{})
+	var loads atomic.Int32
+	j := &JWKS{ttl: time.Hour, load: func() ([]byte, error) {
+		loads.Add(1)
+		<-release
+		return octJWKS(map[string]string{"a": "secret a"}), nil
+	}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := j.Key("HS256", "a")
+			errs <- err
+		}()
+	}
+
+	// the lock is free while the fetch is running
+	deadline := time.Now().Add(time.Second)
+	for loads.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	locked := make(chan struct{})
+	go func() {
+		j.mu.Lock()
+		j.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Error("lock held during the fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("waiting caller: %v", err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+	JSON Web Tokens (RFC 7519)
+
+	- HS256 (shared secret), RS256 (rsa key) and ES256 (P-256 key)
+	- the algorithm must fit the type of the key, a token can't pick "none" or turn
+	  a public rsa key into a hmac secret
+	- exp and nbf are checked when present, iss and aud when the verifier asks for them
+*/
+
+// Claims are the claims of a token
+type Claims map[string]any
+
+// claims.Subject()
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// claims.Strings(name)
+// returns a claim holding a string list ("roles": ["a", "b"]) or a space separated string ("scope": "a b")
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// time of a NumericDate claim (exp, nbf, iat)
+func (c Claims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrMalformedToken, name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrMalformedToken, name)
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true, nil
+}
+
+// KeySource returns the key verifying a token, by its alg and kid header
+type KeySource interface {
+	Key(alg, kid string) (any, error)
+}
+
+type staticKey struct{ key any }
+
+func (s staticKey) Key(alg, kid string) (any, error) { return s.key, nil }
+
+// auth.SecretKey(secret)
+// verifies HS256 tokens with a shared secret
+func SecretKey(secret []byte) KeySource {
+	return staticKey{secret}
+}
+
+// auth.PublicKey(key)
+// verifies RS256 tokens with an *rsa.PublicKey or ES256 tokens with an *ecdsa.PublicKey
+func PublicKey(key crypto.PublicKey) KeySource {
+	return staticKey{key}
+}
+
+// Verifier checks the signature and claims of tokens
+type Verifier struct {
+	// keys verifying the signature: SecretKey, PublicKey or a JWKS
+	Keys KeySource
+	// allowed algorithms, all supported ones by default
+	Algorithms []string
+	// required "iss" claim, not checked when empty
+	Issuer string
+	// required entry of the "aud" claim, not checked when empty
+	Audience string
+	// clock skew allowed for exp and nbf
+	Leeway time.Duration
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// verifier.Verify(token)
+// returns the claims of a valid token
+// errors wrap one of the Err* values of this package
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	if !supportedAlg(h.Alg) || (len(v.Algorithms) > 0 && !slices.Contains(v.Algorithms, h.Alg)) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlg, h.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	key, err := v.Keys.Key(h.Alg, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) checkClaims(claims Claims) error {
+	now := time.Now()
+
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return err
+	}
+	if ok && !now.Before(exp.Add(v.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(v.Leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+	if v.Audience != "" && !slices.Contains(claims.Strings("aud"), v.Audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// auth.Sign(claims, alg, kid, key)
+// creates a token, key is a []byte secret (HS256), *rsa.PrivateKey (RS256) or *ecdsa.PrivateKey (ES256)
+// kid may be empty
+//
+//	token, err := auth.Sign(auth.Claims{"sub": "42", "exp": time.Now().Add(time.Hour).Unix()}, "HS256", "", secret)
+func Sign(claims Claims, alg, kid string, key any) (string, error) {
+	h, err := json.Marshal(header{Alg: alg, Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != "HS256" {
+			return "", fmt.Errorf("%w: %s with a secret", ErrUnsupportedAlg, alg)
+		}
+		sig = hmacSHA256(k, []byte(signed))
+	case *rsa.PrivateKey:
+		if alg != "RS256" {
+			return "", fmt.Errorf("%w: %s with an rsa key", ErrUnsupportedAlg, alg)
+		}
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		if alg != "ES256" {
+			return "", fmt.Errorf("%w: %s with an ecdsa key", ErrUnsupportedAlg, alg)
+		}
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			// fixed size r || s, not ASN.1
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	default:
+		return "", fmt.Errorf("%w: key of type %T", ErrUnsupportedAlg, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func supportedAlg(alg string) bool {
+	return alg == "HS256" || alg == "RS256" || alg == "ES256"
+}
+
+func verifySignature(alg string, key any, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+
+	switch k := key.(type) {
+	case []byte:
+		if alg == "HS256" && hmac.Equal(sig, hmacSHA256(k, signed)) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == "ES256" && len(sig) == 64 && k.Curve.Params().BitSize == 256 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(k, digest[:], r, s) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("%w: key of type %T", ErrUnsupportedAlg, key)
+	}
+	return ErrInvalidSignature
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return nil
+}
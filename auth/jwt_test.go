@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func mustSign(t *testing.T, claims Claims, alg, kid string, key any) string {
+	t.Helper()
+	token, err := Sign(claims, alg, kid, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// a token with any header, signed with hmac over whatever key bytes are given
+func forgeHS256(t *testing.T, h map[string]any, claims Claims, key []byte) string {
+	t.Helper()
+	hj, _ := json.Marshal(h)
+	cj, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(hj) + "." + base64.RawURLEncoding.EncodeToString(cj)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(key, []byte(signed)))
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	claims := Claims{"sub": "42"}
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42"}`)) + "."
+
+	tests := []struct {
+		name     string
+		verifier Verifier
+		token    string
+		err      error
+	}{
+		{"hs256", Verifier{Keys: SecretKey(testSecret)}, mustSign(t, claims, "HS256", "", testSecret), nil},
+		{"rs256", Verifier{Keys: PublicKey(&rsaKey.PublicKey)}, mustSign(t, claims, "RS256", "", rsaKey), nil},
+		{"es256", Verifier{Keys: PublicKey(&ecKey.PublicKey)}, mustSign(t, claims, "ES256", "", ecKey), nil},
+
+		{"alg none", Verifier{Keys: SecretKey(testSecret)}, unsigned, ErrUnsupportedAlg},
+		{"unknown alg", Verifier{Keys: SecretKey(testSecret)}, forgeHS256(t, map[string]any{"alg": "HS512"}, claims, testSecret), ErrUnsupportedAlg},
+		{"alg not allowed", Verifier{Keys: SecretKey(testSecret), Algorithms: []string{"RS256"}}, mustSign(t, claims, "HS256", "", testSecret), ErrUnsupportedAlg},
+
+		// the public key used as hmac secret must not verify
+		{"hs256 with the rsa public key", Verifier{Keys: PublicKey(&rsaKey.PublicKey)}, forgeHS256(t, map[string]any{"alg": "HS256"}, claims, rsaDER), ErrInvalidSignature},
+		{"rs256 signature checked as es256", Verifier{Keys: PublicKey(&ecKey.PublicKey)}, mustSign(t, claims, "RS256", "", rsaKey), ErrInvalidSignature},
+		{"hs256 token for an rsa verifier", Verifier{Keys: PublicKey(&rsaKey.PublicKey)}, mustSign(t, claims, "HS256", "", testSecret), ErrInvalidSignature},
+		{"wrong secret", Verifier{Keys: SecretKey([]byte("another secret of 32 bytes......"))}, mustSign(t, claims, "HS256", "", testSecret), ErrInvalidSignature},
+
+		{"two segments", Verifier{Keys: SecretKey(testSecret)}, "a.b", ErrMalformedToken},
+		{"bad base64", Verifier{Keys: SecretKey(testSecret)}, "!!.!!.!!", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		_, err := tt.verifier.Verify(tt.token)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestVerifyTamperedClaims(t *testing.T) {
+	v := Verifier{Keys: SecretKey(testSecret)}
+	token := mustSign(t, Claims{"sub": "42", "roles": []string{"user"}}, "HS256", "", testSecret)
+	parts := strings.Split(token, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42","roles":["admin"]}`))
+
+	if _, err := v.Verify(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	tests := []struct {
+		name     string
+		verifier Verifier
+		claims   Claims
+		err      error
+	}{
+		{"no exp", Verifier{}, Claims{"sub": "42"}, nil},
+		{"valid", Verifier{}, Claims{"exp": at(time.Hour), "nbf": at(-time.Minute)}, nil},
+		{"expired", Verifier{}, Claims{"exp": at(-time.Minute)}, ErrTokenExpired},
+		{"expired within leeway", Verifier{Leeway: 2 * time.Minute}, Claims{"exp": at(-time.Minute)}, nil},
+		{"expired beyond leeway", Verifier{Leeway: 30 * time.Second}, Claims{"exp": at(-time.Minute)}, ErrTokenExpired},
+		{"not valid yet", Verifier{}, Claims{"nbf": at(time.Hour)}, ErrTokenNotValidYet},
+		{"not valid yet within leeway", Verifier{Leeway: 2 * time.Minute}, Claims{"nbf": at(time.Minute)}, nil},
+		{"exp not a number", Verifier{}, Claims{"exp": "tomorrow"}, ErrMalformedToken},
+
+		{"issuer", Verifier{Issuer: "https://id.example.com"}, Claims{"iss": "https://id.example.com"}, nil},
+		{"wrong issuer", Verifier{Issuer: "https://id.example.com"}, Claims{"iss": "https://evil.example.com"}, ErrInvalidIssuer},
+		{"missing issuer", Verifier{Issuer: "https://id.example.com"}, Claims{}, ErrInvalidIssuer},
+		{"audience string", Verifier{Audience: "api"}, Claims{"aud": "api"}, nil},
+		{"audience list", Verifier{Audience: "api"}, Claims{"aud": []string{"web", "api"}}, nil},
+		{"wrong audience", Verifier{Audience: "api"}, Claims{"aud": []string{"web"}}, ErrInvalidAudience},
+	}
+	for _, tt := range tests {
+		tt.verifier.Keys = SecretKey(testSecret)
+		_, err := tt.verifier.Verify(mustSign(t, tt.claims, "HS256", "", testSecret))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
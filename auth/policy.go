@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"squirrel/core"
+	"strings"
+	"time"
+)
+
+/*
+	Authorization policies
+
+	- a Policy decides whether the principal may make the request
+	- Roles, Permissions and Scopes check the principal, Owner and custom policies
+	  can look at the request as well (params, headers...)
+	- policies are attached to routes with server.Require(...) or group.Require(...)
+
+	server.Delete("/posts/:id", deletePost, server.Require(auth.Any(auth.Roles("admin"), ownsPost)))
+*/
+
+// ErrForbidden is returned by policies denying a request
+var ErrForbidden = errors.New("auth: forbidden")
+
+// Policy decides about authenticated requests
+// nil allows the request, errors wrapping ErrForbidden deny it with 403
+// a core.HTTPError is answered as is, e.g. 404 to hide that a resource exists
+// any other error means the policy couldn't decide (database down...) and is a 500
+type Policy interface {
+	Authorize(req *core.Request, p *Principal) error
+}
+
+// PolicyFunc turns a function into a Policy
+type PolicyFunc func(req *core.Request, p *Principal) error
+
+func (f PolicyFunc) Authorize(req *core.Request, p *Principal) error {
+	return f(req, p)
+}
+
+// auth.Roles(roles...)
+// requires every one of the roles
+func Roles(roles ...string) Policy {
+	return requireAll("role", roles, (*Principal).HasRole)
+}
+
+// auth.AnyRole(roles...)
+// requires at least one of the roles
+func AnyRole(roles ...string) Policy {
+	return PolicyFunc(func(req *core.Request, p *Principal) error {
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: needs one of the roles %s", ErrForbidden, strings.Join(roles, ", "))
+	})
+}
+
+// auth.Permissions(permissions...)
+// requires every one of the permissions, e.g. "posts:delete"
+func Permissions(permissions ...string) Policy {
+	return requireAll("permission", permissions, (*Principal).HasPermission)
+}
+
+// auth.Scopes(scopes...)
+// requires every one of the oauth scopes of the token, e.g. "orders:read"
+func Scopes(scopes ...string) Policy {
+	return requireAll("scope", scopes, (*Principal).HasScope)
+}
+
+// auth.Owner(param, owner)
+// allows principals owning the resource of the route parameter
+// owner looks up the id of the owner, e.g. in the database
+// its errors are returned as they are: a 500 unless it's a core.HTTPError or wraps ErrForbidden
+//
+//	auth.Owner("id", func(id string) (string, error) {
+//		post, err := db.FindPost(id)
+//		if errors.Is(err, db.ErrNotFound) {
+//			return "", core.NewHTTPError(404, "Post Not Found")
+//		}
+//		return post.AuthorID, err
+//	})
+func Owner(param string, owner func(id string) (string, error)) Policy {
+	return PolicyFunc(func(req *core.Request, p *Principal) error {
+		id, err := owner(req.Param(param))
+		if err != nil {
+			return err
+		}
+		if id == "" || id != p.ID {
+			return fmt.Errorf("%w: not the owner of %s %q", ErrForbidden, param, req.Param(param))
+		}
+		return nil
+	})
+}
+
+// auth.All(policies...)
+// allows requests allowed by every policy
+func All(policies ...Policy) Policy {
+	return PolicyFunc(func(req *core.Request, p *Principal) error {
+		for _, policy := range policies {
+			if err := policy.Authorize(req, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// auth.Any(policies...)
+// allows requests allowed by at least one policy
+// otherwise returns the first error that isn't a denial (the request couldn't be decided),
+// then the first core.HTTPError (e.g. 404 of an Owner lookup), or else the first denial
+func Any(policies ...Policy) Policy {
+	return PolicyFunc(func(req *core.Request, p *Principal) error {
+		var failed, httpErr, denied error
+		for _, policy := range policies {
+			err := policy.Authorize(req, p)
+			if err == nil {
+				return nil
+			}
+			var he *core.HTTPError
+			switch {
+			case errors.As(err, &he):
+				if httpErr == nil {
+					httpErr = err
+				}
+			case errors.Is(err, ErrForbidden):
+				if denied == nil {
+					denied = err
+				}
+			default:
+				if failed == nil {
+					failed = err
+				}
+			}
+		}
+		switch {
+		case failed != nil:
+			return failed
+		case httpErr != nil:
+			return httpErr
+		case denied != nil:
+			return denied
+		}
+		return ErrForbidden
+	})
+}
+
+func requireAll(kind string, values []string, has func(*Principal, string) bool) Policy {
+	return PolicyFunc(func(req *core.Request, p *Principal) error {
+		for _, v := range values {
+			if !has(p, v) {
+				return fmt.Errorf("%w: missing %s %q", ErrForbidden, kind, v)
+			}
+		}
+		return nil
+	})
+}
+
+// Denial describes a denied request for audit logs
+type Denial struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	ClientIP  string
+	Principal string // id of the principal, empty for anonymous requests
+	Status    int    // 401 or 403 (or the 4xx code of a core.HTTPError returned by a policy)
+	Reason    error
+}
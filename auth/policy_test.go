@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"squirrel/core"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	posts := map[string]string{"1": "ada", "2": "grace"}
+	notFound := core.NewHTTPError(404, "Not Found")
+	dbDown := errors.New("db: connection refused")
+	ownsPost := Owner("id", func(id string) (string, error) {
+		if id == "down" {
+			return "", dbDown
+		}
+		author, ok := posts[id]
+		if !ok {
+			return "", notFound
+		}
+		return author, nil
+	})
+
+	ada := &Principal{ID: "ada", Roles: []string{"editor"}, Permissions: []string{"posts:edit"}, Scopes: []string{"posts:read"}}
+	admin := &Principal{ID: "root", Roles: []string{"admin", "editor"}}
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		principal *Principal
+		post      string
+		want      error // nil allows, ErrForbidden or notFound deny, dbDown can't decide
+	}{
+		{"roles all present", Roles("admin", "editor"), admin, "", nil},
+		{"roles one missing", Roles("admin", "editor"), ada, "", ErrForbidden},
+		{"any role", AnyRole("admin", "editor"), ada, "", nil},
+		{"any role none", AnyRole("admin"), ada, "", ErrForbidden},
+		{"permissions", Permissions("posts:edit"), ada, "", nil},
+		{"permissions missing", Permissions("posts:delete"), ada, "", ErrForbidden},
+		{"scopes", Scopes("posts:read"), ada, "", nil},
+		{"scopes missing", Scopes("posts:write"), admin, "", ErrForbidden},
+		{"owner", ownsPost, ada, "1", nil},
+		{"not the owner", ownsPost, ada, "2", ErrForbidden},
+		{"owner lookup error", ownsPost, ada, "404", notFound},
+		{"all allow", All(Roles("editor"), ownsPost), ada, "1", nil},
+		{"all first denial", All(ownsPost, Roles("admin")), ada, "2", ErrForbidden},
+		{"all empty", All(), ada, "", nil},
+		{"any first allows", Any(Roles("admin"), ownsPost), admin, "2", nil},
+		{"any second allows", Any(Roles("admin"), ownsPost), ada, "1", nil},
+		{"any denies", Any(Roles("admin"), ownsPost), ada, "2", ErrForbidden},
+		// the 404 of the lookup wins, so a denied request doesn't reveal that the post exists
+		{"any prefers http errors", Any(Roles("admin"), ownsPost), ada, "404", notFound},
+		{"any empty", Any(), ada, "", ErrForbidden},
+		// a failed lookup isn't a denial, it's reported even if another policy said 404
+		{"owner lookup fails", ownsPost, ada, "down", dbDown},
+		{"any prefers failures", Any(Owner("id", func(string) (string, error) { return "", notFound }), ownsPost), ada, "down", dbDown},
+	}
+	for _, tt := range tests {
+		req := &core.Request{Params: map[string]string{"id": tt.post}}
+		err := tt.policy.Authorize(req, tt.principal)
+		if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+			t.Errorf("%s: Authorize = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
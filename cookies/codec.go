@@ -0,0 +1,228 @@
+package cookies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Signed and encrypted cookie values
+
+Cookies are stored by the client, so it can read and change them at will.
+A Codec protects the values before they are sent:
+
+	- signed:    value + HMAC-SHA256, the client can read but not change the value
+	- encrypted: AES-GCM, the client can neither read nor change the value
+
+Every value carries the time it was encoded, so old values can be rejected (MaxAge)
+and the name of the cookie is part of the signature, so a value can't be moved to another cookie.
+*/
+
+var (
+	// ErrNoCookie is returned when the request has no cookie with the name
+	ErrNoCookie = errors.New("cookies: no such cookie")
+	// ErrInvalidValue is returned for values that were changed, are malformed or
+	// were encoded with a key that is no longer in the keyring
+	ErrInvalidValue = errors.New("cookies: invalid value")
+	// ErrExpiredValue is returned for values older than the MaxAge of the codec
+	ErrExpiredValue = errors.New("cookies: expired value")
+	// ErrValueTooLong is returned when the encoded value doesn't fit into a cookie
+	ErrValueTooLong = errors.New("cookies: value too long")
+)
+
+// browsers limit a cookie to about 4KB
+const maxValueLength = 4096
+
+// Keyring holds the keys of a codec
+// the first key (the primary) encodes, all keys decode
+// so a new key can be rotated in without invalidating the cookies of the old one
+type Keyring struct {
+	mu   sync.RWMutex
+	keys [][]byte
+}
+
+// NewKeyring
+// creates a keyring, the first key is the primary
+func NewKeyring(keys ...[]byte) *Keyring {
+	if len(keys) == 0 {
+		panic("cookies: a keyring needs at least one key")
+	}
+	return &Keyring{keys: keys}
+}
+
+// keyring.Rotate(key)
+// makes the key the primary, the old keys still decode
+func (k *Keyring) Rotate(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append([][]byte{key}, k.keys...)
+}
+
+// keyring.Retire(key)
+// removes the key, values encoded with it are invalid from now on
+// the primary key can't be retired
+func (k *Keyring) Retire(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// a new slice, codecs may still be iterating over the old one
+	keys := [][]byte{k.keys[0]}
+	for _, existing := range k.keys[1:] {
+		if !hmac.Equal(existing, key) {
+			keys = append(keys, existing)
+		}
+	}
+	k.keys = keys
+}
+
+func (k *Keyring) all() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys
+}
+
+// Codec signs or encrypts cookie values
+type Codec struct {
+	Keys *Keyring
+	// values older than this are rejected, 0 means no limit
+	MaxAge time.Duration
+	// encrypt the values instead of only signing them
+	Encrypt bool
+}
+
+// NewSignedCodec
+// creates a codec signing the values with HMAC-SHA256
+// keys should be at least 32 random bytes
+func NewSignedCodec(keys ...[]byte) *Codec {
+	return &Codec{Keys: NewKeyring(keys...)}
+}
+
+// NewEncryptedCodec
+// creates a codec encrypting the values with AES-256-GCM
+// the aes keys are derived from the keys, which can have any length
+// but should be at least 32 random bytes
+func NewEncryptedCodec(keys ...[]byte) *Codec {
+	return &Codec{Keys: NewKeyring(keys...), Encrypt: true}
+}
+
+// codec.Encode(name, value)
+// returns the protected value for the cookie with the name
+func (c *Codec) Encode(name, value string) (string, error) {
+	key := c.Keys.all()[0]
+
+	// 8 bytes unix timestamp followed by the value
+	plain := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plain, uint64(time.Now().Unix()))
+	plain = append(plain, value...)
+
+	var encoded string
+	if c.Encrypt {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		encoded = base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, []byte(name)))
+	} else {
+		payload := base64.RawURLEncoding.EncodeToString(plain)
+		encoded = payload + "." + base64.RawURLEncoding.EncodeToString(mac(key, name, payload))
+	}
+
+	if len(encoded) > maxValueLength {
+		return "", ErrValueTooLong
+	}
+	return encoded, nil
+}
+
+// codec.Decode(name, encoded)
+// returns the original value if it is authentic and not expired
+func (c *Codec) Decode(name, encoded string) (string, error) {
+	var plain []byte
+	if c.Encrypt {
+		plain = c.decrypt(name, encoded)
+	} else {
+		plain = c.verify(name, encoded)
+	}
+	if len(plain) < 8 {
+		return "", ErrInvalidValue
+	}
+
+	created := time.Unix(int64(binary.BigEndian.Uint64(plain[:8])), 0)
+	if c.MaxAge > 0 && time.Since(created) > c.MaxAge {
+		return "", ErrExpiredValue
+	}
+	return string(plain[8:]), nil
+}
+
+// returns the payload if any key signed it
+func (c *Codec) verify(name, encoded string) []byte {
+	payload, sig, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return nil
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil
+	}
+
+	for _, key := range c.Keys.all() {
+		if hmac.Equal(got, mac(key, name, payload)) {
+			plain, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return nil
+			}
+			return plain
+		}
+	}
+	return nil
+}
+
+// returns the plain text if any key decrypts it
+func (c *Codec) decrypt(name, encoded string) []byte {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+
+	for _, key := range c.Keys.all() {
+		aead, err := newAEAD(key)
+		if err != nil || len(data) < aead.NonceSize() {
+			return nil
+		}
+		nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if plain, err := aead.Open(nil, nonce, sealed, []byte(name)); err == nil {
+			return plain
+		}
+	}
+	return nil
+}
+
+func mac(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// the aes key is derived from the key, so the same secret can sign and encrypt
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("squirrel cookie encryption"))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,155 @@
+package cookies
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testKey    = []byte("0123456789abcdef0123456789abcdef")
+	testOldKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+// signs the value as if it was encoded at the given time
+func signedAt(key []byte, name, value string, at time.Time) string {
+	plain := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plain, uint64(at.Unix()))
+	plain = append(plain, value...)
+	payload := base64.RawURLEncoding.EncodeToString(plain)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac(key, name, payload))
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []*Codec{NewSignedCodec(testKey), NewEncryptedCodec(testKey)} {
+		encoded, err := codec.Encode("session", "alice")
+		if err != nil {
+			t.Fatalf("encrypt=%v: encode: %v", codec.Encrypt, err)
+		}
+		if codec.Encrypt && strings.Contains(encoded, base64.RawURLEncoding.EncodeToString([]byte("alice"))) {
+			t.Errorf("encrypted value shows the plain text: %s", encoded)
+		}
+		value, err := codec.Decode("session", encoded)
+		if err != nil || value != "alice" {
+			t.Errorf("encrypt=%v: decode = %q, %v, want alice", codec.Encrypt, value, err)
+		}
+	}
+}
+
+func TestCodecTamper(t *testing.T) {
+	for _, codec := range []*Codec{NewSignedCodec(testKey), NewEncryptedCodec(testKey)} {
+		encoded, err := codec.Encode("session", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		flipped := []byte(encoded)
+		flipped[len(flipped)/2] ^= 1
+
+		tests := []struct {
+			name    string
+			cookie  string
+			encoded string
+		}{
+			{"changed byte", "session", string(flipped)},
+			{"other cookie name", "admin", encoded},
+			{"truncated", "session", encoded[:len(encoded)-4]},
+			{"empty", "session", ""},
+			{"garbage", "session", "not.base64!"},
+			{"other key", "session", mustEncode(t, &Codec{Keys: NewKeyring(testOldKey), Encrypt: codec.Encrypt}, "session", "alice")},
+		}
+		for _, tt := range tests {
+			if value, err := codec.Decode(tt.cookie, tt.encoded); !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("encrypt=%v %s: decode = %q, %v, want ErrInvalidValue", codec.Encrypt, tt.name, value, err)
+			}
+		}
+	}
+}
+
+func TestCodecForgedPayload(t *testing.T) {
+	codec := NewSignedCodec(testKey)
+	encoded := signedAt(testKey, "session", "alice", time.Now())
+	payload, sig, _ := strings.Cut(encoded, ".")
+
+	plain, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged := base64.RawURLEncoding.EncodeToString(append(plain[:8], "admin"...)) + "." + sig
+	if _, err := codec.Decode("session", forged); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("forged payload: err = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestCodecExpiry(t *testing.T) {
+	codec := NewSignedCodec(testKey)
+	codec.MaxAge = time.Hour
+
+	tests := []struct {
+		name string
+		at   time.Time
+		err  error
+	}{
+		{"fresh", time.Now(), nil},
+		{"almost expired", time.Now().Add(-59 * time.Minute), nil},
+		{"expired", time.Now().Add(-61 * time.Minute), ErrExpiredValue},
+	}
+	for _, tt := range tests {
+		_, err := codec.Decode("session", signedAt(testKey, "session", "alice", tt.at))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	codec.MaxAge = 0
+	if _, err := codec.Decode("session", signedAt(testKey, "session", "alice", time.Now().Add(-24*365*time.Hour))); err != nil {
+		t.Errorf("no MaxAge: err = %v, want nil", err)
+	}
+}
+
+func TestCodecRotation(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		codec := &Codec{Keys: NewKeyring(testOldKey), Encrypt: encrypt}
+		old := mustEncode(t, codec, "session", "alice")
+
+		codec.Keys.Rotate(testKey)
+		fresh := mustEncode(t, codec, "session", "bob")
+
+		// the new primary encodes, the old key still decodes
+		if value, err := codec.Decode("session", old); err != nil || value != "alice" {
+			t.Errorf("encrypt=%v: old value after rotate = %q, %v", encrypt, value, err)
+		}
+		if _, err := (&Codec{Keys: NewKeyring(testKey), Encrypt: encrypt}).Decode("session", fresh); err != nil {
+			t.Errorf("encrypt=%v: new value isn't encoded with the new key: %v", encrypt, err)
+		}
+
+		codec.Keys.Retire(testOldKey)
+		if _, err := codec.Decode("session", old); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("encrypt=%v: old value after retire: err = %v, want ErrInvalidValue", encrypt, err)
+		}
+		if value, err := codec.Decode("session", fresh); err != nil || value != "bob" {
+			t.Errorf("encrypt=%v: new value after retire = %q, %v", encrypt, value, err)
+		}
+
+		// the primary stays
+		codec.Keys.Retire(testKey)
+		if _, err := codec.Decode("session", fresh); err != nil {
+			t.Errorf("encrypt=%v: primary was retired: %v", encrypt, err)
+		}
+	}
+}
+
+func TestCodecValueTooLong(t *testing.T) {
+	codec := NewSignedCodec(testKey)
+	if _, err := codec.Encode("session", strings.Repeat("x", maxValueLength)); !errors.Is(err, ErrValueTooLong) {
+		t.Errorf("err = %v, want ErrValueTooLong", err)
+	}
+}
+
+func mustEncode(t *testing.T, c *Codec, name, value string) string {
+	t.Helper()
+	encoded, err := c.Encode(name, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
@@ -49,14 +49,47 @@ type Cookie struct {
 	Secure   bool
 	HttpOnly bool
 	SameSite SameSite
-	Raw      string
-	Unparsed []string // Raw text of unparsed attribute-value pairs
+	// Partitioned keeps third party cookies in a separate jar per top level site (CHIPS)
+	// requires Secure
+	Partitioned bool
+	Priority    Priority // optional, Chrome evicts low priority cookies first
+	Raw         string   // the Set-Cookie header the cookie was parsed from
+	Unparsed    []string // Raw text of unparsed attribute-value pairs
 }
 
+// Priority of a cookie when the browser has to evict cookies of a domain
+type Priority int
+
+const (
+	PriorityDefault Priority = iota // no Priority attribute, browsers assume medium
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+// name=value as sent in Cookie and Set-Cookie headers
+// values containing spaces or commas are quoted
+func (c *Cookie) pair() string {
+	value := c.Value
+	if c.Quoted || strings.ContainsAny(value, " ,") {
+		value = `"` + value + `"`
+	}
+	return c.Name + "=" + value
+}
+
+// format of the Expires attribute, always in GMT
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
 // serialize the cookie struct into string
 // to set in the Set-Cookie header
+// returns an empty string for invalid cookies (see c.Valid())
+// values containing spaces or commas are quoted
 func FormatSetCookie(c Cookie) string {
 
+	if c.Valid() != nil {
+		return ""
+	}
+
 	const extraCookieLength = 110
 
 	// A Builder is used to efficiently build a string using [Builder.Write] methods.
@@ -69,7 +102,7 @@ func FormatSetCookie(c Cookie) string {
 	b.Grow(len(c.Name) + len(c.Path) + len(c.Value) + len(c.Domain) + extraCookieLength)
 
 	// Name and value is required
-	fmt.Fprintf(&b, "%s=%s", c.Name, c.Value)
+	b.WriteString(c.pair())
 
 	// optional fields
 	if c.Path != "" {
@@ -77,11 +110,13 @@ func FormatSetCookie(c Cookie) string {
 	}
 
 	if c.Domain != "" {
-		fmt.Fprintf(&b, "; Domain=%s", c.Domain)
+		// a leading dot is ignored by browsers anyway
+		fmt.Fprintf(&b, "; Domain=%s", strings.TrimPrefix(c.Domain, "."))
 	}
 
-	if !c.Expires.IsZero() {
-		fmt.Fprintf(&b, "; Expires=%s", c.Expires.UTC().Format(time.RFC1123))
+	// dates before 1601 can't be represented by browsers
+	if !c.Expires.IsZero() && c.Expires.Year() >= 1601 {
+		fmt.Fprintf(&b, "; Expires=%s", c.Expires.UTC().Format(TimeFormat))
 	}
 
 	if c.MaxAge > 0 {
@@ -91,13 +126,14 @@ func FormatSetCookie(c Cookie) string {
 	}
 
 	if c.Secure {
-		b.WriteString("; secure")
+		b.WriteString("; Secure")
 	}
 
 	if c.HttpOnly {
 		b.WriteString("; HttpOnly") // prevents the use of javascript
 	}
 
+	// SameSiteDefaultMode leaves it to the browser (Lax in modern browsers)
 	switch c.SameSite {
 	case SameSiteLaxMode:
 		b.WriteString("; SameSite=Lax")
@@ -108,5 +144,18 @@ func FormatSetCookie(c Cookie) string {
 		b.WriteString("; SameSite=None")
 	}
 
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+
+	switch c.Priority {
+	case PriorityLow:
+		b.WriteString("; Priority=Low")
+	case PriorityMedium:
+		b.WriteString("; Priority=Medium")
+	case PriorityHigh:
+		b.WriteString("; Priority=High")
+	}
+
 	return b.String() // .String() returns the accumulated string of Builder Type String
 }
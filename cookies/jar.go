@@ -0,0 +1,459 @@
+package cookies
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Jar stores cookies for clients, the way a browser does (RFC 6265 section 5)
+
+	- cookies from Set-Cookie headers are stored per domain, path and name
+	- they are sent back to urls matching their domain and path, Secure ones only over https
+	- expired cookies and Max-Age<=0 remove the stored cookie
+	- insecure origins can't overwrite, shadow or delete Secure cookies (RFC 6265bis)
+	- without a public suffix list a Domain attribute must contain a dot
+	  (Domain=com is rejected), with one public suffixes (Domain=co.uk) are rejected too
+	- for ip addresses the cookie is always host-only
+	- Jar implements http.CookieJar, so it plugs into an http.Client
+
+	jar := cookies.NewJar()
+	jar.SetFromHeaders(u, setCookieLines)
+	req.Header.Set("Cookie", jar.CookieHeader(u))
+
+	client := &http.Client{Jar: cookies.NewJar(cookies.JarOptions{PublicSuffixList: publicsuffix.List})}
+*/
+
+// Jar is a client side cookie store, safe for concurrent use
+type Jar struct {
+	mu      sync.Mutex
+	entries map[string]*jarEntry // "domain;path;name" => entry
+	psl     PublicSuffixList
+}
+
+// Jar is used as the cookie jar of http.Client
+var _ http.CookieJar = (*Jar)(nil)
+
+// PublicSuffixList tells the public suffix of a domain, "co.uk" for "www.example.co.uk"
+// golang.org/x/net/publicsuffix.List implements it
+type PublicSuffixList interface {
+	PublicSuffix(domain string) string
+}
+
+// JarOptions configures a Jar
+type JarOptions struct {
+	// rejects Domain attributes that are public suffixes, e.g. Domain=co.uk
+	// without it only Domain attributes without a dot are rejected
+	PublicSuffixList PublicSuffixList
+}
+
+// stored cookie, exported fields for the json file
+type jarEntry struct {
+	Name        string    `json:"name"`
+	Value       string    `json:"value"`
+	Quoted      bool      `json:"quoted,omitempty"`
+	Domain      string    `json:"domain"`
+	Path        string    `json:"path"`
+	HostOnly    bool      `json:"host_only,omitempty"`
+	Secure      bool      `json:"secure,omitempty"`
+	HttpOnly    bool      `json:"http_only,omitempty"`
+	SameSite    SameSite  `json:"same_site,omitempty"`
+	Partitioned bool      `json:"partitioned,omitempty"`
+	Persistent  bool      `json:"persistent,omitempty"`
+	Expires     time.Time `json:"expires"`
+	Created     time.Time `json:"created"`
+	LastAccess  time.Time `json:"last_access"`
+}
+
+func (e *jarEntry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e *jarEntry) expired(now time.Time) bool {
+	return e.Persistent && !now.Before(e.Expires)
+}
+
+// NewJar
+// creates an empty cookie jar
+// cookies.NewJar(cookies.JarOptions{PublicSuffixList: publicsuffix.List})
+func NewJar(opts ...JarOptions) *Jar {
+	j := &Jar{entries: map[string]*jarEntry{}}
+	if len(opts) > 0 {
+		j.psl = opts[0].PublicSuffixList
+	}
+	return j
+}
+
+// jar.SetFromHeaders(u, lines)
+// stores the cookies of the Set-Cookie header lines of a response to u
+// lines that can't be parsed are ignored
+func (j *Jar) SetFromHeaders(u *url.URL, lines []string) {
+	var cookies []*Cookie
+	for _, line := range lines {
+		if c, err := ParseSetCookie(line); err == nil {
+			cookies = append(cookies, c)
+		}
+	}
+	j.Store(u, cookies)
+}
+
+// jar.SetCookies(u, cookies)
+// stores the cookies of net/http, the http.CookieJar side of the jar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	converted := make([]*Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		converted = append(converted, &Cookie{
+			Name:        c.Name,
+			Value:       c.Value,
+			Quoted:      c.Quoted,
+			Path:        c.Path,
+			Domain:      c.Domain,
+			Expires:     c.Expires,
+			MaxAge:      c.MaxAge,
+			Secure:      c.Secure,
+			HttpOnly:    c.HttpOnly,
+			SameSite:    SameSite(c.SameSite), // same values as http.SameSite
+			Partitioned: c.Partitioned,
+		})
+	}
+	j.Store(u, converted)
+}
+
+// jar.Store(u, cookies)
+// stores the cookies of a response to u
+// cookies the url isn't allowed to set are ignored
+func (j *Jar) Store(u *url.URL, cookies []*Cookie) {
+	host, ok := canonicalHost(u)
+	if !ok {
+		return
+	}
+	secure := isSecure(u)
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		e, remove, ok := newEntry(c, host, u.Path, secure, now, j.psl)
+		if !ok {
+			continue
+		}
+		if !secure && j.shadowsSecure(e) {
+			continue
+		}
+
+		key := e.key()
+		old, exists := j.entries[key]
+		if remove {
+			delete(j.entries, key)
+			continue
+		}
+		if exists {
+			e.Created = old.Created
+		}
+		j.entries[key] = e
+	}
+}
+
+// an insecure origin can't overwrite a secure cookie, nor shadow it with a narrower path
+// (RFC 6265bis section 5.7 step 16), must be called with the lock held
+func (j *Jar) shadowsSecure(e *jarEntry) bool {
+	for _, old := range j.entries {
+		if !old.Secure || old.Name != e.Name || !pathMatch(e.Path, old.Path) {
+			continue
+		}
+		if domainMatch(e.Domain, old.Domain) || domainMatch(old.Domain, e.Domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEntry applies the storage model of RFC 6265 section 5.3
+// remove is true for cookies that delete the stored one
+func newEntry(c *Cookie, host, requestPath string, secure bool, now time.Time, psl PublicSuffixList) (e *jarEntry, remove, ok bool) {
+	if c.Valid() != nil {
+		return nil, false, false
+	}
+	// only secure origins can set secure cookies
+	if c.Secure && !secure {
+		return nil, false, false
+	}
+
+	e = &jarEntry{
+		Name:        c.Name,
+		Value:       c.Value,
+		Quoted:      c.Quoted,
+		Secure:      c.Secure,
+		HttpOnly:    c.HttpOnly,
+		SameSite:    c.SameSite,
+		Partitioned: c.Partitioned,
+		Created:     now,
+		LastAccess:  now,
+	}
+
+	// expiry, Max-Age wins over Expires
+	switch {
+	case c.MaxAge < 0:
+		remove = true
+	case c.MaxAge > 0:
+		e.Persistent, e.Expires = true, now.Add(time.Duration(c.MaxAge)*time.Second)
+	case !c.Expires.IsZero():
+		e.Persistent, e.Expires = true, c.Expires
+		remove = !now.Before(c.Expires)
+	}
+
+	// domain
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if domain != "" && psl != nil && net.ParseIP(domain) == nil && psl.PublicSuffix(domain) == domain {
+		// Domain=co.uk, only co.uk itself may set it and only for itself
+		if domain != host {
+			return nil, false, false
+		}
+		domain = ""
+	}
+	switch {
+	case domain == "" || domain == host:
+		e.Domain, e.HostOnly = host, domain == ""
+	case net.ParseIP(host) != nil:
+		// ip addresses only get host-only cookies
+		return nil, false, false
+	case !strings.Contains(domain, "."):
+		// top level domains can't be set, also without a public suffix list
+		return nil, false, false
+	case !domainMatch(host, domain):
+		return nil, false, false
+	default:
+		e.Domain = domain
+	}
+
+	// path
+	e.Path = c.Path
+	if !strings.HasPrefix(e.Path, "/") {
+		e.Path = defaultPath(requestPath)
+	}
+
+	return e, remove, true
+}
+
+// jar.Cookies(u)
+// returns the cookies to send with a request to u for net/http, the http.CookieJar side of the jar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	matched := j.Match(u)
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, c := range matched {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Quoted: c.Quoted})
+	}
+	return cookies
+}
+
+// jar.Match(u)
+// returns the cookies to send with a request to u
+// longer paths first, then older cookies first, then by name
+func (j *Jar) Match(u *url.URL) []*Cookie {
+	host, ok := canonicalHost(u)
+	if !ok {
+		return nil
+	}
+	secure := isSecure(u)
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var matched []*jarEntry
+	for key, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, key)
+			continue
+		}
+		if e.HostOnly && host != e.Domain || !e.HostOnly && !domainMatch(host, e.Domain) {
+			continue
+		}
+		if !pathMatch(p, e.Path) || e.Secure && !secure {
+			continue
+		}
+		e.LastAccess = now
+		matched = append(matched, e)
+	}
+
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		if !matched[a].Created.Equal(matched[b].Created) {
+			return matched[a].Created.Before(matched[b].Created)
+		}
+		// set by the same response, the map order would change from call to call
+		return matched[a].Name < matched[b].Name
+	})
+
+	cookies := make([]*Cookie, 0, len(matched))
+	for _, e := range matched {
+		cookies = append(cookies, &Cookie{Name: e.Name, Value: e.Value, Quoted: e.Quoted})
+	}
+	return cookies
+}
+
+// jar.CookieHeader(u)
+// returns the value of the Cookie header for a request to u
+// empty if no cookie matches
+func (j *Jar) CookieHeader(u *url.URL) string {
+	var pairs []string
+	for _, c := range j.Match(u) {
+		pairs = append(pairs, c.pair())
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// jar.Len()
+// returns the number of stored cookies, including expired ones not removed yet
+func (j *Jar) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// jar.MarshalJSON()
+// encodes the cookies that have not expired
+func (j *Jar) MarshalJSON() ([]byte, error) {
+	now := time.Now()
+
+	j.mu.Lock()
+	entries := make([]*jarEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if !e.expired(now) {
+			// copied, Cookies updates LastAccess while we encode
+			entry := *e
+			entries = append(entries, &entry)
+		}
+	}
+	j.mu.Unlock()
+
+	// stable output for files under version control
+	sort.Slice(entries, func(a, b int) bool { return entries[a].key() < entries[b].key() })
+	return json.MarshalIndent(entries, "", "  ")
+}
+
+// jar.UnmarshalJSON(data)
+// adds the cookies encoded by MarshalJSON, expired ones are skipped
+func (j *Jar) UnmarshalJSON(data []byte) error {
+	var entries []*jarEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entries == nil {
+		j.entries = map[string]*jarEntry{}
+	}
+	for _, e := range entries {
+		if !e.expired(now) {
+			j.entries[e.key()] = e
+		}
+	}
+	return nil
+}
+
+// jar.Save(path)
+// writes the cookies to a json file, readable only by the owner
+// session cookies (without Expires or Max-Age) are saved too
+func (j *Jar) Save(path string) error {
+	data, err := j.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	// written to a temporary file first, so a crash never leaves half a jar
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".jar-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadJar
+// reads a jar written by jar.Save
+// a missing file gives an empty jar
+func LoadJar(path string, opts ...JarOptions) (*Jar, error) {
+	j := NewJar(opts...)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := j.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// lower case host without port and trailing dot
+func canonicalHost(u *url.URL) (string, bool) {
+	if u == nil {
+		return "", false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	return host, host != ""
+}
+
+func isSecure(u *url.URL) bool {
+	return u.Scheme == "https" || u.Scheme == "wss"
+}
+
+// domain-match of RFC 6265 section 5.1.3
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// path-match of RFC 6265 section 5.1.4
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// default-path of RFC 6265 section 5.1.4: the directory of the request path
+func defaultPath(requestPath string) string {
+	if !strings.HasPrefix(requestPath, "/") {
+		return "/"
+	}
+	i := strings.LastIndex(requestPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
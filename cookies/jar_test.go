@@ -0,0 +1,234 @@
+package cookies
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestJarDomainMatching(t *testing.T) {
+	tests := []struct {
+		name, from, setCookie, to, want string
+	}{
+		{"host-only sent back", "http://example.com/", "a=1", "http://example.com/", "a=1"},
+		{"host-only not sent to subdomain", "http://example.com/", "a=1", "http://www.example.com/", ""},
+		{"domain sent to subdomain", "http://example.com/", "a=1; Domain=example.com", "http://www.example.com/", "a=1"},
+		{"leading dot ignored", "http://www.example.com/", "a=1; Domain=.example.com", "http://api.example.com/", "a=1"},
+		{"suffix is not a subdomain", "http://example.com/", "a=1; Domain=example.com", "http://badexample.com/", ""},
+		{"foreign domain rejected", "http://example.com/", "a=1; Domain=evil.com", "http://evil.com/", ""},
+		{"top level domain rejected", "http://example.com/", "a=1; Domain=com", "http://other.com/", ""},
+		{"ip only host-only", "http://127.0.0.1/", "a=1; Domain=0.0.1", "http://127.0.0.1/", ""},
+		{"case and port ignored", "http://Example.COM:8080/", "a=1", "http://example.com/", "a=1"},
+	}
+	for _, tt := range tests {
+		jar := NewJar()
+		jar.SetFromHeaders(mustURL(t, tt.from), []string{tt.setCookie})
+		if got := jar.CookieHeader(mustURL(t, tt.to)); got != tt.want {
+			t.Errorf("%s: CookieHeader = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJarPathMatching(t *testing.T) {
+	tests := []struct {
+		name, from, setCookie, to, want string
+	}{
+		{"default path is the directory", "http://example.com/docs/page", "a=1", "http://example.com/docs/other", "a=1"},
+		{"default path not above", "http://example.com/docs/page", "a=1", "http://example.com/", ""},
+		{"explicit path below", "http://example.com/", "a=1; Path=/api", "http://example.com/api/users", "a=1"},
+		{"explicit path exact", "http://example.com/", "a=1; Path=/api", "http://example.com/api", "a=1"},
+		{"prefix is not a path match", "http://example.com/", "a=1; Path=/api", "http://example.com/apiv2", ""},
+		{"relative path uses default", "http://example.com/x/y", "a=1; Path=rel", "http://example.com/x/z", "a=1"},
+	}
+	for _, tt := range tests {
+		jar := NewJar()
+		jar.SetFromHeaders(mustURL(t, tt.from), []string{tt.setCookie})
+		if got := jar.CookieHeader(mustURL(t, tt.to)); got != tt.want {
+			t.Errorf("%s: CookieHeader = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJarOrderAndExpiry(t *testing.T) {
+	jar := NewJar()
+	u := mustURL(t, "https://example.com/api/users")
+	jar.SetFromHeaders(u, []string{"root=1; Path=/", "api=2; Path=/api", "gone=3; Max-Age=-1"})
+
+	if got, want := jar.CookieHeader(u), "api=2; root=1"; got != want {
+		t.Errorf("CookieHeader = %q, want %q", got, want)
+	}
+
+	jar.SetFromHeaders(u, []string{"root=1; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT"})
+	if got, want := jar.CookieHeader(u), "api=2"; got != want {
+		t.Errorf("after expiry CookieHeader = %q, want %q", got, want)
+	}
+	if jar.Len() != 1 {
+		t.Errorf("Len = %d, want 1", jar.Len())
+	}
+}
+
+func TestJarSecure(t *testing.T) {
+	https := mustURL(t, "https://example.com/")
+	http := mustURL(t, "http://example.com/")
+
+	jar := NewJar()
+	jar.SetFromHeaders(http, []string{"s=1; Secure"})
+	if jar.Len() != 0 {
+		t.Errorf("insecure origin stored a Secure cookie")
+	}
+
+	jar.SetFromHeaders(https, []string{"s=1; Secure"})
+	if got := jar.CookieHeader(http); got != "" {
+		t.Errorf("Secure cookie sent over http: %q", got)
+	}
+	if got := jar.CookieHeader(https); got != "s=1" {
+		t.Errorf("Secure cookie over https = %q, want %q", got, "s=1")
+	}
+
+	// an insecure origin can't overwrite or delete a secure cookie
+	jar.SetFromHeaders(http, []string{"s=evil"})
+	jar.SetFromHeaders(http, []string{"s=; Max-Age=-1"})
+	if got := jar.CookieHeader(https); got != "s=1" {
+		t.Errorf("after insecure overwrite = %q, want %q", got, "s=1")
+	}
+
+	// nor shadow it with a narrower path or from a sibling domain
+	jar.SetFromHeaders(http, []string{"s=evil; Path=/admin"})
+	jar.SetFromHeaders(mustURL(t, "http://www.example.com/"), []string{"s=evil; Domain=example.com"})
+	if jar.Len() != 1 {
+		t.Errorf("insecure origin shadowed a Secure cookie, Len = %d", jar.Len())
+	}
+	// other names are fine
+	jar.SetFromHeaders(http, []string{"t=1; Path=/admin"})
+	if got := jar.CookieHeader(mustURL(t, "http://example.com/admin")); got != "t=1" {
+		t.Errorf("insecure cookie of another name = %q, want %q", got, "t=1")
+	}
+
+	jar.SetFromHeaders(https, []string{"s=2; Secure"})
+	if got := jar.CookieHeader(https); got != "s=2" {
+		t.Errorf("after secure overwrite = %q, want %q", got, "s=2")
+	}
+}
+
+// suffixes of a few domains, like golang.org/x/net/publicsuffix.List
+type testSuffixList map[string]bool
+
+func (l testSuffixList) PublicSuffix(domain string) string {
+	for d := domain; ; {
+		if l[d] {
+			return d
+		}
+		_, rest, ok := strings.Cut(d, ".")
+		if !ok {
+			return d
+		}
+		d = rest
+	}
+}
+
+func TestJarPublicSuffixList(t *testing.T) {
+	psl := testSuffixList{"uk": true, "co.uk": true, "github.io": true}
+	tests := []struct {
+		name, from, setCookie, to, want string
+	}{
+		{"public suffix rejected", "http://shop.example.co.uk/", "a=1; Domain=co.uk", "http://other.co.uk/", ""},
+		{"registrable domain allowed", "http://shop.example.co.uk/", "a=1; Domain=example.co.uk", "http://www.example.co.uk/", "a=1"},
+		{"hosted sites are separate", "http://alice.github.io/", "a=1; Domain=github.io", "http://bob.github.io/", ""},
+		{"suffix host gets host-only", "http://github.io/", "a=1; Domain=github.io", "http://github.io/", "a=1"},
+		{"suffix host not shared", "http://github.io/", "a=1; Domain=github.io", "http://bob.github.io/", ""},
+	}
+	for _, tt := range tests {
+		jar := NewJar(JarOptions{PublicSuffixList: psl})
+		jar.SetFromHeaders(mustURL(t, tt.from), []string{tt.setCookie})
+		if got := jar.CookieHeader(mustURL(t, tt.to)); got != tt.want {
+			t.Errorf("%s: CookieHeader = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// without the list only the dot rule applies
+	jar := NewJar()
+	jar.SetFromHeaders(mustURL(t, "http://shop.example.co.uk/"), []string{"a=1; Domain=co.uk"})
+	if got := jar.CookieHeader(mustURL(t, "http://other.co.uk/")); got != "a=1" {
+		t.Errorf("without list: CookieHeader = %q, want %q", got, "a=1")
+	}
+}
+
+func TestJarHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark mode", Quoted: true, Path: "/", MaxAge: 3600})
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(401)
+			return
+		}
+		theme, _ := r.Cookie("theme")
+		fmt.Fprintf(w, "%s %s", c.Value, theme.Value)
+	}))
+	defer srv.Close()
+
+	jar := NewJar()
+	client := &http.Client{Jar: jar}
+	if _, err := client.Get(srv.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL + "/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "abc dark mode" {
+		t.Errorf("GET /me = %d %q", resp.StatusCode, body)
+	}
+
+	// both sides see the same cookies
+	u := mustURL(t, srv.URL+"/me")
+	if got, want := jar.CookieHeader(u), `session=abc; theme="dark mode"`; got != want {
+		t.Errorf("CookieHeader = %q, want %q", got, want)
+	}
+	if got := jar.Cookies(u); len(got) != 2 || got[0].Name != "session" {
+		t.Errorf("Cookies = %v", got)
+	}
+}
+
+func TestJarSaveLoad(t *testing.T) {
+	u := mustURL(t, "https://example.com/")
+	jar := NewJar()
+	jar.SetFromHeaders(u, []string{"session=abc", `theme="dark mode"; Max-Age=3600`, "old=1; Max-Age=-1"})
+
+	path := filepath.Join(t.TempDir(), "jar.json")
+	if err := jar.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := loaded.CookieHeader(u), jar.CookieHeader(u); got != want {
+		t.Errorf("loaded CookieHeader = %q, want %q", got, want)
+	}
+
+	empty, err := LoadJar(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || empty.Len() != 0 {
+		t.Errorf("LoadJar(missing) = %v, %v, want an empty jar", empty.Len(), err)
+	}
+}
@@ -0,0 +1,170 @@
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Parsing
+
+	ParseCookie:    Cookie header of a request      a=1; b="two"
+	ParseSetCookie: Set-Cookie header of a response  id=1; Path=/; Secure; SameSite=Lax
+
+quoted values are unquoted and marked with Quoted, so they are quoted again when sent
+*/
+
+// ParseCookie
+// parses the Cookie header of a request
+// returns the valid cookies and an error describing the invalid pairs, if any
+func ParseCookie(header string) ([]*Cookie, error) {
+	var cookies []*Cookie
+	var errs []error
+
+	for _, part := range strings.Split(header, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: missing '=' in %q", ErrInvalidCookie, part))
+			continue
+		}
+
+		c, err := parsePair(name, value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, errors.Join(errs...)
+}
+
+// ParseSetCookie
+// parses a Set-Cookie header line, for clients talking to servers
+// unknown attributes are kept in Unparsed, invalid attribute values are ignored
+func ParseSetCookie(line string) (*Cookie, error) {
+	parts := strings.Split(line, ";")
+
+	name, value, ok := strings.Cut(strings.TrimSpace(parts[0]), "=")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing '=' in %q", ErrInvalidCookie, parts[0])
+	}
+	c, err := parsePair(name, value)
+	if err != nil {
+		return nil, err
+	}
+	c.Raw = line
+
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		attr, val, _ := strings.Cut(part, "=")
+		attr, val = strings.TrimSpace(attr), strings.TrimSpace(val)
+
+		switch strings.ToLower(attr) {
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HttpOnly = true
+		case "partitioned":
+			c.Partitioned = true
+		case "domain":
+			if validDomain(val) {
+				c.Domain = strings.ToLower(strings.TrimPrefix(val, "."))
+			}
+		case "path":
+			// paths not starting with "/" mean the default path
+			if strings.HasPrefix(val, "/") {
+				c.Path = val
+			}
+		case "max-age":
+			secs, err := strconv.Atoi(val)
+			if err != nil || val[0] == '+' {
+				c.Unparsed = append(c.Unparsed, part)
+				continue
+			}
+			if secs <= 0 {
+				secs = -1
+			}
+			c.MaxAge = secs
+		case "expires":
+			c.RawExpires = val
+			if t, ok := parseDate(val); ok {
+				c.Expires = t.UTC()
+			}
+		case "samesite":
+			switch strings.ToLower(val) {
+			case "lax":
+				c.SameSite = SameSiteLaxMode
+			case "strict":
+				c.SameSite = SameSiteStrictMode
+			case "none":
+				c.SameSite = SameSiteNoneMode
+			default:
+				c.SameSite = SameSiteDefaultMode
+			}
+		case "priority":
+			switch strings.ToLower(val) {
+			case "low":
+				c.Priority = PriorityLow
+			case "medium":
+				c.Priority = PriorityMedium
+			case "high":
+				c.Priority = PriorityHigh
+			}
+		default:
+			c.Unparsed = append(c.Unparsed, part)
+		}
+	}
+	return c, nil
+}
+
+// name=value with an optionally quoted value
+func parsePair(name, value string) (*Cookie, error) {
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !validName(name) {
+		return nil, fmt.Errorf("%w: invalid name %q", ErrInvalidCookie, name)
+	}
+
+	c := &Cookie{Name: name}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+		c.Quoted = true
+	}
+	for i := 0; i < len(value); i++ {
+		if !validValueByte(value[i]) {
+			return nil, fmt.Errorf("%w: invalid byte %q in value of %s", ErrInvalidCookie, value[i], name)
+		}
+	}
+	c.Value = value
+	return c, nil
+}
+
+// date formats found in Expires attributes
+// RFC 6265 allows many more, these are the ones servers actually send
+var dateLayouts = []string{
+	TimeFormat,                        // Sun, 06 Nov 1994 08:49:37 GMT
+	"Mon, 02-Jan-2006 15:04:05 GMT",   // Netscape
+	"Monday, 02-Jan-06 15:04:05 GMT",  // RFC 850
+	"Mon, 02 Jan 2006 15:04:05 -0700", // numeric zone
+	"Mon Jan _2 15:04:05 2006",        // asctime
+}
+
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,104 @@
+package cookies
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCookie(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    map[string]string
+		invalid bool
+	}{
+		{"a=1; b=two", map[string]string{"a": "1", "b": "two"}, false},
+		{`theme="dark mode"`, map[string]string{"theme": "dark mode"}, false},
+		{"token=abc==", map[string]string{"token": "abc=="}, false},
+		{"empty=", map[string]string{"empty": ""}, false},
+		{" a=1 ;; b=2 ", map[string]string{"a": "1", "b": "2"}, false},
+		{"a=1; novalue; b=2", map[string]string{"a": "1", "b": "2"}, true},
+		{`a=1; bad name=x; c="x\y"`, map[string]string{"a": "1"}, true},
+		{"", map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		cookies, err := ParseCookie(tt.header)
+		got := map[string]string{}
+		for _, c := range cookies {
+			got[c.Name] = c.Value
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCookie(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+		if (err != nil) != tt.invalid || (err != nil && !errors.Is(err, ErrInvalidCookie)) {
+			t.Errorf("ParseCookie(%q) error = %v, want invalid %v", tt.header, err, tt.invalid)
+		}
+	}
+}
+
+func TestParseSetCookie(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		line string
+		want Cookie
+	}{
+		{"id=1", Cookie{Name: "id", Value: "1"}},
+		{"id=1; Path=/app; Domain=.Example.com; Secure; HttpOnly",
+			Cookie{Name: "id", Value: "1", Path: "/app", Domain: "example.com", Secure: true, HttpOnly: true}},
+		{"id=1; Max-Age=60; SameSite=Strict; Priority=High; Partitioned; Secure",
+			Cookie{Name: "id", Value: "1", MaxAge: 60, SameSite: SameSiteStrictMode, Priority: PriorityHigh, Partitioned: true, Secure: true}},
+		{"id=1; Max-Age=0", Cookie{Name: "id", Value: "1", MaxAge: -1}},
+		{"id=1; Expires=Wed, 02 Jan 2030 03:04:05 GMT",
+			Cookie{Name: "id", Value: "1", Expires: expires, RawExpires: "Wed, 02 Jan 2030 03:04:05 GMT"}},
+		{"id=1; expires=Wed, 02-Jan-2030 03:04:05 GMT",
+			Cookie{Name: "id", Value: "1", Expires: expires, RawExpires: "Wed, 02-Jan-2030 03:04:05 GMT"}},
+		{`id="a b"; Path=relative; Max-Age=+5; Custom=x`,
+			Cookie{Name: "id", Value: "a b", Quoted: true, Unparsed: []string{"Max-Age=+5", "Custom=x"}}},
+		{"id=1; SameSite=bogus; Domain=bad domain",
+			Cookie{Name: "id", Value: "1", SameSite: SameSiteDefaultMode}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSetCookie(tt.line)
+		if err != nil {
+			t.Errorf("ParseSetCookie(%q): %v", tt.line, err)
+			continue
+		}
+		tt.want.Raw = tt.line
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseSetCookie(%q) =\n%+v\nwant\n%+v", tt.line, *got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"novalue", "bad name=1", `id=x\y`, `id="x"y"`} {
+		if _, err := ParseSetCookie(line); err == nil {
+			t.Errorf("ParseSetCookie(%q) accepted", line)
+		}
+	}
+}
+
+func TestSetCookieRoundTrip(t *testing.T) {
+	tests := []Cookie{
+		{Name: "id", Value: "1"},
+		{Name: "theme", Value: "dark mode", Path: "/", MaxAge: 3600, HttpOnly: true, SameSite: SameSiteLaxMode},
+		{Name: "__Host-sid", Value: "abc", Path: "/", Secure: true, HttpOnly: true, SameSite: SameSiteStrictMode},
+		{Name: "embed", Value: "x", Secure: true, SameSite: SameSiteNoneMode, Partitioned: true, Priority: PriorityLow},
+		{Name: "list", Value: "a,b", Domain: "example.com", Expires: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	for _, c := range tests {
+		line := FormatSetCookie(c)
+		parsed, err := ParseSetCookie(line)
+		if err != nil {
+			t.Errorf("%s: ParseSetCookie(%q): %v", c.Name, line, err)
+			continue
+		}
+		if again := FormatSetCookie(*parsed); again != line {
+			t.Errorf("%s: round trip\n%q\n%q", c.Name, line, again)
+		}
+		if parsed.Value != c.Value || parsed.Secure != c.Secure || parsed.SameSite != c.SameSite ||
+			parsed.Partitioned != c.Partitioned || parsed.Priority != c.Priority || !parsed.Expires.Equal(c.Expires) {
+			t.Errorf("%s: parsed %+v from %q", c.Name, *parsed, line)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package cookies
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+Validation (RFC 6265 section 4.1 and the cookie prefixes of RFC 6265bis)
+
+	- names are tokens: no spaces, separators or control characters
+	- values are cookie-octets: no control characters, '"', ';' or '\'
+	  spaces and commas are allowed but force the value to be quoted
+	- __Secure- cookies must be Secure
+	- __Host- cookies must be Secure, have Path=/ and no Domain
+	- SameSite=None and Partitioned cookies must be Secure
+*/
+
+// ErrInvalidCookie is wrapped by all validation and parsing errors
+var ErrInvalidCookie = errors.New("cookies: invalid cookie")
+
+// c.Valid()
+// reports why the cookie can't be sent, nil if it is fine
+func (c *Cookie) Valid() error {
+	if !validName(c.Name) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidCookie, c.Name)
+	}
+	for i := 0; i < len(c.Value); i++ {
+		if !validValueByte(c.Value[i]) {
+			return fmt.Errorf("%w: invalid byte %q in value of %s", ErrInvalidCookie, c.Value[i], c.Name)
+		}
+	}
+	for i := 0; i < len(c.Path); i++ {
+		if !validPathByte(c.Path[i]) {
+			return fmt.Errorf("%w: invalid byte %q in path of %s", ErrInvalidCookie, c.Path[i], c.Name)
+		}
+	}
+	if c.Domain != "" && !validDomain(c.Domain) {
+		return fmt.Errorf("%w: invalid domain %q of %s", ErrInvalidCookie, c.Domain, c.Name)
+	}
+
+	switch {
+	case strings.HasPrefix(c.Name, "__Secure-") && !c.Secure:
+		return fmt.Errorf("%w: %s must be Secure", ErrInvalidCookie, c.Name)
+	case strings.HasPrefix(c.Name, "__Host-") && (!c.Secure || c.Path != "/" || c.Domain != ""):
+		return fmt.Errorf("%w: %s must be Secure, with Path=/ and without Domain", ErrInvalidCookie, c.Name)
+	case c.SameSite == SameSiteNoneMode && !c.Secure:
+		return fmt.Errorf("%w: SameSite=None requires Secure (%s)", ErrInvalidCookie, c.Name)
+	case c.Partitioned && !c.Secure:
+		return fmt.Errorf("%w: Partitioned requires Secure (%s)", ErrInvalidCookie, c.Name)
+	}
+	return nil
+}
+
+// token of RFC 7230
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// cookie-octet, plus space and comma which are sent quoted
+func validValueByte(b byte) bool {
+	return 0x20 <= b && b < 0x7f && b != '"' && b != ';' && b != '\\'
+}
+
+func validPathByte(b byte) bool {
+	return 0x20 <= b && b < 0x7f && b != ';'
+}
+
+// host name or ip address, a leading dot is allowed
+func validDomain(domain string) bool {
+	domain = strings.TrimPrefix(domain, ".")
+	if net.ParseIP(domain) != nil {
+		return true
+	}
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
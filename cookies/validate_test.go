@@ -0,0 +1,56 @@
+package cookies
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie Cookie
+		valid  bool
+	}{
+		{"plain", Cookie{Name: "id", Value: "abc"}, true},
+		{"empty value", Cookie{Name: "id"}, true},
+		{"space in value", Cookie{Name: "id", Value: "a b"}, true},
+		{"empty name", Cookie{Value: "abc"}, false},
+		{"separator in name", Cookie{Name: "a;b", Value: "abc"}, false},
+		{"space in name", Cookie{Name: "a b", Value: "abc"}, false},
+		{"semicolon in value", Cookie{Name: "id", Value: "a;b"}, false},
+		{"quote in value", Cookie{Name: "id", Value: `a"b`}, false},
+		{"backslash in value", Cookie{Name: "id", Value: `a\b`}, false},
+		{"control byte in value", Cookie{Name: "id", Value: "a\nb"}, false},
+		{"semicolon in path", Cookie{Name: "id", Path: "/a;b"}, false},
+		{"domain", Cookie{Name: "id", Domain: ".example.com"}, true},
+		{"ip domain", Cookie{Name: "id", Domain: "127.0.0.1"}, true},
+		{"bad domain", Cookie{Name: "id", Domain: "exa mple.com"}, false},
+		{"domain label with dash", Cookie{Name: "id", Domain: "-example.com"}, false},
+
+		{"__Secure- with Secure", Cookie{Name: "__Secure-id", Secure: true}, true},
+		{"__Secure- without Secure", Cookie{Name: "__Secure-id"}, false},
+		{"__Host- valid", Cookie{Name: "__Host-id", Secure: true, Path: "/"}, true},
+		{"__Host- without Secure", Cookie{Name: "__Host-id", Path: "/"}, false},
+		{"__Host- with other path", Cookie{Name: "__Host-id", Secure: true, Path: "/app"}, false},
+		{"__Host- without path", Cookie{Name: "__Host-id", Secure: true}, false},
+		{"__Host- with domain", Cookie{Name: "__Host-id", Secure: true, Path: "/", Domain: "example.com"}, false},
+
+		{"SameSite=None with Secure", Cookie{Name: "id", SameSite: SameSiteNoneMode, Secure: true}, true},
+		{"SameSite=None without Secure", Cookie{Name: "id", SameSite: SameSiteNoneMode}, false},
+		{"Partitioned with Secure", Cookie{Name: "id", Partitioned: true, Secure: true}, true},
+		{"Partitioned without Secure", Cookie{Name: "id", Partitioned: true}, false},
+	}
+	for _, tt := range tests {
+		err := tt.cookie.Valid()
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: Valid() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidCookie) {
+			t.Errorf("%s: %v doesn't wrap ErrInvalidCookie", tt.name, err)
+		}
+		// invalid cookies are never formatted
+		if formatted := FormatSetCookie(tt.cookie); (formatted != "") != tt.valid {
+			t.Errorf("%s: FormatSetCookie = %q", tt.name, formatted)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseQValues
+// parses a header with quality values like Accept-Encoding or Accept-Language
+// into a map of lowercased value => weight
+//
+//	"gzip;q=0.5, br, *;q=0.1" => {"gzip": 0.5, "br": 1, "*": 0.1}
+func ParseQValues(header string) map[string]float64 {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		weights[name] = q
+	}
+	return weights
+}
+
+// AcceptsEncoding
+// returns the weight the Accept-Encoding header gives to the content coding
+// codings that are not listed get the weight of "*", or 0 without it
+func AcceptsEncoding(acceptEncoding, coding string) float64 {
+	weights := ParseQValues(acceptEncoding)
+	if q, ok := weights[coding]; ok {
+		return q
+	}
+	if coding == "gzip" {
+		if q, ok := weights["x-gzip"]; ok {
+			return q
+		}
+	}
+	return weights["*"]
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQValues(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"gzip", map[string]float64{"gzip": 1}},
+		{"gzip;q=0.5, br, *;q=0.1", map[string]float64{"gzip": 0.5, "br": 1, "*": 0.1}},
+		{"GZIP ; Q=0.3", map[string]float64{"gzip": 0.3}},
+		{"en-US;level=1;q=0.8", map[string]float64{"en-us": 0.8}},
+		{"gzip;q=abc", map[string]float64{"gzip": 1}},
+		{" , br", map[string]float64{"br": 1}},
+	}
+	for _, tt := range tests {
+		if got := ParseQValues(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQValues(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header, coding string
+		want           float64
+	}{
+		{"gzip, br", "br", 1},
+		{"gzip;q=0.4", "gzip", 0.4},
+		{"x-gzip", "gzip", 1},
+		{"*;q=0.2", "br", 0.2},
+		{"br;q=0, *", "br", 0},
+		{"gzip", "br", 0},
+		{"", "gzip", 0},
+	}
+	for _, tt := range tests {
+		if got := AcceptsEncoding(tt.header, tt.coding); got != tt.want {
+			t.Errorf("AcceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+	Cache policies
+
+	- a CachePolicy describes how long and by whom a response may be cached
+	- it is rendered into Cache-Control, with a matching Expires for old caches
+	  and the Vary headers the cached response depends on
+	- CacheRules pick a policy by path, e.g. immutable fingerprinted assets
+	  and no-store html
+*/
+
+// CachePolicy is the caching policy of a response
+type CachePolicy struct {
+	Public  bool // any cache may store the response (also for authenticated requests)
+	Private bool // only the browser may store the response
+
+	NoCache bool // caches must revalidate with the server before every use
+	NoStore bool // nothing may store the response at all
+
+	MaxAge  time.Duration // fresh for this long in any cache
+	SMaxAge time.Duration // overrides MaxAge for shared caches (CDNs, proxies)
+
+	Immutable      bool // the response never changes while fresh (fingerprinted assets)
+	MustRevalidate bool // stale responses must not be used without revalidation
+	NoTransform    bool // proxies (and the Compress middleware) must not change the body
+
+	StaleWhileRevalidate time.Duration // stale response may be used while revalidating in the background
+	StaleIfError         time.Duration // stale response may be used if the server fails
+
+	Vary []string // request headers the response depends on, e.g. Accept-Encoding
+}
+
+// common policies
+var (
+	// fingerprinted assets (app.3f9a2c1b.js): cache forever
+	CacheImmutable = CachePolicy{Public: true, MaxAge: 365 * 24 * time.Hour, Immutable: true}
+	// html shells and pages that must always be fresh
+	CacheRevalidate = CachePolicy{NoCache: true}
+	// sensitive responses that must never be stored
+	CacheNoStore = CachePolicy{NoStore: true}
+)
+
+// policy.String()
+// renders the policy as value of the Cache-Control header
+func (p CachePolicy) String() string {
+	var directives []string
+
+	if p.NoStore {
+		// nothing else matters if the response must not be stored
+		return "no-store"
+	}
+
+	switch {
+	case p.Private:
+		directives = append(directives, "private")
+	case p.Public:
+		directives = append(directives, "public")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.MaxAge > 0 || p.NoCache || p.MustRevalidate {
+		directives = append(directives, fmt.Sprintf("max-age=%d", seconds(p.MaxAge)))
+	}
+	if p.SMaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("s-maxage=%d", seconds(p.SMaxAge)))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	if p.NoTransform {
+		directives = append(directives, "no-transform")
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%d", seconds(p.StaleWhileRevalidate)))
+	}
+	if p.StaleIfError > 0 {
+		directives = append(directives, fmt.Sprintf("stale-if-error=%d", seconds(p.StaleIfError)))
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// res.SetCachePolicy(policy)
+// sets Cache-Control, Expires and Vary according to the policy
+// Expires is only for HTTP/1.0 caches, Cache-Control takes precedence everywhere else
+func (r *Response) SetCachePolicy(p CachePolicy) {
+	r.SetHeader("Cache-Control", p.String())
+
+	switch {
+	case p.NoStore || p.NoCache || p.MaxAge <= 0:
+		// already expired
+		r.SetHeader("Expires", time.Unix(0, 0).UTC().Format(TimeFormat))
+	default:
+		r.SetHeader("Expires", time.Now().Add(p.MaxAge).UTC().Format(TimeFormat))
+	}
+
+	for _, header := range p.Vary {
+		r.AddHeader("Vary", header)
+	}
+}
+
+// CacheRule applies a cache policy to the paths matching the pattern
+//
+//	"*.html"      matches the file name in any directory
+//	"/api/*"      matches everything below /api
+//	"/img/*.png"  path.Match on the whole path
+//	""            matches every path
+type CacheRule struct {
+	Pattern string
+	// only match fingerprinted file names (app.3f9a2c1b.js)
+	Fingerprinted bool
+	Policy        CachePolicy
+}
+
+// rule.Matches(path)
+// reports whether the rule applies to the path
+func (rule CacheRule) Matches(p string) bool {
+	if rule.Fingerprinted && !IsFingerprinted(p) {
+		return false
+	}
+
+	return rule.Pattern == "" || MatchPath(rule.Pattern, p)
+}
+
+// MatchCacheRule
+// returns the policy of the first rule matching the path
+func MatchCacheRule(rules []CacheRule, p string) (CachePolicy, bool) {
+	for _, rule := range rules {
+		if rule.Matches(p) {
+			return rule.Policy, true
+		}
+	}
+	return CachePolicy{}, false
+}
+
+// IsFingerprinted
+// reports whether the file name contains a content hash
+// like app.3f9a2c1b.js or index-BxY3kL9a.css
+// such files never change, so they can be cached forever
+func IsFingerprinted(name string) bool {
+	name = path.Base(name)
+	ext := path.Ext(name)
+	if ext == "" {
+		return false
+	}
+	name = strings.TrimSuffix(name, ext)
+
+	// the hash is the last segment separated by "." or "-"
+	i := strings.LastIndexAny(name, ".-")
+	if i < 0 {
+		return false
+	}
+	hash := name[i+1:]
+	if len(hash) < 8 {
+		return false
+	}
+
+	hasDigit, hasLetter := false, false
+	for _, c := range hash {
+		switch {
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			hasLetter = true
+		case c == '_':
+		default:
+			return false
+		}
+	}
+	return hasDigit && hasLetter
+}
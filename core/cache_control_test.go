@@ -0,0 +1,105 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCachePolicyString(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CachePolicy
+		want   string
+	}{
+		{"immutable", CacheImmutable, "public, max-age=31536000, immutable"},
+		{"revalidate", CacheRevalidate, "no-cache, max-age=0"},
+		{"no-store wins", CachePolicy{NoStore: true, Public: true, MaxAge: time.Hour}, "no-store"},
+		{"private", CachePolicy{Private: true, Public: true, MaxAge: time.Minute}, "private, max-age=60"},
+		{"shared caches", CachePolicy{Public: true, MaxAge: time.Minute, SMaxAge: time.Hour}, "public, max-age=60, s-maxage=3600"},
+		{"must-revalidate", CachePolicy{MustRevalidate: true}, "max-age=0, must-revalidate"},
+		{"stale", CachePolicy{MaxAge: 10 * time.Second, StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour, NoTransform: true},
+			"max-age=10, no-transform, stale-while-revalidate=60, stale-if-error=3600"},
+		{"empty", CachePolicy{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetCachePolicy(t *testing.T) {
+	var conn net.Conn
+
+	res := NewResponse(&conn)
+	res.SetHeader("Vary", "Accept")
+	res.SetCachePolicy(CachePolicy{Public: true, MaxAge: time.Hour, Vary: []string{"Accept-Encoding", "Accept"}})
+	if got := res.GetHeader("Vary"); got != "Accept, Accept-Encoding" {
+		t.Errorf("Vary = %q", got)
+	}
+	expires, err := ParseTime(res.GetHeader("Expires"))
+	if err != nil || expires.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expires = %q", res.GetHeader("Expires"))
+	}
+
+	res = NewResponse(&conn)
+	res.SetCachePolicy(CacheNoStore)
+	if got := res.GetHeader("Expires"); got != "Thu, 01 Jan 1970 00:00:00 GMT" {
+		t.Errorf("no-store Expires = %q", got)
+	}
+}
+
+func TestMatchCacheRule(t *testing.T) {
+	rules := []CacheRule{
+		{Pattern: "/api/*", Policy: CacheNoStore},
+		{Fingerprinted: true, Policy: CacheImmutable},
+		{Pattern: "*.html", Policy: CacheRevalidate},
+	}
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"/api/users", "no-store", true},
+		{"/api", "no-store", true},
+		{"/assets/app.3f9a2c1b.js", CacheImmutable.String(), true},
+		{"/docs/index.html", CacheRevalidate.String(), true},
+		{"/assets/app.js", "", false},
+		{"/apis", "", false},
+	}
+	for _, tt := range tests {
+		policy, ok := MatchCacheRule(rules, tt.path)
+		if ok != tt.ok || (ok && policy.String() != tt.want) {
+			t.Errorf("MatchCacheRule(%q) = %q, %v, want %q, %v", tt.path, policy.String(), ok, tt.want, tt.ok)
+		}
+	}
+
+	// a rule without pattern matches everything
+	if _, ok := MatchCacheRule([]CacheRule{{Policy: CacheRevalidate}}, "/anything"); !ok {
+		t.Error("rule without pattern didn't match")
+	}
+}
+
+func TestIsFingerprinted(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"app.3f9a2c1b.js", true},
+		{"/assets/index-BxY3kL9a.css", true},
+		{"chunk.a1b2c3d4e5.mjs", true},
+		{"app.js", false},
+		{"jquery-3.7.1.js", false},
+		{"vendor.abcdefgh.js", false},
+		{"build.12345678.js", false},
+		{"app.3f9a2c.js", false},
+		{"3f9a2c1b7d", false},
+	}
+	for _, tt := range tests {
+		if got := IsFingerprinted(tt.name); got != tt.want {
+			t.Errorf("IsFingerprinted(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+	Conditional requests (RFC 9110 section 13)
+
+	- responses carry validators: ETag and Last-Modified
+	- clients send them back with If-None-Match / If-Modified-Since
+	  and get 304 Not Modified instead of the whole body again
+	- If-Match / If-Unmodified-Since protect against lost updates and fail with 412
+*/
+
+// TimeFormat is the format of the dates in http headers
+// e.g: Sun, 06 Nov 1994 08:49:37 GMT
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// ETagMode decides how the mux generates etags for buffered responses
+type ETagMode int
+
+const (
+	ETagStrong ETagMode = iota // strong etag, byte for byte identical bodies (default)
+	ETagWeak                   // weak etag, W/"..."
+	ETagOff                    // no automatic etags
+)
+
+// result of evaluating the preconditions of a request
+const (
+	PreconditionPassed = 0
+	NotModified        = 304
+	PreconditionFailed = 412
+)
+
+// GenerateETag
+// computes an etag from the body
+// the etag is quoted as required by the header syntax
+func GenerateETag(body []byte, weak bool) string {
+	h := fnv.New64a()
+	h.Write(body)
+	etag := fmt.Sprintf(`"%x-%x"`, len(body), h.Sum64())
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// res.SetETag
+// sets the entity tag of the response
+// unquoted values are quoted: res.SetETag("v1") => "v1"
+func (r *Response) SetETag(etag string) {
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+	r.SetHeader("ETag", etag)
+}
+
+// res.SetLastModified
+// sets the Last-Modified header of the response
+// header dates only have second precision, so the time is truncated
+func (r *Response) SetLastModified(t time.Time) {
+	if t.IsZero() || t.Unix() == 0 {
+		return
+	}
+	r.SetHeader("Last-Modified", t.UTC().Format(TimeFormat))
+}
+
+// ParseTime
+// parses a date in any of the three formats allowed by http
+func ParseTime(value string) (time.Time, error) {
+	var lastErr error
+	for _, layout := range []string{TimeFormat, time.RFC850, time.ANSIC} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, lastErr
+}
+
+// CheckPreconditions
+// evaluates the conditional headers of the request against the current validators
+// of the resource, in the order defined by RFC 9110 section 13.2.2
+// returns PreconditionPassed, NotModified (304) or PreconditionFailed (412)
+//
+// for unsafe methods call it before changing anything:
+//
+//	if code := core.CheckPreconditions(req, doc.ETag, doc.Updated); code != core.PreconditionPassed {
+//		return core.NewHTTPError(code)
+//	}
+func CheckPreconditions(req *Request, etag string, lastModified time.Time) int {
+	isGetOrHead := req.Method == "GET" || req.Method == "HEAD"
+
+	// step 1 & 2: If-Match, or If-Unmodified-Since when If-Match is absent
+	if ifMatch := req.GetHeader("If-Match"); ifMatch != "" {
+		if !matchETag(ifMatch, etag, true) {
+			return PreconditionFailed
+		}
+	} else if ius := req.GetHeader("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := ParseTime(ius); err == nil && lastModified.Truncate(time.Second).After(t) {
+			return PreconditionFailed
+		}
+	}
+
+	// step 3 & 4: If-None-Match, or If-Modified-Since when If-None-Match is absent
+	if ifNoneMatch := req.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if matchETag(ifNoneMatch, etag, false) {
+			if isGetOrHead {
+				return NotModified
+			}
+			return PreconditionFailed
+		}
+	} else if ims := req.GetHeader("If-Modified-Since"); ims != "" && isGetOrHead && !lastModified.IsZero() {
+		if t, err := ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return NotModified
+		}
+	}
+
+	return PreconditionPassed
+}
+
+// matchETag
+// checks the etag against a list header like: "a", W/"b", "c"   or   *
+// strong comparison requires both tags to be strong and identical
+// weak comparison only compares the opaque tags
+func matchETag(list, etag string, strong bool) bool {
+	list = strings.TrimSpace(list)
+	if list == "*" {
+		return etag != ""
+	}
+	if etag == "" {
+		return false
+	}
+
+	etagWeak := strings.HasPrefix(etag, "W/")
+	opaque := strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidateWeak := strings.HasPrefix(candidate, "W/")
+		if strong && (candidateWeak || etagWeak) {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// res.HandleConditional
+// called by the mux right before the response is sent
+//
+//   - generates an etag for successful buffered responses that don't have one
+//   - evaluates the conditional headers of the request
+//   - turns the response into 304 or 412 with the body stripped if needed
+//
+// only for GET and HEAD: unsafe methods have already made their change when this runs,
+// they have to call CheckPreconditions themselves before changing anything (RFC 9110 13.2.1)
+func (r *Response) HandleConditional(req *Request, mode ETagMode) {
+	if r.sent || r.statusCode != 200 {
+		return
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return
+	}
+
+	// files (ServeContent, SetBody with *os.File) may be huge
+	// they rely on Last-Modified instead of reading them into memory
+	_, isFile := r.body.(*contentBody)
+	if r.GetHeader("ETag") == "" && mode != ETagOff && r.body != nil && r.bodyLen >= 0 && !isFile {
+		body, err := io.ReadAll(r.body)
+		r.body.Close()
+		if err != nil {
+			r.statusCode = 500
+			r.Write(StatusText(500) + "\n")
+			return
+		}
+		r.WriteBytes(body)
+		r.SetHeader("ETag", GenerateETag(body, mode == ETagWeak))
+	}
+
+	var lastModified time.Time
+	if lm := r.GetHeader("Last-Modified"); lm != "" {
+		lastModified, _ = ParseTime(lm)
+	}
+
+	switch CheckPreconditions(req, r.GetHeader("ETag"), lastModified) {
+	case NotModified:
+		r.stripBody(NotModified)
+	case PreconditionFailed:
+		r.stripBody(PreconditionFailed)
+	}
+}
+
+// stripBody
+// replaces the response with a body-less status response
+// 304 keeps the headers a 200 response would have had (validators, caching, Vary)
+func (r *Response) stripBody(status int) {
+	if r.body != nil {
+		r.body.Close()
+	}
+	r.statusCode = status
+	r.body = io.NopCloser(bytes.NewReader(nil))
+	r.bodyLen = 0
+	r.content = nil
+
+	r.DelHeader("Content-Length")
+	r.DelHeader("Content-Encoding")
+	if status == NotModified {
+		r.DelHeader("Content-Type")
+		return
+	}
+	r.DelHeader("ETag")
+	r.DelHeader("Last-Modified")
+}
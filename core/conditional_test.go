@@ -0,0 +1,151 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	before := modified.Add(-time.Hour).Format(TimeFormat)
+	after := modified.Add(time.Hour).Format(TimeFormat)
+	etag := `"v2"`
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    int
+	}{
+		{"no conditions", "GET", nil, PreconditionPassed},
+
+		// If-None-Match
+		{"if-none-match hit", "GET", map[string]string{"If-None-Match": `"v2"`}, NotModified},
+		{"if-none-match weak hit", "GET", map[string]string{"If-None-Match": `W/"v2"`}, NotModified},
+		{"if-none-match list", "HEAD", map[string]string{"If-None-Match": `"v1", "v2"`}, NotModified},
+		{"if-none-match star", "GET", map[string]string{"If-None-Match": "*"}, NotModified},
+		{"if-none-match miss", "GET", map[string]string{"If-None-Match": `"v1"`}, PreconditionPassed},
+		{"if-none-match hit on put", "PUT", map[string]string{"If-None-Match": "*"}, PreconditionFailed},
+
+		// If-Modified-Since
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": after}, NotModified},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": before}, PreconditionPassed},
+		{"if-modified-since ignored for post", "POST", map[string]string{"If-Modified-Since": after}, PreconditionPassed},
+		{"if-modified-since unparsable", "GET", map[string]string{"If-Modified-Since": "yesterday"}, PreconditionPassed},
+		{"if-none-match wins over if-modified-since", "GET", map[string]string{
+			"If-None-Match":     `"v1"`,
+			"If-Modified-Since": after,
+		}, PreconditionPassed},
+
+		// If-Match
+		{"if-match hit", "PUT", map[string]string{"If-Match": `"v2"`}, PreconditionPassed},
+		{"if-match miss", "PUT", map[string]string{"If-Match": `"v1"`}, PreconditionFailed},
+		{"if-match is strong", "PUT", map[string]string{"If-Match": `W/"v2"`}, PreconditionFailed},
+		{"if-match star", "DELETE", map[string]string{"If-Match": "*"}, PreconditionPassed},
+
+		// If-Unmodified-Since
+		{"unmodified since", "PUT", map[string]string{"If-Unmodified-Since": after}, PreconditionPassed},
+		{"modified after if-unmodified-since", "PUT", map[string]string{"If-Unmodified-Since": before}, PreconditionFailed},
+		{"if-match wins over if-unmodified-since", "PUT", map[string]string{
+			"If-Match":            `"v2"`,
+			"If-Unmodified-Since": before,
+		}, PreconditionPassed},
+
+		// If-Match is evaluated before If-None-Match
+		{"if-match fails first", "GET", map[string]string{
+			"If-Match":      `"v1"`,
+			"If-None-Match": `"v2"`,
+		}, PreconditionFailed},
+	}
+	for _, tt := range tests {
+		req := &Request{Method: tt.method, Headers: tt.headers}
+		if got := CheckPreconditions(req, etag, modified); got != tt.want {
+			t.Errorf("%s: CheckPreconditions = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPreconditionsWithoutValidators(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"if-match star without etag", map[string]string{"If-Match": "*"}, PreconditionFailed},
+		{"if-none-match star without etag", map[string]string{"If-None-Match": "*"}, PreconditionPassed},
+		{"if-modified-since without last-modified", map[string]string{"If-Modified-Since": TimeFormat}, PreconditionPassed},
+	}
+	for _, tt := range tests {
+		req := &Request{Method: "GET", Headers: tt.headers}
+		if got := CheckPreconditions(req, "", time.Time{}); got != tt.want {
+			t.Errorf("%s: CheckPreconditions = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandleConditional(t *testing.T) {
+	var conn net.Conn
+
+	res := NewResponse(&conn)
+	res.Write("hello")
+	res.HandleConditional(&Request{Method: "GET"}, ETagStrong)
+	etag := res.GetHeader("ETag")
+	if etag != GenerateETag([]byte("hello"), false) {
+		t.Fatalf("generated ETag = %q", etag)
+	}
+
+	tests := []struct {
+		name    string
+		mode    ETagMode
+		headers map[string]string
+		status  int
+		etag    string
+	}{
+		{"match", ETagStrong, map[string]string{"If-None-Match": etag}, 304, etag},
+		{"no match", ETagStrong, map[string]string{"If-None-Match": `"other"`}, 200, etag},
+		{"weak mode", ETagWeak, map[string]string{"If-None-Match": etag}, 304, "W/" + etag},
+		{"off", ETagOff, map[string]string{"If-None-Match": etag}, 200, ""},
+		{"if-match fails", ETagStrong, map[string]string{"If-Match": `"other"`}, 412, ""},
+	}
+	for _, tt := range tests {
+		res := NewResponse(&conn)
+		res.Write("hello")
+		res.HandleConditional(&Request{Method: "GET", Headers: tt.headers}, tt.mode)
+
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+		if got := res.GetHeader("ETag"); got != tt.etag {
+			t.Errorf("%s: ETag = %q, want %q", tt.name, got, tt.etag)
+		}
+		if tt.status != 200 && res.ContentLength() != 0 {
+			t.Errorf("%s: body not stripped, %d bytes", tt.name, res.ContentLength())
+		}
+	}
+}
+
+func TestHandleConditionalMethods(t *testing.T) {
+	var conn net.Conn
+
+	// the handler of an unsafe method has already made its change,
+	// a 412 now would hide that it succeeded
+	tests := []struct {
+		method string
+		status int
+	}{
+		{"GET", 412},
+		{"HEAD", 412},
+		{"POST", 200},
+		{"PUT", 200},
+		{"DELETE", 200},
+	}
+	for _, tt := range tests {
+		res := NewResponse(&conn)
+		res.Write("saved")
+		res.HandleConditional(&Request{Method: tt.method, Headers: map[string]string{"If-Match": `"other"`}}, ETagStrong)
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.method, res.GetStatusCode(), tt.status)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// HTTPError is an error that knows which status code it should be answered with
+// handlers can return it (or wrap it with fmt.Errorf("...: %w", err))
+// and the mux's ErrorHandler will use its code and message for the response
+type HTTPError struct {
+	Code    int    // status code sent to the client
+	Message string // message sent to the client, defaults to the status text
+	Err     error  // optional internal error, logged but never sent to the client
+}
+
+// create a new http error
+// core.NewHTTPError(404, "user not found")
+// message is optional, status text is used when it is omitted
+func NewHTTPError(code int, message ...string) *HTTPError {
+	e := &HTTPError{Code: code, Message: StatusText(code)}
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Unwrap allows errors.Is and errors.As to look into the internal error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// e.WithInternal(err)
+// attaches the underlying cause to the http error
+func (e *HTTPError) WithInternal(err error) *HTTPError {
+	e.Err = err
+	return e
+}
+
+// AsHTTPError
+// finds the first HTTPError in the error chain
+// any other error is treated as 500 Internal Server Error
+func AsHTTPError(err error) *HTTPError {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	return NewHTTPError(500).WithInternal(err)
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAsHTTPError(t *testing.T) {
+	cause := errors.New("db: connection refused")
+	tests := []struct {
+		name    string
+		err     error
+		code    int
+		message string
+	}{
+		{"http error", NewHTTPError(404, "user not found"), 404, "user not found"},
+		{"status text by default", NewHTTPError(403), 403, "Forbidden"},
+		{"wrapped", fmt.Errorf("loading: %w", NewHTTPError(409)), 409, "Conflict"},
+		{"plain error", cause, 500, "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		he := AsHTTPError(tt.err)
+		if he.Code != tt.code || he.Message != tt.message {
+			t.Errorf("%s: AsHTTPError = %d %q, want %d %q", tt.name, he.Code, he.Message, tt.code, tt.message)
+		}
+	}
+
+	// the cause stays reachable, but isn't part of the message
+	he := AsHTTPError(cause)
+	if !errors.Is(he, cause) {
+		t.Error("internal error not reachable with errors.Is")
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+/*
+	Form values
+
+	- application/x-www-form-urlencoded and multipart/form-data bodies
+	- the body is read once and put back, so handlers can still read it themselves
+	- files of multipart forms are skipped, only the plain fields are parsed
+*/
+
+// bodies bigger than this are not parsed as forms
+const MaxFormSize = 10 << 20
+
+// ErrFormTooLarge is returned for form bodies bigger than MaxFormSize
+var ErrFormTooLarge = NewHTTPError(413, "Form Too Large")
+
+// req.FormValue(name)
+// returns the first value of the form field, empty string if there is none
+func (r *Request) FormValue(name string) string {
+	form, err := r.Form()
+	if err != nil {
+		return ""
+	}
+	return form.Get(name)
+}
+
+// req.Form()
+// parses the form body of the request, once
+//
+//	form, err := req.Form()
+//	email := form.Get("email")
+func (r *Request) Form() (url.Values, error) {
+	if r.form != nil {
+		return r.form, nil
+	}
+
+	form := url.Values{}
+	mediaType, params, _ := mime.ParseMediaType(r.GetHeader("Content-Type"))
+	if r.Body == nil || (mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data") {
+		r.form = form
+		return form, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, MaxFormSize+1))
+	// the body stays readable for the handler
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxFormSize {
+		return nil, ErrFormTooLarge
+	}
+
+	if mediaType == "multipart/form-data" {
+		err = parseMultipartFields(data, params["boundary"], form)
+	} else {
+		var values url.Values
+		values, err = url.ParseQuery(string(data))
+		for key, v := range values {
+			form[key] = v
+		}
+	}
+	if err != nil {
+		return nil, NewHTTPError(400, "Malformed Form").WithInternal(err)
+	}
+
+	r.form = form
+	return form, nil
+}
+
+// plain fields of a multipart body, file parts are skipped
+func parseMultipartFields(data []byte, boundary string, form url.Values) error {
+	if boundary == "" {
+		return errors.New("multipart body without boundary")
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := part.FormName()
+		if name == "" || part.FileName() != "" {
+			part.Close()
+			continue
+		}
+
+		var value strings.Builder
+		if _, err := io.Copy(&value, part); err != nil {
+			return err
+		}
+		form.Add(name, value.String())
+		part.Close()
+	}
+}
@@ -6,3 +6,38 @@ package core
 // accepts the request and write response to the client
 
 type HandlerFunc func(*Request, *Response)
+
+// HandlerFuncE is the error returning flavour of HandlerFunc
+// instead of setting the status and writing the body by hand on every failure,
+// the handler simply returns the error and the mux's ErrorHandler
+// turns it into a response
+//
+//	func getUser(req *core.Request, res *core.Response) error {
+//		user, err := findUser(req.Param("id"))
+//		if err != nil {
+//			return core.NewHTTPError(404, "user not found")
+//		}
+//		res.JSON(user)
+//		return nil
+//	}
+type HandlerFuncE func(*Request, *Response) error
+
+// h.HandlerFunc()
+// converts the error returning handler into a plain HandlerFunc
+// the returned error is recorded on the response using res.SetError
+// so that the mux (and any middleware in between) can still see it
+func (h HandlerFuncE) HandlerFunc() HandlerFunc {
+	return func(req *Request, res *Response) {
+		res.SetError(h(req, res))
+	}
+}
+
+// h.E()
+// converts a plain HandlerFunc into a HandlerFuncE
+// the error returned is the one recorded on the response, if any
+func (h HandlerFunc) E() HandlerFuncE {
+	return func(req *Request, res *Response) error {
+		h(req, res)
+		return res.GetError()
+	}
+}
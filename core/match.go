@@ -0,0 +1,29 @@
+package core
+
+import (
+	"path"
+	"strings"
+)
+
+// core.MatchPath(pattern, path)
+// matches request paths against the patterns of cache rules, static excludes and csrf exemptions
+//
+//	"/api/*"      matches /api and everything below it
+//	"*.html"      a pattern without "/" matches the file name in any directory
+//	"/img/*.png"  path.Match on the whole path
+//
+// an empty pattern matches nothing
+func MatchPath(pattern, p string) bool {
+	switch {
+	case pattern == "":
+		return false
+	case strings.HasSuffix(pattern, "/*"):
+		base := strings.TrimSuffix(pattern, "/*")
+		return p == base || strings.HasPrefix(p, base+"/")
+	case !strings.Contains(pattern, "/"):
+		matched, _ := path.Match(pattern, path.Base(p))
+		return matched
+	}
+	matched, _ := path.Match(pattern, p)
+	return matched
+}
@@ -0,0 +1,29 @@
+package core
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/api/*", "/api", true},
+		{"/api/*", "/api/users/7", true},
+		{"/api/*", "/apis", false},
+		{"/api/*", "/v1/api/users", false},
+		{"*.html", "/index.html", true},
+		{"*.html", "/docs/guide/intro.html", true},
+		{"*.html", "/app.js", false},
+		{"/img/*.png", "/img/logo.png", true},
+		{"/img/*.png", "/img/icons/logo.png", false},
+		{"/health", "/health", true},
+		{"/health", "/health/db", false},
+		{"", "/", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+/*
+	Content negotiation
+
+	- the client lists the formats it understands in the Accept header
+	  Accept: application/json;q=0.9, text/html
+	- res.Negotiate(data) picks the best format the server can produce
+	- 406 Not Acceptable when there is none
+*/
+
+// media types res.Negotiate can produce, in order of preference
+const (
+	MIMEJSON   = "application/json"
+	MIMEXML    = "application/xml"
+	MIMEText   = "text/plain"
+	MIMEHTML   = "text/html"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// res.Text(status, s)
+// sends plain text with the status code
+func (r *Response) Text(status int, s string) {
+	r.SetStatus(status)
+	r.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	r.Write(s)
+}
+
+// res.HTML(status, s)
+// sends the html string with the status code
+// use res.Render for templates
+func (r *Response) HTML(status int, s string) {
+	r.SetStatus(status)
+	r.SetHeader("Content-Type", "text/html; charset=utf-8")
+	r.Write(s)
+}
+
+// res.XML(data)
+// sends the data encoded as xml
+// indented when pretty printing is enabled with res.SetPretty(true)
+// slices are wrapped in an <items> element, xml documents need a single root
+func (r *Response) XML(data interface{}) {
+	b, err := encodeXML(data, r.pretty)
+	if err != nil {
+		r.SetStatus(500)
+		r.SetError(err)
+		return
+	}
+
+	r.SetHeader("Content-Type", "application/xml; charset=utf-8")
+	r.WriteBytes(b)
+}
+
+func encodeXML(data interface{}, pretty bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	if pretty {
+		enc.Indent("", "  ")
+	}
+
+	var err error
+	v := reflect.ValueOf(data)
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		root := xml.StartElement{Name: xml.Name{Local: "items"}}
+		err = enc.EncodeToken(root)
+		for i := 0; err == nil && i < v.Len(); i++ {
+			err = enc.Encode(v.Index(i).Interface())
+		}
+		if err == nil {
+			err = enc.EncodeToken(root.End())
+		}
+	} else {
+		err = enc.Encode(data)
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// res.NDJSON(data)
+// sends every element of the slice as one line of json (newline delimited json)
+// anything else is sent as a single line
+func (r *Response) NDJSON(data interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				r.SetStatus(500)
+				r.SetError(err)
+				return
+			}
+		}
+	} else if err := enc.Encode(data); err != nil {
+		r.SetStatus(500)
+		r.SetError(err)
+		return
+	}
+
+	r.SetHeader("Content-Type", MIMENDJSON)
+	r.WriteBytes(buf.Bytes())
+}
+
+// res.Negotiate(data, template...)
+// renders the data in the format the client prefers according to its Accept header
+// JSON, XML, plain text, NDJSON and, if a template name is given, HTML via res.Render
+// returns a 406 HTTPError when none of them is acceptable
+// XML is only offered for data encoding/xml can encode, maps e.g. are not
+//
+//	server.GetE("/users", func(req *core.Request, res *core.Response) error {
+//		return res.Negotiate(users, "users/index")
+//	})
+func (r *Response) Negotiate(data interface{}, template ...string) error {
+	offers := []string{MIMEJSON, MIMEXML, MIMEText, MIMENDJSON}
+	if len(template) > 0 && r.renderer != nil {
+		offers = append(offers, MIMEHTML)
+	}
+
+	accept := ""
+	if r.req != nil {
+		accept = r.req.GetHeader("Accept")
+	}
+
+	r.AddHeader("Vary", "Accept")
+
+	chosen := NegotiateContentType(accept, offers)
+	var xmlBody []byte
+	if chosen == MIMEXML {
+		// maps and friends have no xml form, the client gets the next format it accepts
+		var err error
+		if xmlBody, err = encodeXML(data, r.pretty); err != nil {
+			offers = slices.DeleteFunc(offers, func(offer string) bool { return offer == MIMEXML })
+			chosen = NegotiateContentType(accept, offers)
+		}
+	}
+
+	switch chosen {
+	case MIMEJSON:
+		r.JSON(data)
+	case MIMEXML:
+		r.SetHeader("Content-Type", "application/xml; charset=utf-8")
+		r.WriteBytes(xmlBody)
+	case MIMEText:
+		r.Text(r.statusCode, textOf(data))
+	case MIMENDJSON:
+		r.NDJSON(data)
+	case MIMEHTML:
+		return r.Render(template[0], data)
+	default:
+		return NewHTTPError(406, "Not Acceptable: "+strings.Join(offers, ", "))
+	}
+	return r.GetError()
+}
+
+func textOf(data interface{}) string {
+	switch d := data.(type) {
+	case string:
+		return d
+	case []byte:
+		return string(d)
+	case fmt.Stringer:
+		return d.String()
+	}
+	return fmt.Sprintf("%v", data)
+}
+
+// NegotiateContentType
+// returns the offer the Accept header prefers, "" if none is acceptable
+// the most specific media range decides the weight of an offer
+// (text/html beats text/* beats */*), ties are won by the earlier offer
+// an empty Accept header accepts anything, so the first offer wins
+func NegotiateContentType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	ranges := ParseQValues(accept)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := mediaWeight(ranges, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// weight of the media type according to the most specific matching range
+func mediaWeight(ranges map[string]float64, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	if q, ok := ranges[mediaType]; ok {
+		return q
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	if q, ok := ranges[major+"/*"]; ok {
+		return q
+	}
+	if q, ok := ranges["*/*"]; ok {
+		return q
+	}
+	return 0
+}
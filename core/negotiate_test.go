@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEText, MIMEHTML}
+
+	tests := []struct {
+		accept, want string
+	}{
+		{"", MIMEJSON},
+		{"*/*", MIMEJSON},
+		{"application/xml", MIMEXML},
+		{"text/html, application/xhtml+xml, */*;q=0.8", MIMEHTML},
+		{"application/json;q=0.5, application/xml", MIMEXML},
+		{"text/*", MIMEText},
+		{"text/*;q=0.5, text/html", MIMEHTML},
+		{"text/*, text/plain;q=0", MIMEHTML},
+		{"image/png", ""},
+		{"*/*;q=0", ""},
+		{"APPLICATION/XML", MIMEXML},
+	}
+	for _, tt := range tests {
+		if got := NegotiateContentType(tt.accept, offers); got != tt.want {
+			t.Errorf("NegotiateContentType(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+	if got := NegotiateContentType("*/*", nil); got != "" {
+		t.Errorf("without offers = %q", got)
+	}
+}
+
+type negotiatedUser struct {
+	Name string `json:"name" xml:"name"`
+	Age  int    `json:"age" xml:"age"`
+}
+
+func (u negotiatedUser) String() string { return u.Name }
+
+func TestNegotiate(t *testing.T) {
+	users := []negotiatedUser{{"ada", 36}, {"alan", 41}}
+
+	tests := []struct {
+		name        string
+		accept      string
+		data        any
+		contentType string
+		body        string
+	}{
+		{"json", "application/json", users, "application/json",
+			`[{"name":"ada","age":36},{"name":"alan","age":41}]`},
+		{"xml slice", "application/xml", users, "application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<items><negotiatedUser><name>ada</name><age>36</age></negotiatedUser><negotiatedUser><name>alan</name><age>41</age></negotiatedUser></items>`},
+		{"xml struct", "application/xml", users[0], "application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<negotiatedUser><name>ada</name><age>36</age></negotiatedUser>`},
+		{"text", "text/plain", users[0], "text/plain; charset=utf-8", "ada"},
+		{"ndjson", "application/x-ndjson", users, MIMENDJSON,
+			`{"name":"ada","age":36}` + "\n" + `{"name":"alan","age":41}` + "\n"},
+	}
+	for _, tt := range tests {
+		res := negotiateResponse(tt.accept)
+		if err := res.Negotiate(tt.data); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := res.GetHeader("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.name, got, tt.contentType)
+		}
+		if got := readAll(t, res); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+		if res.GetHeader("Vary") != "Accept" {
+			t.Errorf("%s: Vary = %q", tt.name, res.GetHeader("Vary"))
+		}
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	res := negotiateResponse("image/png")
+	err := res.Negotiate("x")
+
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != 406 {
+		t.Errorf("err = %v, want 406", err)
+	}
+
+	// html is only offered with a template and a view engine
+	res = negotiateResponse("text/html")
+	if err := res.Negotiate("x", "users/index"); !errors.As(err, &he) || he.Code != 406 {
+		t.Errorf("html without view engine: err = %v, want 406", err)
+	}
+
+	// maps have no xml form, xml is not offered for them
+	res = negotiateResponse("application/xml")
+	if err := res.Negotiate(map[string]int{"a": 1}); !errors.As(err, &he) || he.Code != 406 {
+		t.Errorf("map as xml: err = %v, want 406", err)
+	}
+}
+
+func TestNegotiateXMLFallback(t *testing.T) {
+	res := negotiateResponse("application/xml, application/json;q=0.5")
+	if err := res.Negotiate(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := res.GetHeader("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := readAll(t, res); got != `{"a":1}` {
+		t.Errorf("body = %q", got)
+	}
+	if res.GetStatusCode() != 200 || res.GetError() != nil {
+		t.Errorf("status = %d, err = %v", res.GetStatusCode(), res.GetError())
+	}
+}
+
+func TestPretty(t *testing.T) {
+	res := negotiateResponse("application/json")
+	res.SetPretty(true)
+	res.JSON(map[string]int{"a": 1})
+	if got := readAll(t, res); got != "{\n  \"a\": 1\n}" {
+		t.Errorf("pretty json = %q", got)
+	}
+}
+
+func negotiateResponse(accept string) *Response {
+	var conn net.Conn
+	res := NewResponse(&conn)
+	res.SetRequest(&Request{Method: "GET", Headers: map[string]string{"Accept": accept}})
+	return res
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+/*
+	Client address behind proxies
+
+	- req.RemoteAddr() is the peer of the tcp connection, the proxy if there is one
+	- req.ClientIP(), req.Scheme() and req.Host() read the forwarded headers,
+	  but only when the peer is a trusted proxy, anyone else could send them to fake their address
+	- only the headers the proxy sets are read (ProxyHeader), X-Forwarded-* by default:
+	  most proxies append to X-Forwarded-For and pass a Forwarded of the client through untouched
+	- the chain is walked from the right, skipping trusted proxies,
+	  the first address that isn't one of them is the client
+*/
+
+// ProxyHeader names the forwarded headers set by the trusted proxies
+type ProxyHeader string
+
+const (
+	// X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host (nginx, haproxy, most load balancers)
+	ProxyXForwardedFor ProxyHeader = "X-Forwarded-For"
+	// Forwarded of RFC 7239 with its for=, proto= and host= parameters
+	ProxyForwarded ProxyHeader = "Forwarded"
+	// X-Real-IP for the address, X-Forwarded-Proto and X-Forwarded-Host for the rest
+	ProxyXRealIP ProxyHeader = "X-Real-IP"
+)
+
+// ranges accepted as keywords by ParseTrustedProxies
+var proxyKeywords = map[string][]string{
+	"loopback": {"127.0.0.0/8", "::1/128"},
+	"private":  {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+}
+
+// core.ParseTrustedProxies(cidrs...)
+// parses the ranges of trusted proxies: "10.0.0.0/8", "192.168.1.10",
+// or the keywords "loopback" and "private"
+func ParseTrustedProxies(cidrs ...string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, cidr := range cidrs {
+		if ranges, ok := proxyKeywords[strings.ToLower(cidr)]; ok {
+			parsed, _ := ParseTrustedProxies(ranges...)
+			prefixes = append(prefixes, parsed...)
+			continue
+		}
+		if strings.Contains(cidr, "/") {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", cidr, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", cidr, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// req.TrustProxies(prefixes, header)
+// sets the trusted proxies of the request and the header they set,
+// done by the mux (server.TrustProxies), an empty header means ProxyXForwardedFor
+func (r *Request) TrustProxies(prefixes []netip.Prefix, header ProxyHeader) {
+	r.trustedProxies = prefixes
+	r.proxyHeader = header
+}
+
+// req.RemoteAddr()
+// returns the address of the connection peer, "ip:port"
+// empty for requests without a connection
+func (r *Request) RemoteAddr() string {
+	if r.Conn == nil || r.Conn.RemoteAddr() == nil {
+		return ""
+	}
+	return r.Conn.RemoteAddr().String()
+}
+
+// req.ClientIP()
+// returns the ip address of the client
+// the forwarded headers are only used when the request comes from a trusted proxy
+func (r *Request) ClientIP() string {
+	peer, ok := r.peerIP()
+	if !ok {
+		host, _, err := net.SplitHostPort(r.RemoteAddr())
+		if err != nil {
+			return r.RemoteAddr()
+		}
+		return host
+	}
+	if !r.trusted(peer) {
+		return peer.String()
+	}
+
+	chain := r.forwardedFor()
+	// walk from the proxy next to us towards the client
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, err := parseForwardedIP(chain[i])
+		if err != nil {
+			// unknown or obfuscated hop, the last valid one is the best we know
+			break
+		}
+		client = addr
+		if !r.trusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// req.Scheme()
+// returns "https" or "http", as seen by the client
+// the forwarded headers are only used when the request comes from a trusted proxy
+func (r *Request) Scheme() string {
+	if r.fromTrustedProxy() {
+		var proto string
+		if r.proxyHeader == ProxyForwarded {
+			proto = lastForwarded(r.GetHeader("Forwarded"), "proto")
+		} else {
+			proto = lastListValue(r.GetHeader("X-Forwarded-Proto"))
+		}
+		if strings.EqualFold(proto, "https") {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// req.Host()
+// returns the host the client asked for, with the port if there is one
+// the forwarded headers are only used when the request comes from a trusted proxy
+func (r *Request) Host() string {
+	if r.fromTrustedProxy() {
+		var host string
+		if r.proxyHeader == ProxyForwarded {
+			host = lastForwarded(r.GetHeader("Forwarded"), "host")
+		} else {
+			host = lastListValue(r.GetHeader("X-Forwarded-Host"))
+		}
+		if host != "" {
+			return host
+		}
+	}
+	return r.GetHeader("Host")
+}
+
+func (r *Request) peerIP() (netip.Addr, bool) {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addrPort.Addr().Unmap(), true
+}
+
+func (r *Request) fromTrustedProxy() bool {
+	peer, ok := r.peerIP()
+	return ok && r.trusted(peer)
+}
+
+func (r *Request) trusted(addr netip.Addr) bool {
+	for _, prefix := range r.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// addresses of the forwarding chain, client first
+// read from the header of the trusted proxies only
+func (r *Request) forwardedFor() []string {
+	var chain []string
+	switch r.proxyHeader {
+	case ProxyForwarded:
+		for _, element := range strings.Split(r.GetHeader("Forwarded"), ",") {
+			if value := forwardedParam(element, "for"); value != "" {
+				chain = append(chain, value)
+			}
+		}
+	case ProxyXRealIP:
+		if ip := strings.TrimSpace(r.GetHeader("X-Real-IP")); ip != "" {
+			chain = append(chain, ip)
+		}
+	default:
+		if header := r.GetHeader("X-Forwarded-For"); header != "" {
+			for _, value := range strings.Split(header, ",") {
+				chain = append(chain, strings.TrimSpace(value))
+			}
+		}
+	}
+	return chain
+}
+
+// value of a parameter of one Forwarded element: for=192.0.2.60;proto=http
+func forwardedParam(element, name string) string {
+	for _, pair := range strings.Split(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, name) {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// the parameter of the last Forwarded element, set by the proxy next to us
+func lastForwarded(header, name string) string {
+	if header == "" {
+		return ""
+	}
+	elements := strings.Split(header, ",")
+	return forwardedParam(elements[len(elements)-1], name)
+}
+
+func lastListValue(header string) string {
+	values := strings.Split(header, ",")
+	return strings.TrimSpace(values[len(values)-1])
+}
+
+// "192.0.2.60", "192.0.2.60:4711", "[2001:db8::1]:4711" or "2001:db8::1"
+func parseForwardedIP(value string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
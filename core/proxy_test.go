@@ -0,0 +1,125 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+// a connection that only knows its peer
+type peerConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c peerConn) RemoteAddr() net.Addr { return c.addr }
+
+func proxyRequest(t *testing.T, peer string, header ProxyHeader, headers map[string]string) *Request {
+	t.Helper()
+	addr, err := net.ResolveTCPAddr("tcp", peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixes, err := ParseTrustedProxies("10.0.0.0/8", "loopback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{Conn: peerConn{addr: addr}, Headers: headers}
+	req.TrustProxies(prefixes, header)
+	return req
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		header  ProxyHeader
+		headers map[string]string
+		want    string
+	}{
+		{"direct client", "203.0.113.9:5000", "", nil, "203.0.113.9"},
+		{"untrusted peer sends xff", "203.0.113.9:5000", "", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+		{"untrusted peer sends forwarded", "203.0.113.9:5000", ProxyForwarded, map[string]string{"Forwarded": "for=1.2.3.4"}, "203.0.113.9"},
+		{"untrusted peer sends x-real-ip", "203.0.113.9:5000", ProxyXRealIP, map[string]string{"X-Real-Ip": "1.2.3.4"}, "203.0.113.9"},
+
+		{"trusted proxy", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"trusted proxy without header", "10.0.0.7:5000", "", nil, "10.0.0.7"},
+		{"client prepends a fake hop", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9"}, "203.0.113.9"},
+		{"chain of trusted proxies", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.9, 10.0.0.3, 10.0.0.5"}, "203.0.113.9"},
+		{"client fakes a trusted hop", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-For": "10.0.0.1, 203.0.113.9"}, "203.0.113.9"},
+		{"garbage hop stops the walk", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-For": "1.2.3.4, unknown, 10.0.0.3"}, "10.0.0.3"},
+		{"ipv6 in xff", "[::1]:5000", "", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+		{"ipv4 mapped peer", "[::ffff:10.0.0.7]:5000", "", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+
+		// headers the proxy doesn't set are passed through from the client
+		{"xff proxy, client sends forwarded", "10.0.0.7:5000", ProxyXForwardedFor, map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"xff proxy, client sends x-real-ip", "10.0.0.7:5000", ProxyXForwardedFor, map[string]string{"X-Real-Ip": "1.2.3.4"}, "10.0.0.7"},
+		{"forwarded proxy, client sends xff", "10.0.0.7:5000", ProxyForwarded, map[string]string{"Forwarded": "for=203.0.113.9", "X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+		{"forwarded proxy, only xff", "10.0.0.7:5000", ProxyForwarded, map[string]string{"X-Forwarded-For": "1.2.3.4"}, "10.0.0.7"},
+		{"forwarded chain", "10.0.0.7:5000", ProxyForwarded, map[string]string{"Forwarded": `for=1.2.3.4, for="[2001:db8::1]:4711";proto=https, for=10.0.0.3`}, "2001:db8::1"},
+		{"x-real-ip proxy", "10.0.0.7:5000", ProxyXRealIP, map[string]string{"X-Real-Ip": "203.0.113.9", "X-Forwarded-For": "1.2.3.4"}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		req := proxyRequest(t, tt.peer, tt.header, tt.headers)
+		if got := req.ClientIP(); got != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchemeAndHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		header  ProxyHeader
+		headers map[string]string
+		scheme  string
+		host    string
+	}{
+		{"direct", "203.0.113.9:5000", "", map[string]string{"Host": "example.com"}, "http", "example.com"},
+		{"untrusted peer", "203.0.113.9:5000", "", map[string]string{"Host": "example.com", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"}, "http", "example.com"},
+		{"xff proxy", "10.0.0.7:5000", "", map[string]string{"Host": "internal:8080", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}, "https", "example.com"},
+		{"xff proxy appends", "10.0.0.7:5000", "", map[string]string{"X-Forwarded-Proto": "http, https", "X-Forwarded-Host": "evil.example, example.com"}, "https", "example.com"},
+		{"xff proxy ignores forwarded", "10.0.0.7:5000", "", map[string]string{"Host": "example.com", "Forwarded": "proto=https;host=evil.example"}, "http", "example.com"},
+		{"forwarded proxy", "10.0.0.7:5000", ProxyForwarded, map[string]string{"Host": "internal", "Forwarded": "for=1.2.3.4;proto=https;host=example.com"}, "https", "example.com"},
+		{"forwarded proxy ignores xff", "10.0.0.7:5000", ProxyForwarded, map[string]string{"Host": "example.com", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"}, "http", "example.com"},
+	}
+	for _, tt := range tests {
+		req := proxyRequest(t, tt.peer, tt.header, tt.headers)
+		if got := req.Scheme(); got != tt.scheme {
+			t.Errorf("%s: Scheme() = %q, want %q", tt.name, got, tt.scheme)
+		}
+		if got := req.Host(); got != tt.host {
+			t.Errorf("%s: Host() = %q, want %q", tt.name, got, tt.host)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		trusted string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", "10.1.2.3", false},
+		{"192.168.1.10", "192.168.1.10", false},
+		{"loopback", "::1", false},
+		{"private", "172.20.0.1", false},
+		{"10.0.0.0/33", "", true},
+		{"proxy.example.com", "", true},
+	}
+	for _, tt := range tests {
+		prefixes, err := ParseTrustedProxies(tt.cidr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.cidr, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		req := proxyRequest(t, net.JoinHostPort(tt.trusted, "80"), "", nil)
+		req.TrustProxies(prefixes, "")
+		if !req.fromTrustedProxy() {
+			t.Errorf("%s: %s isn't trusted", tt.cidr, tt.trusted)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Byte range requests (RFC 9110 section 14)
+
+	- res.ServeContent() announces "Accept-Ranges: bytes" for seekable content
+	- the client asks for parts with: Range: bytes=0-499, 1000-
+	- one range is answered with 206 and Content-Range
+	- several ranges with 206 and a multipart/byteranges body
+	- ranges outside of the content with 416 Range Not Satisfiable
+*/
+
+// byteRange is an inclusive range of bytes: start-end
+type byteRange struct {
+	start, end int64
+}
+
+func (br byteRange) length() int64 {
+	return br.end - br.start + 1
+}
+
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.start, br.end, size)
+}
+
+var errNoOverlap = errors.New("no satisfiable range")
+
+// seekable content served through res.ServeContent
+type content struct {
+	rs   io.ReadSeeker
+	size int64
+}
+
+// closes the content (e.g. the file) once the body was sent
+type contentBody struct {
+	io.Reader
+	rs io.ReadSeeker
+}
+
+func (c *contentBody) Close() error {
+	if closer, ok := c.rs.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// res.ServeContent(name, modtime, content)
+// serves seekable content like a file with support for
+// range requests, If-Range and conditional requests
+// the content type is taken from the extension of name (if not set already)
+// modtime is sent as Last-Modified, zero time means unknown
+// if content is also an io.Closer it is closed after sending
+//
+//	f, _ := os.Open("video.mp4")
+//	info, _ := f.Stat()
+//	res.ServeContent(info.Name(), info.ModTime(), f)
+func (r *Response) ServeContent(name string, modtime time.Time, rs io.ReadSeeker) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = rs.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		r.SetStatus(500)
+		r.Write("Error Reading Content\n")
+		return
+	}
+
+	if r.GetHeader("Content-Type") == "" {
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		r.SetHeader("Content-Type", ctype)
+	}
+
+	r.SetLastModified(modtime)
+	r.SetHeader("Accept-Ranges", "bytes")
+
+	r.body = &contentBody{Reader: io.LimitReader(rs, size), rs: rs}
+	r.bodyLen = size
+	r.content = &content{rs: rs, size: size}
+}
+
+// res.HandleRange
+// called by the mux after the conditional headers were evaluated
+// turns a response from res.ServeContent into 206 / 416 if the request has a Range header
+func (r *Response) HandleRange(req *Request) {
+	if r.sent || r.content == nil || r.statusCode != 200 || req.Method != "GET" {
+		return
+	}
+
+	header := req.GetHeader("Range")
+	if header == "" || !r.ifRangeMatches(req.GetHeader("If-Range")) {
+		return
+	}
+
+	size := r.content.size
+	ranges, err := parseRange(header, size)
+	if err == errNoOverlap {
+		r.stripBody(416)
+		r.SetHeader("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return
+	}
+
+	// syntactically invalid ranges are ignored and the full content is sent
+	// same for requests whose ranges add up to more than the content (overlap abuse)
+	if err != nil || len(ranges) == 0 || sumRanges(ranges) > size {
+		return
+	}
+
+	rs := r.content.rs
+
+	if len(ranges) == 1 {
+		ra := ranges[0]
+		if _, err := rs.Seek(ra.start, io.SeekStart); err != nil {
+			return
+		}
+		r.statusCode = 206
+		r.SetHeader("Content-Range", ra.contentRange(size))
+		r.body = &contentBody{Reader: io.LimitReader(rs, ra.length()), rs: rs}
+		r.bodyLen = ra.length()
+		return
+	}
+
+	// multipart/byteranges body, built lazily from the parts of the content
+	boundary := randomBoundary()
+	ctype := r.GetHeader("Content-Type")
+
+	var parts []io.Reader
+	var total int64
+	for _, ra := range ranges {
+		head := fmt.Sprintf("\r\n--%s\r\nContent-Type: %s\r\nContent-Range: %s\r\n\r\n", boundary, ctype, ra.contentRange(size))
+		parts = append(parts, strings.NewReader(head), &sectionReader{rs: rs, offset: ra.start, n: ra.length()})
+		total += int64(len(head)) + ra.length()
+	}
+	tail := fmt.Sprintf("\r\n--%s--\r\n", boundary)
+	parts = append(parts, strings.NewReader(tail))
+	total += int64(len(tail))
+
+	r.statusCode = 206
+	r.SetHeader("Content-Type", "multipart/byteranges; boundary="+boundary)
+	r.body = &contentBody{Reader: io.MultiReader(parts...), rs: rs}
+	r.bodyLen = total
+}
+
+// If-Range makes the Range header conditional:
+// the range is only served if the validator still matches the current content
+func (r *Response) ifRangeMatches(ifRange string) bool {
+	if ifRange == "" {
+		return true
+	}
+
+	// entity tag, requires strong comparison
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+		etag := r.GetHeader("ETag")
+		return !strings.HasPrefix(ifRange, "W/") && matchETag(ifRange, etag, true)
+	}
+
+	// http date, has to be an exact match of Last-Modified
+	t, err := ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	lastModified, err := ParseTime(r.GetHeader("Last-Modified"))
+	return err == nil && lastModified.Equal(t)
+}
+
+// parseRange
+// parses the Range header against content of the given size
+//
+//	bytes=0-499    => first 500 bytes
+//	bytes=500-     => everything from byte 500
+//	bytes=-500     => last 500 bytes
+func parseRange(header string, size int64) ([]byteRange, error) {
+	unit, spec, ok := strings.Cut(header, "=")
+	if !ok || strings.TrimSpace(unit) != "bytes" {
+		return nil, errors.New("invalid range unit")
+	}
+
+	var ranges []byteRange
+	noOverlap := false
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errors.New("invalid range")
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var ra byteRange
+		if first == "" {
+			// suffix range: last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errors.New("invalid range")
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ra = byteRange{start: size - n, end: size - 1}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errors.New("invalid range")
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, errors.New("invalid range")
+				}
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			if end >= size {
+				end = size - 1
+			}
+			ra = byteRange{start: start, end: end}
+		}
+		ranges = append(ranges, ra)
+	}
+
+	if len(ranges) == 0 && noOverlap {
+		return nil, errNoOverlap
+	}
+	return ranges, nil
+}
+
+func sumRanges(ranges []byteRange) int64 {
+	var total int64
+	for _, ra := range ranges {
+		total += ra.length()
+	}
+	return total
+}
+
+// sectionReader reads n bytes starting at offset
+// it seeks only when it is first read, so several sections can share one ReadSeeker
+// as long as they are read one after the other (io.MultiReader)
+type sectionReader struct {
+	rs     io.ReadSeeker
+	offset int64
+	n      int64
+	seeked bool
+}
+
+func (s *sectionReader) Read(p []byte) (int, error) {
+	if !s.seeked {
+		if _, err := s.rs.Seek(s.offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		s.seeked = true
+	}
+	if s.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.rs.Read(p)
+	s.n -= int64(n)
+	if err == io.EOF && s.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func randomBoundary() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []byteRange
+		err    bool
+	}{
+		{"bytes=0-499", []byteRange{{0, 499}}, false},
+		{"bytes=500-", []byteRange{{500, 999}}, false},
+		{"bytes=-200", []byteRange{{800, 999}}, false},
+		{"bytes=-5000", []byteRange{{0, 999}}, false},
+		{"bytes=900-2000", []byteRange{{900, 999}}, false},
+		{"bytes=0-0, -1", []byteRange{{0, 0}, {999, 999}}, false},
+		{"bytes= 0-9 , 20-29 ", []byteRange{{0, 9}, {20, 29}}, false},
+		{"bytes=0-9, 5000-", []byteRange{{0, 9}}, false},
+		{"bytes=5000-", nil, true},
+		{"bytes=-0", nil, true},
+		{"bytes=9-0", nil, true},
+		{"bytes=a-b", nil, true},
+		{"bytes=10", nil, true},
+		{"items=0-9", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRange(tt.header, 1000)
+		if (err != nil) != tt.err {
+			t.Errorf("parseRange(%q) error = %v, want error %v", tt.header, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRange(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	if _, err := parseRange("bytes=5000-", 1000); err != errNoOverlap {
+		t.Errorf("range beyond the content: err = %v, want errNoOverlap", err)
+	}
+}
+
+const rangeContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func TestHandleRange(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"no range", nil, 200, rangeContent, ""},
+		{"first bytes", map[string]string{"Range": "bytes=0-3"}, 206, "0123", "bytes 0-3/36"},
+		{"open range", map[string]string{"Range": "bytes=30-"}, 206, "uvwxyz", "bytes 30-35/36"},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, 206, "xyz", "bytes 33-35/36"},
+		{"unsatisfiable", map[string]string{"Range": "bytes=100-"}, 416, "", "bytes */36"},
+		{"invalid range ignored", map[string]string{"Range": "bytes=x-y"}, 200, rangeContent, ""},
+		{"overlapping ranges beyond the size", map[string]string{"Range": "bytes=0-30, 5-35"}, 200, rangeContent, ""},
+		{"if-range etag match", map[string]string{"Range": "bytes=0-3", "If-Range": `"v1"`}, 206, "0123", "bytes 0-3/36"},
+		{"if-range etag changed", map[string]string{"Range": "bytes=0-3", "If-Range": `"v0"`}, 200, rangeContent, ""},
+		{"if-range weak etag", map[string]string{"Range": "bytes=0-3", "If-Range": `W/"v1"`}, 200, rangeContent, ""},
+		{"if-range date match", map[string]string{"Range": "bytes=0-3", "If-Range": modified.Format(TimeFormat)}, 206, "0123", "bytes 0-3/36"},
+		{"if-range date older", map[string]string{"Range": "bytes=0-3", "If-Range": modified.Add(-time.Hour).Format(TimeFormat)}, 200, rangeContent, ""},
+	}
+	for _, tt := range tests {
+		res := serveRangeContent(modified)
+		res.HandleRange(&Request{Method: "GET", Headers: tt.headers})
+
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+		if got := readAll(t, res); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+		if got := res.GetHeader("Content-Range"); got != tt.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", tt.name, got, tt.contentRange)
+		}
+		if res.GetHeader("Accept-Ranges") != "bytes" {
+			t.Errorf("%s: Accept-Ranges = %q", tt.name, res.GetHeader("Accept-Ranges"))
+		}
+	}
+}
+
+func TestHandleRangeMultipart(t *testing.T) {
+	res := serveRangeContent(time.Time{})
+	res.HandleRange(&Request{Method: "GET", Headers: map[string]string{"Range": "bytes=0-1, 10-12, -2"}})
+
+	if res.GetStatusCode() != 206 {
+		t.Fatalf("status = %d, want 206", res.GetStatusCode())
+	}
+	mediaType, params, err := mime.ParseMediaType(res.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q", res.GetHeader("Content-Type"))
+	}
+
+	body := readAll(t, res)
+	if int64(len(body)) != res.ContentLength() {
+		t.Errorf("announced length %d, body has %d bytes", res.ContentLength(), len(body))
+	}
+
+	want := []struct{ contentRange, body string }{
+		{"bytes 0-1/36", "01"},
+		{"bytes 10-12/36", "abc"},
+		{"bytes 34-35/36", "yz"},
+	}
+	mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for i, w := range want {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		b, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Range") != w.contentRange || string(b) != w.body {
+			t.Errorf("part %d = %q %q, want %q %q", i, part.Header.Get("Content-Range"), b, w.contentRange, w.body)
+		}
+		if part.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+			t.Errorf("part %d: Content-Type = %q", i, part.Header.Get("Content-Type"))
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected end of multipart body, got %v", err)
+	}
+}
+
+func serveRangeContent(modified time.Time) *Response {
+	var conn net.Conn
+	res := NewResponse(&conn)
+	res.SetETag("v1")
+	res.ServeContent("letters.txt", modified, strings.NewReader(rangeContent))
+	return res
+}
+
+func readAll(t *testing.T, res *Response) string {
+	t.Helper()
+	b, err := io.ReadAll(res.GetBody())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
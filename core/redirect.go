@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"net"
+	"net/url"
+	"strings"
+)
+
+/*
+	Redirects
+
+	- res.Redirect(url, code) sends a 3xx with the Location header
+	- relative urls are resolved against the url of the request:
+	  "edit" on /users/12/ => /users/12/edit, "../" on /users/12 => /
+	- absolute urls may only point to the host of the request or to an allowed host,
+	  so user input like ?next=https://evil.example can't be used for open redirects
+*/
+
+// ErrUnsafeRedirect is returned by res.Redirect for urls pointing to hosts that are not allowed
+var ErrUnsafeRedirect = NewHTTPError(400, "Unsafe Redirect")
+
+// res.Redirect(url, code)
+// redirects the client to the url with one of the redirect status codes
+//
+//	301 Moved Permanently, 308 Permanent Redirect (keeps the method and body)
+//	302 Found, 303 See Other (always followed with GET), 307 Temporary Redirect (keeps the method and body)
+//
+// returns ErrUnsafeRedirect for absolute urls to hosts other than the request host
+// and the hosts allowed with res.AllowRedirectHosts
+func (r *Response) Redirect(location string, code int) error {
+	switch code {
+	case 301, 302, 303, 307, 308:
+	default:
+		return fmt.Errorf("redirect: invalid status code %d", code)
+	}
+
+	// browsers treat backslashes as slashes, "/\evil.example" would leave the site
+	location = strings.ReplaceAll(location, "\\", "/")
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return NewHTTPError(400, "Invalid Redirect URL").WithInternal(err)
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return ErrUnsafeRedirect
+		}
+		if !r.redirectAllowed(u.Hostname()) {
+			return ErrUnsafeRedirect
+		}
+	} else if r.req != nil && r.req.Url != nil {
+		base := &url.URL{Path: r.req.Url.Path}
+		u = base.ResolveReference(u)
+	}
+
+	target := u.String()
+	if target == "" {
+		target = "/"
+	}
+
+	r.SetStatus(code)
+	r.SetHeader("Location", target)
+	r.SetHeader("Content-Type", "text/html; charset=utf-8")
+
+	// tiny body for clients that don't follow redirects, HEAD requests get none
+	if r.req != nil && r.req.Method == "HEAD" {
+		r.Write("")
+		return nil
+	}
+	r.Write(fmt.Sprintf("<a href=\"%s\">%s</a>.\n", html.EscapeString(target), StatusText(code)))
+	return nil
+}
+
+// res.AllowRedirectHosts("example.com", "*.example.com")
+// allows res.Redirect to send clients to these hosts
+// "*.example.com" allows every subdomain of example.com
+// the host of the request itself is always allowed
+func (r *Response) AllowRedirectHosts(hosts ...string) {
+	r.redirectHosts = append(r.redirectHosts, hosts...)
+}
+
+func (r *Response) redirectAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return false
+	}
+
+	if r.req != nil && strings.EqualFold(host, hostname(r.req.Host())) {
+		return true
+	}
+
+	for _, allowed := range r.redirectHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname strips the port from a Host header value
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
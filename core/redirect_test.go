@@ -0,0 +1,103 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func redirectResponse(method, path, host string) *Response {
+	var conn net.Conn
+	res := NewResponse(&conn)
+	u, _ := url.Parse(path)
+	res.SetRequest(&Request{Method: method, Path: u.Path, Url: u, Headers: map[string]string{"Host": host}})
+	return res
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		host     string
+		allow    []string
+		location string
+		want     string // expected Location, "" if the redirect must be refused
+	}{
+		{"absolute path", "/login", "example.com", nil, "/dashboard", "/dashboard"},
+		{"relative", "/users/12/", "example.com", nil, "edit", "/users/12/edit"},
+		{"parent", "/users/12", "example.com", nil, "../", "/"},
+		{"query kept", "/login", "example.com", nil, "/search?q=nuts", "/search?q=nuts"},
+		{"empty", "/login", "example.com", nil, "", "/login"},
+		{"same host", "/", "example.com:8080", nil, "https://example.com/welcome", "https://example.com/welcome"},
+		{"relative with forged host", "/login", "evil.com", nil, "/dashboard", "/dashboard"},
+
+		{"other host", "/", "example.com", nil, "https://evil.com/", ""},
+		{"protocol relative", "/", "example.com", nil, "//evil.com", ""},
+		{"backslash", "/", "example.com", nil, `/\evil.com`, ""},
+		{"backslashes", "/", "example.com", nil, `\\evil.com`, ""},
+		{"scheme without slashes", "/", "example.com", nil, "https:evil.com", ""},
+		{"javascript", "/", "example.com", nil, "javascript:alert(1)", ""},
+		{"data", "/", "example.com", nil, "data:text/html,hi", ""},
+		{"userinfo", "/", "example.com", nil, "https://example.com@evil.com/", ""},
+
+		{"allowed host", "/", "example.com", []string{"auth.example.org"}, "https://auth.example.org/login", "https://auth.example.org/login"},
+		{"wildcard subdomain", "/", "example.com", []string{"*.example.com"}, "https://api.example.com/", "https://api.example.com/"},
+		{"wildcard suffix trick", "/", "example.com", []string{"*.example.com"}, "https://example.com.evil.com/", ""},
+		{"wildcard lookalike", "/", "example.com", []string{"*.example.com"}, "https://evilexample.com/", ""},
+		{"wildcard excludes apex", "/", "other.org", []string{"*.example.com"}, "https://example.com/", ""},
+	}
+	for _, tt := range tests {
+		res := redirectResponse("GET", tt.path, tt.host)
+		res.AllowRedirectHosts(tt.allow...)
+		err := res.Redirect(tt.location, 302)
+
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsafeRedirect) {
+				t.Errorf("%s: Redirect(%q) err = %v, want ErrUnsafeRedirect (Location %q)", tt.name, tt.location, err, res.GetHeader("Location"))
+			}
+			if res.GetHeader("Location") != "" {
+				t.Errorf("%s: Location set to %q", tt.name, res.GetHeader("Location"))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Redirect(%q): %v", tt.name, tt.location, err)
+			continue
+		}
+		if got := res.GetHeader("Location"); got != tt.want {
+			t.Errorf("%s: Location = %q, want %q", tt.name, got, tt.want)
+		}
+		if res.GetStatusCode() != 302 {
+			t.Errorf("%s: status = %d", tt.name, res.GetStatusCode())
+		}
+	}
+}
+
+func TestRedirectCodes(t *testing.T) {
+	for _, code := range []int{301, 302, 303, 307, 308} {
+		res := redirectResponse("GET", "/", "example.com")
+		if err := res.Redirect("/next", code); err != nil || res.GetStatusCode() != code {
+			t.Errorf("code %d: status %d, err %v", code, res.GetStatusCode(), err)
+		}
+	}
+	for _, code := range []int{200, 304, 404} {
+		res := redirectResponse("GET", "/", "example.com")
+		if err := res.Redirect("/next", code); err == nil {
+			t.Errorf("code %d accepted", code)
+		}
+	}
+
+	// the link for clients that don't follow redirects is escaped
+	res := redirectResponse("GET", "/", "example.com")
+	res.Redirect(`/search?q="><script>`, 303)
+	if got := readAll(t, res); got != `<a href="/search?q=&#34;&gt;&lt;script&gt;">See Other</a>.`+"\n" {
+		t.Errorf("body = %q", got)
+	}
+
+	res = redirectResponse("HEAD", "/", "example.com")
+	res.Redirect("/next", 302)
+	if got := readAll(t, res); got != "" {
+		t.Errorf("HEAD body = %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Renderer renders named templates, implemented by the views package
+// the request is passed along so per-request data (current user, csrf token,
+// flash messages) can be merged into the template data
+type Renderer interface {
+	Render(w io.Writer, req *Request, name string, data any, layout ...string) error
+}
+
+// ErrNoRenderer is returned by res.Render when no view engine is attached to the mux
+var ErrNoRenderer = errors.New("no view engine configured, use server.SetViews()")
+
+// res.SetRenderer
+// attaches the view engine used by res.Render
+// the mux does this for every request when a view engine is configured
+func (r *Response) SetRenderer(renderer Renderer) {
+	r.renderer = renderer
+}
+
+// res.SetRequest
+// links the response to the request it answers
+// set by the mux, used by helpers that need to look at the request
+func (r *Response) SetRequest(req *Request) {
+	r.req = req
+}
+
+// res.Request
+// returns the request this response answers, nil if not linked
+func (r *Response) Request() *Request {
+	return r.req
+}
+
+// res.Render("users/show", data)
+// renders the template with the view engine of the mux and writes it as html
+// an optional layout overrides the default one, "" renders without layout
+// nothing is written if rendering fails, the error is returned instead
+func (r *Response) Render(name string, data any, layout ...string) error {
+	if r.renderer == nil {
+		return ErrNoRenderer
+	}
+
+	var buf bytes.Buffer
+	if err := r.renderer.Render(&buf, r.req, name, data, layout...); err != nil {
+		return err
+	}
+
+	r.SetHeader("Content-Type", "text/html; charset=utf-8")
+	r.WriteBytes(buf.Bytes())
+	return nil
+}
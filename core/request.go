@@ -5,8 +5,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"net/netip"
+	"net/textproto"
 	"net/url"
 	"path/filepath"
 	"squirrel/cookies"
@@ -27,6 +28,18 @@ type Request struct {
 	Close         bool
 	Queries       map[string][]string
 	Cookies       []*cookies.Cookie
+
+	// values attached to the request by middlewares and handlers
+	// e.g. the current user, csrf token or flash messages
+	locals map[string]any
+	// session attached by the session middleware
+	session *Session
+	// parsed form body, see req.Form()
+	form url.Values
+	// proxies allowed to tell the client address, see req.ClientIP()
+	trustedProxies []netip.Prefix
+	// the forwarded headers those proxies set
+	proxyHeader ProxyHeader
 }
 
 // func to parse the incoming request
@@ -48,6 +61,42 @@ func (r *Request) Query(queryName string) []string {
 	return r.Queries[queryName]
 }
 
+// req.GetHeader
+// gets the request header by its name, case insensitive
+// returns empty string if the header is not present
+func (r *Request) GetHeader(key string) string {
+	return r.Headers[textproto.CanonicalMIMEHeaderKey(key)]
+}
+
+// req.Set
+// attaches a value to the request
+// middlewares use it to pass data down to the handlers and templates
+func (r *Request) Set(key string, value any) {
+	if r.locals == nil {
+		r.locals = map[string]any{}
+	}
+	r.locals[key] = value
+}
+
+// req.Get
+// returns the value attached to the request, nil if not set
+func (r *Request) Get(key string) any {
+	return r.locals[key]
+}
+
+// req.Locals
+// returns all values attached to the request
+func (r *Request) Locals() map[string]any {
+	return r.locals
+}
+
+// req.IsPreflight()
+// reports whether the request is a CORS preflight: OPTIONS with Origin and Access-Control-Request-Method
+// browsers never send credentials with it, so auth middlewares let it through
+func (r *Request) IsPreflight() bool {
+	return r.Method == "OPTIONS" && r.GetHeader("Origin") != "" && r.GetHeader("Access-Control-Request-Method") != ""
+}
+
 // req.Original
 // gets the original request url
 func (r *Request) OriginalUrl() *url.URL {
@@ -55,16 +104,32 @@ func (r *Request) OriginalUrl() *url.URL {
 }
 
 func ParseRequest(conn net.Conn) (*Request, error) {
+	return ParseRequestLimit(conn, DefaultMaxBodySize)
+}
+
+// bodies bigger than this are refused by ParseRequest, see server.SetMaxBodySize
+const DefaultMaxBodySize = 10 << 20
+
+// ErrBodyTooLarge is returned by ParseRequestLimit for bodies bigger than the limit
+var ErrBodyTooLarge = NewHTTPError(413, "Content Too Large")
+
+// core.ParseRequestLimit(conn, maxBodySize)
+// parses the request like ParseRequest, refusing bodies bigger than maxBodySize bytes
+// the request is returned without its body along with ErrBodyTooLarge, so the error can be answered
+func ParseRequestLimit(conn net.Conn, maxBodySize int64) (*Request, error) {
 
 	reader := bufio.NewReader(conn)      // returns a new [Reader] whose buffer has default size
 	line, err := reader.ReadString('\n') // read until the first delim 'delimeter: \n'
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// extracting the first line of request
 	// GET HTTP/1.1 OK.
 	parts := strings.Fields(strings.TrimSpace(line))
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("malformed request line %q", line)
+	}
 	method, path := parts[0], parts[1]
 
 	if method == "" {
@@ -87,7 +152,7 @@ func ParseRequest(conn net.Conn) (*Request, error) {
 
 		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
 		if len(kv) == 2 {
-			key := strings.TrimSpace(kv[0])
+			key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(kv[0]))
 			value := strings.TrimSpace(kv[1])
 			headers[key] = value
 
@@ -102,65 +167,62 @@ func ParseRequest(conn net.Conn) (*Request, error) {
 		}
 	}
 
-	// if the conn is post request
-	// read the body as well
-	body := io.NopCloser(strings.NewReader(""))
-	if contentLength > 0 {
-		bodyBuf := make([]byte, contentLength)
-		_, err := io.ReadFull(body, bodyBuf)
-		if err != nil {
-			return nil, err
-		}
-		// converting the bytes into io.Reader type
-		// cause, our body is actually io.ReaderCloser
-		body = io.NopCloser(bytes.NewReader(bodyBuf))
-	}
-
 	query := map[string][]string{}
-	u, _ := url.Parse(path)
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("malformed request target %q", path)
+	}
 	for k, v := range u.Query() {
 		query[k] = v
 	}
 
-	actualPath := filepath.Clean(path)
-	if strings.HasSuffix(u.Path, "/") {
-		actualPath = strings.TrimSuffix(u.Path, "/")
+	// cleaned path without the query and the trailing slash
+	// the path as sent by the client is still available in req.Url.Path
+	actualPath := "/"
+	if u.Path != "" {
+		actualPath = filepath.ToSlash(filepath.Clean(u.Path))
 	}
 
-	return &Request{
+	req := &Request{
+		Conn:          conn,
 		Method:        method,
 		Path:          actualPath, // just getting the pure path without query
 		Url:           u,
-		Body:          body,
+		Body:          io.NopCloser(strings.NewReader("")),
 		Headers:       headers,
 		Close:         false,
 		ContentLength: contentLength,
 		Queries:       query,
 		Cookies:       cookies,
-	}, nil
+	}
 
+	// checked before allocating, the client chooses the Content-Length
+	if contentLength > maxBodySize {
+		return req, ErrBodyTooLarge
+	}
+
+	// if the conn is post request
+	// read the body as well
+	if contentLength > 0 {
+		bodyBuf := make([]byte, contentLength)
+		_, err := io.ReadFull(reader, bodyBuf)
+		if err != nil {
+			return nil, err
+		}
+		// converting the bytes into io.Reader type
+		// cause, our body is actually io.ReaderCloser
+		req.Body = io.NopCloser(bytes.NewReader(bodyBuf))
+	}
+
+	return req, nil
 }
 
 // since the headers we receive the cookie mostly in name-value pair
 // we don't need to parse it much
+// quoted values are unquoted, pairs with invalid names or values are skipped
+// use cookies.ParseCookie to find out what was wrong with them
 func ParseCookieHeader(header string) []*cookies.Cookie {
-
-	parts := strings.Split(header, ";")
-
-	var c []*cookies.Cookie
-
-	for _, part := range parts {
-
-		pair := strings.SplitN(strings.TrimSpace(part), "=", 2)
-
-		if len(pair) == 2 {
-			c = append(c, &cookies.Cookie{
-				Name:  pair[0],
-				Value: pair[1],
-			})
-		}
-
-	}
+	c, _ := cookies.ParseCookie(header)
 	return c
 }
 
@@ -175,3 +237,15 @@ func (r *Request) GetCookie(name string) *cookies.Cookie {
 	}
 	return nil
 }
+
+// req.SignedCookie(codec, name)
+// returns the value of a cookie set with res.SetSignedCookie
+// the client can't forge it: values that were changed, expired or
+// encoded with a retired key return an error
+func (r *Request) SignedCookie(codec *cookies.Codec, name string) (string, error) {
+	cookie := r.GetCookie(name)
+	if cookie == nil {
+		return "", cookies.ErrNoCookie
+	}
+	return codec.Decode(name, cookie.Value)
+}
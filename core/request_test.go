@@ -0,0 +1,132 @@
+package core
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+// parses the raw request as the server would read it from the connection
+func parseRaw(t *testing.T, raw string) *Request {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		client.Write([]byte(raw))
+		client.Close()
+	}()
+	req, err := ParseRequest(server)
+	if err != nil {
+		t.Fatalf("ParseRequest: %v", err)
+	}
+	return req
+}
+
+func TestParseRequestHeaders(t *testing.T) {
+	req := parseRaw(t, "GET /users?id=7 HTTP/1.1\r\nhost: example.com\r\naccept-ENCODING: gzip\r\nX-Request-Id: abc\r\n\r\n")
+
+	tests := []struct {
+		name, want string
+	}{
+		{"Host", "example.com"},
+		{"Accept-Encoding", "gzip"},
+		{"accept-encoding", "gzip"},
+		{"x-request-id", "abc"},
+		{"X-Missing", ""},
+	}
+	for _, tt := range tests {
+		if got := req.GetHeader(tt.name); got != tt.want {
+			t.Errorf("GetHeader(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if req.Method != "GET" || req.Url.Path != "/users" || req.Query("id")[0] != "7" {
+		t.Errorf("request line parsed as %s %s %v", req.Method, req.Url.Path, req.Queries)
+	}
+}
+
+func TestParseRequestBody(t *testing.T) {
+	req := parseRaw(t, "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: 11\r\n\r\nhello world")
+
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello world" || req.ContentLength != 11 {
+		t.Errorf("body = %q (ContentLength %d), want %q", b, req.ContentLength, "hello world")
+	}
+}
+
+func TestParseRequestMalformed(t *testing.T) {
+	tests := []struct {
+		name, raw string
+	}{
+		{"connection closed", ""},
+		{"only a method", "GET\r\n\r\n"},
+		{"blank request line", "\r\n\r\n"},
+	}
+	for _, tt := range tests {
+		client, server := net.Pipe()
+		go func() {
+			client.Write([]byte(tt.raw))
+			client.Close()
+		}()
+		if _, err := ParseRequest(server); err == nil {
+			t.Errorf("%s: ParseRequest returned no error", tt.name)
+		}
+	}
+}
+
+func TestParseRequestPath(t *testing.T) {
+	tests := []struct {
+		target, path, urlPath string
+	}{
+		{"/", "/", "/"},
+		{"/docs/", "/docs", "/docs/"},
+		{"/docs?page=2", "/docs", "/docs"},
+		{"/a//b/../c", "/a/c", "/a//b/../c"},
+		{"/../../etc/passwd", "/etc/passwd", "/../../etc/passwd"},
+	}
+	for _, tt := range tests {
+		req := parseRaw(t, "GET "+tt.target+" HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		if req.Path != tt.path || req.Url.Path != tt.urlPath {
+			t.Errorf("%s: Path = %q, Url.Path = %q, want %q, %q", tt.target, req.Path, req.Url.Path, tt.path, tt.urlPath)
+		}
+	}
+}
+
+func TestParseRequestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		limit  int64
+		err    error
+		body   string
+		method string
+	}{
+		{"within the limit", "POST /upload HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello", 5, nil, "hello", "POST"},
+		{"too large", "POST /upload HTTP/1.1\r\nContent-Length: 6\r\n\r\nhello!", 5, ErrBodyTooLarge, "", "POST"},
+		// refused before anything is allocated or read
+		{"huge length", "POST /upload HTTP/1.1\r\nContent-Length: 9223372036854775807\r\n\r\n", DefaultMaxBodySize, ErrBodyTooLarge, "", "POST"},
+	}
+	for _, tt := range tests {
+		client, server := net.Pipe()
+		go func() {
+			client.Write([]byte(tt.raw))
+			client.Close()
+		}()
+		req, err := ParseRequestLimit(server, tt.limit)
+		server.Close()
+		if err != tt.err {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		// the request is still returned, so the error can be answered
+		if req == nil || req.Method != tt.method {
+			t.Errorf("%s: request = %v", tt.name, req)
+			continue
+		}
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+	}
+}
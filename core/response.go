@@ -1,12 +1,18 @@
 package core
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net"
+	"net/textproto"
+	"os"
+	"sort"
 	"squirrel/cookies"
+	"strconv"
 	"strings"
 )
 
@@ -16,23 +22,60 @@ type Response struct {
 	headers     map[string]string
 	contentType string
 	body        io.ReadCloser
+	bodyLen     int64    // length of the body, -1 when unknown (streams)
+	content     *content // seekable content from ServeContent, allows range requests
 	statusCode  int
 	cookies     []cookies.Cookie
+	err         error    // error returned by the handler, if any
+	req         *Request // request this response answers
+	renderer    Renderer // view engine used by res.Render
+	pretty      bool     // indent JSON and XML bodies
+	sent        bool     // true once the response has been written to the connection
+	// hosts res.Redirect may send the client to, besides the request host
+	redirectHosts []string
 }
 
 var (
 	statusText = map[int]string{
 		200: "OK",
-		404: "Not Found",
-		302: "Redirect",
-		500: "Internal Server Error",
+		201: "Created",
+		202: "Accepted",
+		204: "No Content",
+		206: "Partial Content",
+		301: "Moved Permanently",
+		302: "Found",
+		303: "See Other",
+		304: "Not Modified",
+		307: "Temporary Redirect",
+		308: "Permanent Redirect",
+		400: "Bad Request",
+		401: "Unauthorized",
 		403: "Forbidden",
-		401: "Bad Request",
+		404: "Not Found",
 		405: "Method Not Allowed",
+		406: "Not Acceptable",
+		409: "Conflict",
+		412: "Precondition Failed",
+		413: "Content Too Large",
+		415: "Unsupported Media Type",
+		416: "Range Not Satisfiable",
+		422: "Unprocessable Content",
+		429: "Too Many Requests",
+		500: "Internal Server Error",
+		501: "Not Implemented",
+		502: "Bad Gateway",
+		503: "Service Unavailable",
 		// TODO: add more status texts as needed
 	}
 )
 
+// StatusText
+// returns the reason phrase for the status code
+// empty string if the code is unknown
+func StatusText(code int) string {
+	return statusText[code]
+}
+
 // create a new response object
 func NewResponse(conn *net.Conn) *Response {
 	return &Response{
@@ -40,13 +83,45 @@ func NewResponse(conn *net.Conn) *Response {
 		contentType: "text/plain",
 		statusCode:  200,
 		headers:     map[string]string{},
+		bodyLen:     -1,
 	}
 }
 
 // res.SetHeader
 // Sets header for response body
+// header names are canonicalized, so "content-type" and "Content-Type" are the same header
 func (r *Response) SetHeader(key, value string) {
-	r.headers[key] = value
+	r.headers[textproto.CanonicalMIMEHeaderKey(key)] = value
+}
+
+// res.AddHeader
+// appends the value to a list header like Vary or Cache-Control
+// values already present are not added twice
+func (r *Response) AddHeader(key, value string) {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	existing, ok := r.headers[key]
+	if !ok || existing == "" {
+		r.headers[key] = value
+		return
+	}
+	for _, v := range strings.Split(existing, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return
+		}
+	}
+	r.headers[key] = existing + ", " + value
+}
+
+// res.GetHeader
+// returns the value of the response header, empty string if not set
+func (r *Response) GetHeader(key string) string {
+	return r.headers[textproto.CanonicalMIMEHeaderKey(key)]
+}
+
+// res.DelHeader
+// removes the header from the response
+func (r *Response) DelHeader(key string) {
+	delete(r.headers, textproto.CanonicalMIMEHeaderKey(key))
 }
 
 // res.SetStatus
@@ -65,8 +140,36 @@ func (r *Response) GetStatusCode() int {
 // accepts io.Reader type
 // works for any kind of stream data like:
 // reading File or Stream
+// the body is closed once it was sent to the client
 func (r *Response) SetBody(reader io.ReadCloser) {
-	r.body = io.NopCloser(reader)
+	r.body = reader
+	r.bodyLen = -1
+	r.content = nil
+
+	// files have a known size and are sent without copying them through memory
+	if file, ok := reader.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			r.body = &contentBody{Reader: io.LimitReader(file, info.Size()), rs: file}
+			r.bodyLen = info.Size()
+		}
+	}
+}
+
+// res.GetBody
+// returns the body set by the handler
+// middlewares use it to transform the body before it is sent
+func (r *Response) GetBody() io.ReadCloser {
+	return r.body
+}
+
+// res.ContentLength
+// length of the body in bytes
+// returns -1 if the body is a stream with unknown length
+func (r *Response) ContentLength() int64 {
+	if r.body == nil {
+		return 0
+	}
+	return r.bodyLen
 }
 
 // add methods and send to the client
@@ -75,6 +178,8 @@ func (r *Response) SetBody(reader io.ReadCloser) {
 
 func (r *Response) Write(body string) {
 	r.body = io.NopCloser(strings.NewReader(body))
+	r.bodyLen = int64(len(body))
+	r.content = nil
 }
 
 // res.WriteBytes(b []byte)
@@ -82,15 +187,19 @@ func (r *Response) Write(body string) {
 // works well for binary responses Images Files Compressed/gzipped data
 func (r *Response) WriteBytes(b []byte) {
 	r.body = io.NopCloser(bytes.NewReader(b))
+	r.bodyLen = int64(len(b))
+	r.content = nil
 }
 
 // res.JSON()
 // allows to send the response as json data
 // accepts any kind of data and returns response in json format
+// indented when pretty printing is enabled with res.SetPretty(true)
 func (r *Response) JSON(data interface{}) {
-	b, err := json.MarshalIndent(data, "", "")
+	b, err := r.marshalJSON(data)
 	if err != nil {
-		r.conn.Write([]byte("HTTP/1.1 500 Internal Server Error\r\n\r\n"))
+		r.SetStatus(500)
+		r.SetError(err)
 		return
 	}
 
@@ -101,48 +210,150 @@ func (r *Response) JSON(data interface{}) {
 
 }
 
+func (r *Response) marshalJSON(data interface{}) ([]byte, error) {
+	if r.pretty {
+		return json.MarshalIndent(data, "", "  ")
+	}
+	return json.Marshal(data)
+}
+
+// res.SetPretty(true)
+// toggles pretty printing (indentation) of JSON and XML responses
+func (r *Response) SetPretty(pretty bool) {
+	r.pretty = pretty
+}
+
 func (r *Response) Send() {
 
+	// response can only be written once to the connection
+	// handlers, middlewares and the mux may all call Send()
+	if r.sent {
+		return
+	}
+	r.sent = true
+
 	// check if body is empty
 	if r.body == nil {
 		r.Write("")
 	}
 
 	statusLine := fmt.Sprintf("HTTP/1.1 %d %s\r\n", r.statusCode, statusText[r.statusCode])
-	contentType := fmt.Sprintf("Content-Type: %s\r\n", r.headers["Content-Type"])
 
-	// A Buffer is a variable-sized buffer of bytes with [Buffer.Read] and [Buffer.Write] methods
+	// 204 and 304 responses never have a body
+	// so they don't describe one either
+	bodyless := r.statusCode == 204 || r.statusCode == 304
+
+	if r.headers["Content-Type"] == "" && !bodyless {
+		r.headers["Content-Type"] = r.contentType
+	}
 
-	var bodyBuf bytes.Buffer
 	defer r.body.Close()
 
-	// copy all the contents of body to the buffer
-	// body need to written at the end after one blank line
-	_, err := io.Copy(&bodyBuf, r.body)
-	if err != nil {
-		r.conn.Write([]byte("HTTP/1.1 500 Internal Server Error"))
-		return
+	// content length always comes from the actual body
+	// any value set by the handler may be stale (e.g. after compression)
+	// bodies of unknown length (streams) are sent in chunks
+	delete(r.headers, "Content-Length")
+	delete(r.headers, "Transfer-Encoding")
+	chunked := false
+	switch {
+	case bodyless:
+	case r.bodyLen >= 0:
+		r.headers["Content-Length"] = strconv.FormatInt(r.bodyLen, 10)
+	default:
+		r.headers["Transfer-Encoding"] = "chunked"
+		chunked = true
 	}
 
-	contentLength := fmt.Sprintf("Content-Length: %d\r\n", bodyBuf.Len())
+	// every connection serves a single request
+	r.headers["Connection"] = "close"
+
+	// status line and headers are buffered and written at once
+	// the body is written straight to the connection afterwards
+	w := bufio.NewWriter(r.conn)
 
 	// write response object to the connection
 	// along with body if available
-	r.conn.Write([]byte(statusLine))
-	r.conn.Write([]byte(contentType))
-	r.conn.Write([]byte(contentLength))
+	w.WriteString(statusLine)
+
+	// headers are written in sorted order so the output is stable
+	keys := make([]string, 0, len(r.headers))
+	for key := range r.headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s: %s\r\n", key, r.headers[key])
+	}
 
 	// set the cookie headers to the client if available
+	// invalid cookies are dropped, browsers would reject them anyway
 	for _, cookie := range r.cookies {
+		if err := cookie.Valid(); err != nil {
+			log.Printf("Dropping cookie: %v", err)
+			continue
+		}
 		cookieHeader := cookies.FormatSetCookie(cookie)
-		r.SetHeader("Set-Cookie", cookieHeader)
-		// r.conn.Write([]byte("Set-Cookie: " + cookieHeader + "\r\n"))
-		r.conn.Write([]byte(fmt.Sprintf("Set-Cookie: %s\r\n", cookieHeader)))
+		fmt.Fprintf(w, "Set-Cookie: %s\r\n", cookieHeader)
+	}
+
+	w.WriteString("\r\n") // single blank line before writing the body
+
+	// HEAD responses describe the body of a GET without sending it
+	head := r.req != nil && r.req.Method == "HEAD"
+	if err := w.Flush(); err != nil || bodyless || head {
+		return
 	}
 
-	r.conn.Write([]byte("\r\n")) // single blank line before writing the body
+	if chunked {
+		cw := &chunkedWriter{w: w}
+		if _, err := io.Copy(cw, r.body); err == nil {
+			cw.Close()
+		}
+		w.Flush()
+		return
+	}
+
+	// copying straight from the file to the connection lets
+	// net.TCPConn.ReadFrom use sendfile, the file never passes through user space
+	io.Copy(r.conn, unwrapBody(r.body))
+}
+
+// bodies from ServeContent wrap the file to close it after sending
+// the inner reader (an io.LimitedReader around *os.File) is what enables sendfile
+func unwrapBody(body io.Reader) io.Reader {
+	if cb, ok := body.(*contentBody); ok {
+		return cb.Reader
+	}
+	return body
+}
+
+// chunkedWriter writes the body with chunked transfer encoding
+// each Write is one chunk: <size in hex>\r\n<data>\r\n
+type chunkedWriter struct {
+	w io.Writer
+}
 
-	r.conn.Write(bodyBuf.Bytes())
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(c.w, "%x\r\n", len(p)); err != nil {
+		return 0, err
+	}
+	if _, err := c.w.Write(p); err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(c.w, "\r\n"); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writes the last, empty chunk
+func (c *chunkedWriter) Close() error {
+	_, err := io.WriteString(c.w, "0\r\n\r\n")
+	return err
 }
 
 // conn.Write([]byte) Write writes data to the connection.
@@ -153,3 +364,39 @@ func (r *Response) Send() {
 func (r *Response) SetCookie(cookie cookies.Cookie) {
 	r.cookies = append(r.cookies, cookie)
 }
+
+// res.SetSignedCookie(codec, cookie)
+// signs or encrypts the value of the cookie with the codec and sets it
+// read it back with req.SignedCookie
+//
+//	codec := cookies.NewSignedCodec(secret)
+//	res.SetSignedCookie(codec, cookies.Cookie{Name: "theme", Value: "dark", Path: "/"})
+func (r *Response) SetSignedCookie(codec *cookies.Codec, cookie cookies.Cookie) error {
+	value, err := codec.Encode(cookie.Name, cookie.Value)
+	if err != nil {
+		return err
+	}
+	cookie.Value = value
+	r.SetCookie(cookie)
+	return nil
+}
+
+// res.SetError
+// records the error returned by a handler
+// the mux hands it over to its ErrorHandler once the handler chain is done
+// passing nil clears the previously recorded error
+func (r *Response) SetError(err error) {
+	r.err = err
+}
+
+// res.GetError
+// returns the error recorded for this response, if any
+func (r *Response) GetError() error {
+	return r.err
+}
+
+// res.Sent
+// reports whether the response was already written to the client
+func (r *Response) Sent() bool {
+	return r.sent
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"squirrel/cookies"
+	"strings"
+	"testing"
+)
+
+// sends the response over a pipe and parses it like a client would
+func sendAndRead(t *testing.T, method string, build func(res *Response)) (*http.Response, string) {
+	t.Helper()
+	client, server := net.Pipe()
+	res := NewResponse(&server)
+	build(res)
+
+	done := make(chan struct{})
+	go func() {
+		res.Send()
+		server.Close()
+		close(done)
+	}()
+	defer func() { <-done }()
+
+	resp, err := http.ReadResponse(bufio.NewReader(client), &http.Request{Method: method})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestSendBodies(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("file contents"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		build         func(res *Response)
+		body          string
+		contentLength int64
+		chunked       bool
+	}{
+		{"written", func(res *Response) { res.Write("hello") }, "hello", 5, false},
+		{"empty", func(res *Response) {}, "", 0, false},
+		{"stale length from handler", func(res *Response) {
+			res.SetHeader("Content-Length", "999")
+			res.Write("hi")
+		}, "hi", 2, false},
+		{"stream", func(res *Response) {
+			res.SetBody(io.NopCloser(strings.NewReader("streamed body")))
+		}, "streamed body", -1, true},
+		{"file", func(res *Response) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.SetBody(f)
+		}, "file contents", 13, false},
+		{"no content", func(res *Response) {
+			res.SetStatus(204)
+			res.Write("ignored")
+		}, "", 0, false},
+	}
+	for _, tt := range tests {
+		resp, body := sendAndRead(t, "GET", tt.build)
+		if body != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.body)
+		}
+		if resp.ContentLength != tt.contentLength {
+			t.Errorf("%s: Content-Length = %d, want %d", tt.name, resp.ContentLength, tt.contentLength)
+		}
+		chunked := len(resp.TransferEncoding) > 0 && resp.TransferEncoding[0] == "chunked"
+		if chunked != tt.chunked {
+			t.Errorf("%s: chunked = %v, want %v", tt.name, chunked, tt.chunked)
+		}
+		if !resp.Close {
+			t.Errorf("%s: missing Connection: close", tt.name)
+		}
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSendClosesBody(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("data")}
+	sendAndRead(t, "GET", func(res *Response) { res.SetBody(body) })
+	if !body.closed {
+		t.Error("body not closed after sending")
+	}
+}
+
+func TestSendDropsInvalidCookies(t *testing.T) {
+	resp, _ := sendAndRead(t, "GET", func(res *Response) {
+		res.SetCookie(cookies.Cookie{Name: "ok", Value: "1"})
+		res.SetCookie(cookies.Cookie{Name: "__Host-sid", Value: "2"})
+		res.SetCookie(cookies.Cookie{Name: "bad", Value: "a;b"})
+	})
+	got := resp.Header.Values("Set-Cookie")
+	if len(got) != 1 || got[0] != "ok=1" {
+		t.Errorf("Set-Cookie = %q, want only ok=1", got)
+	}
+}
+
+func TestSendHead(t *testing.T) {
+	client, server := net.Pipe()
+	res := NewResponse(&server)
+	res.SetRequest(&Request{Method: "HEAD"})
+	res.Text(200, "hello")
+	go func() {
+		res.Send()
+		server.Close()
+	}()
+
+	raw, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the headers of the GET, without the body
+	head, body, _ := strings.Cut(string(raw), "\r\n\r\n")
+	if !strings.Contains(head, "Content-Length: 5") || body != "" {
+		t.Errorf("HEAD response = %q", raw)
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+	Sessions
+
+	- the session middleware (middlewares.Session) loads the session of the request
+	  from its store and attaches it to the request
+	- handlers read and change it through req.Session()
+	- after the handler the middleware saves it and sets the session cookie
+	- flash messages added in one request are readable in the next one, and only there
+*/
+
+// Session holds the values of one client across requests
+// the exported fields are what the stores persist (as json)
+type Session struct {
+	ID        string
+	Values    map[string]any
+	CreatedAt time.Time // start of the session, for the absolute timeout
+	LastSeen  time.Time // last request of the session, for the idle timeout
+	// flash messages for the next request, use AddFlash to add them
+	Flash map[string][]any `json:",omitempty"`
+
+	mu         sync.Mutex
+	flashes    map[string][]any // flash messages of the previous request
+	modified   bool
+	regenerate bool
+	destroyed  bool
+	isNew      bool
+}
+
+// default key of flash messages
+const flashKey = "_flash"
+
+// NewSession
+// creates an empty session with the id
+// used by the session middleware when the request has no (valid) session
+func NewSession(id string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:        id,
+		Values:    map[string]any{},
+		CreatedAt: now,
+		LastSeen:  now,
+		isNew:     true,
+	}
+}
+
+// session.Get(key)
+// returns the value, nil if it is not set
+// values loaded from a store went through json: numbers are float64, structs are maps
+func (s *Session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Values[key]
+}
+
+// session.Set(key, value)
+// stores the value in the session
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Values == nil {
+		s.Values = map[string]any{}
+	}
+	s.Values[key] = value
+	s.modified = true
+}
+
+// session.Delete(key)
+// removes the value from the session
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Values[key]; ok {
+		delete(s.Values, key)
+		s.modified = true
+	}
+}
+
+// session.Clear()
+// removes all values, the session itself stays
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Values = map[string]any{}
+	s.Flash = nil
+	s.modified = true
+}
+
+// session.AddFlash(value, key...)
+// adds a flash message that is readable with Flashes in the next request
+//
+//	req.Session().AddFlash("Profile saved")
+//	res.Redirect("/profile", 303)
+func (s *Session) AddFlash(value any, key ...string) {
+	k := flashKey
+	if len(key) > 0 {
+		k = key[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Flash == nil {
+		s.Flash = map[string][]any{}
+	}
+	s.Flash[k] = append(s.Flash[k], value)
+	s.modified = true
+}
+
+// session.Flashes(key...)
+// returns the flash messages added by the previous request
+func (s *Session) Flashes(key ...string) []any {
+	k := flashKey
+	if len(key) > 0 {
+		k = key[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flashes[k]
+}
+
+// session.Regenerate()
+// gives the session a new id while keeping its values
+// call it whenever the privileges change, e.g. on login, to prevent session fixation
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regenerate = true
+	s.modified = true
+}
+
+// session.Destroy()
+// deletes the session from the store and the client, e.g. on logout
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Values = map[string]any{}
+	s.Flash = nil
+	s.destroyed = true
+}
+
+// session.IsNew()
+// reports whether the session was created by this request
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// session.Modified()
+// reports whether the session was changed and has to be saved
+func (s *Session) Modified() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modified
+}
+
+// session.Regenerated()
+// reports whether Regenerate was called
+func (s *Session) Regenerated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.regenerate
+}
+
+// session.Destroyed()
+// reports whether Destroy was called
+func (s *Session) Destroyed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.destroyed
+}
+
+// req.SetSession(session)
+// attaches the session to the request, done by the session middleware
+// the flash messages of the previous request become readable and are removed from the session
+func (r *Request) SetSession(s *Session) {
+	s.mu.Lock()
+	if len(s.Flash) > 0 {
+		s.flashes = s.Flash
+		s.Flash = nil
+		s.modified = true
+	}
+	s.mu.Unlock()
+	r.session = s
+}
+
+// req.Session()
+// returns the session of the request
+// nil unless the session middleware is used
+func (r *Request) Session() *Session {
+	return r.session
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	In memory cache for static files
+
+	- hot files are kept in memory instead of being read from disk on every hit
+	- least recently used files are evicted once the cache grows beyond its size
+	- an entry is reloaded as soon as the modtime or size of the file changes
+*/
+
+// FileCache is a size bounded LRU cache of file contents
+// exposed to the users as server.StaticCache
+// one cache can be shared by several static mounts
+// create it with NewFileCache, the zero value has no room and caches nothing
+type FileCache struct {
+	// files bigger than this are never cached
+	// defaults to 1/8 of the cache size
+	MaxFileSize int64
+
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	lru      *list.List // front is the most recently used entry
+	entries  map[string]*list.Element
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	modTime time.Time
+	size    int64
+}
+
+// CacheStats are the metrics of the cache
+type CacheStats struct {
+	Hits      int64 // files served from memory
+	Misses    int64 // files read from the file system
+	Evictions int64 // entries removed to make room for others
+	Entries   int   // files currently cached
+	Bytes     int64 // total size of the cached files
+}
+
+// NewFileCache
+// creates a cache holding at most maxBytes of file contents
+func NewFileCache(maxBytes int64) *FileCache {
+	return &FileCache{
+		MaxFileSize: maxBytes / 8,
+		maxBytes:    maxBytes,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+	}
+}
+
+// cache.Stats()
+// returns a snapshot of the cache metrics
+func (c *FileCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+	}
+}
+
+// load
+// returns the content of the file from the cache
+// or reads it from the file system and caches it
+// info is the current info of the file, used to detect changes
+func (c *FileCache) load(key string, fsys fs.FS, name string, info fs.FileInfo) (io.ReadSeeker, error) {
+	// too big to be cached, or a zero value cache without any room
+	if c.maxBytes <= 0 || info.Size() > c.MaxFileSize {
+		c.misses.Add(1)
+		file, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return seekable(file)
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			return bytes.NewReader(entry.data), nil
+		}
+		// file changed on disk, drop the stale entry
+		c.remove(el)
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another request may have cached the file in the meantime
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	el := c.lru.PushFront(&cacheEntry{
+		key:     key,
+		data:    data,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	})
+	c.entries[key] = el
+	c.bytes += int64(len(data))
+
+	for c.bytes > c.maxBytes && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// must be called with the lock held
+func (c *FileCache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.data))
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"io"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func loadCached(t *testing.T, c *FileCache, fsys fstest.MapFS, name string) string {
+	t.Helper()
+	info, err := fsys.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := c.load("/\x00"+name, fsys, name, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(content)
+	return string(b)
+}
+
+func TestFileCache(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":   {Data: []byte("aaaaaaaaaa"), ModTime: time.Unix(100, 0)},
+		"b.txt":   {Data: []byte("bbbbbbbbbb")},
+		"c.txt":   {Data: []byte("cccccccccc")},
+		"big.bin": {Data: make([]byte, 64)},
+	}
+	c := NewFileCache(25)
+	c.MaxFileSize = 20
+
+	loadCached(t, c, fsys, "a.txt")
+	loadCached(t, c, fsys, "a.txt")
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 || s.Entries != 1 || s.Bytes != 10 {
+		t.Errorf("after two loads: %+v", s)
+	}
+
+	// a changed file is read again
+	fsys["a.txt"] = &fstest.MapFile{Data: []byte("AAAAAAAAAA"), ModTime: time.Unix(200, 0)}
+	if got := loadCached(t, c, fsys, "a.txt"); got != "AAAAAAAAAA" {
+		t.Errorf("stale content %q after modification", got)
+	}
+	if s := c.Stats(); s.Misses != 2 || s.Entries != 1 {
+		t.Errorf("after modification: %+v", s)
+	}
+
+	// b is used, so a is the least recently used one when c pushes the cache over its size
+	loadCached(t, c, fsys, "b.txt")
+	loadCached(t, c, fsys, "a.txt")
+	loadCached(t, c, fsys, "c.txt")
+	if s := c.Stats(); s.Evictions != 1 || s.Entries != 2 || s.Bytes != 20 {
+		t.Errorf("after eviction: %+v", s)
+	}
+	if _, ok := c.entries["/\x00b.txt"]; ok {
+		t.Error("least recently used entry b.txt still cached")
+	}
+
+	// files over MaxFileSize bypass the cache
+	if got := loadCached(t, c, fsys, "big.bin"); len(got) != 64 {
+		t.Errorf("big file read %d bytes", len(got))
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Bytes != 20 {
+		t.Errorf("big file cached: %+v", s)
+	}
+}
+
+func TestFileCacheZeroValue(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty.txt": {Data: []byte{}},
+		"a.txt":     {Data: []byte("aaaaaaaaaa")},
+	}
+	var c FileCache
+	c.MaxFileSize = 1 << 20
+
+	// files are read from the file system, nothing is kept
+	for _, name := range []string{"empty.txt", "a.txt", "a.txt"} {
+		want := string(fsys[name].Data)
+		if got := loadCached(t, &c, fsys, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if s := c.Stats(); s.Hits != 0 || s.Misses != 3 || s.Entries != 0 || s.Bytes != 0 {
+		t.Errorf("zero value cache: %+v", s)
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":      {Data: []byte("plain")},
+		"app.js.br":   {Data: []byte("brotli")},
+		"app.js.gz":   {Data: []byte("gzipped")},
+		"site.css":    {Data: []byte("css")},
+		"site.css.gz": {Data: []byte("css gzipped")},
+	}
+	cache := NewFileCache(1 << 20)
+	handler := ServeFSHandler("/", fsys, Options{Precompressed: true, Cache: cache})
+
+	tests := []struct {
+		name, target, accept, encoding, body string
+	}{
+		{"brotli preferred", "/app.js", "gzip, br", "br", "brotli"},
+		{"gzip", "/app.js", "gzip", "gzip", "gzipped"},
+		{"brotli refused", "/app.js", "br;q=0, *", "gzip", "gzipped"},
+		{"no encoding", "/app.js", "", "", "plain"},
+		{"only gzip sibling", "/site.css", "br, gzip", "gzip", "css gzipped"},
+	}
+	for _, tt := range tests {
+		res := serveFSRequest(handler, "GET", tt.target, map[string]string{"Accept-Encoding": tt.accept})
+		if got := res.GetHeader("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.encoding)
+		}
+		if got := readBody(t, res); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+		if res.GetHeader("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q", tt.name, res.GetHeader("Vary"))
+		}
+		if ct := res.GetHeader("Content-Type"); ct != "text/javascript; charset=utf-8" && ct != "text/css; charset=utf-8" {
+			t.Errorf("%s: Content-Type of the sibling: %q", tt.name, ct)
+		}
+	}
+
+	if s := cache.Stats(); s.Hits == 0 {
+		t.Errorf("cache never hit: %+v", s)
+	}
+}
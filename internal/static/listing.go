@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"squirrel/core"
+	"strings"
+	"time"
+)
+
+// entry of a directory listing
+type dirEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+var listingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writes the listing of the directory to the response
+// directories first, then files, both sorted by name
+func writeListing(req *core.Request, res *core.Response, fsys fs.FS, name string, o Options) {
+	items, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		writeFSError(res, err)
+		return
+	}
+
+	entries := make([]dirEntry, 0, len(items))
+	for _, item := range items {
+		if !o.AllowDotfiles && strings.HasPrefix(item.Name(), ".") {
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, dirEntry{
+			Name:    item.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   item.IsDir(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	// html unless the client prefers json, caches have to keep both apart
+	res.AddHeader("Vary", "Accept")
+	if core.NegotiateContentType(req.GetHeader("Accept"), []string{"text/html", "application/json"}) == "application/json" {
+		b, err := json.Marshal(entries)
+		if err != nil {
+			res.SetStatus(500)
+			res.Write("Error Listing Directory")
+			return
+		}
+		res.SetHeader("Content-Type", "application/json")
+		res.WriteBytes(b)
+		return
+	}
+
+	var b strings.Builder
+	data := struct {
+		Path    string
+		Entries []dirEntry
+	}{
+		Path:    path.Clean(req.Url.Path),
+		Entries: entries,
+	}
+	if err := listingTemplate.Execute(&b, data); err != nil {
+		res.SetStatus(500)
+		res.Write("Error Listing Directory")
+		return
+	}
+	res.SetHeader("Content-Type", "text/html; charset=utf-8")
+	res.Write(b.String())
+}
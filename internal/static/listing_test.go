@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"squirrel/core"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var dirFS = fstest.MapFS{
+	"docs/index.htm":  {Data: []byte("docs home")},
+	"files/b.txt":     {Data: []byte("bbb")},
+	"files/a.txt":     {Data: []byte("a")},
+	"files/sub/c.txt": {Data: []byte("c")},
+	"files/.env":      {Data: []byte("SECRET=1")},
+	".git/config":     {Data: []byte("[core]")},
+}
+
+func TestServeDirectories(t *testing.T) {
+	handler := ServeFSHandler("/static", dirFS, Options{Index: "index.htm", Browse: true})
+
+	tests := []struct {
+		name     string
+		target   string
+		status   int
+		location string
+		contains []string
+		missing  []string
+	}{
+		{"redirect to slash", "/static/docs", 301, "/static/docs/", nil, nil},
+		{"redirect keeps query", "/static/docs?lang=en", 301, "/static/docs/?lang=en", nil, nil},
+		{"index file", "/static/docs/", 200, "", []string{"docs home"}, nil},
+		{"listing", "/static/files/", 200, "", []string{
+			"Index of /static/files", `href="../"`, `href="sub/"`, `href="a.txt"`, `href="b.txt"`,
+		}, []string{".env"}},
+		{"dotfile", "/static/files/.env", 404, "", nil, nil},
+		{"dot directory", "/static/.git/config", 404, "", nil, nil},
+	}
+	for _, tt := range tests {
+		res := serveFS(handler, "GET", tt.target)
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+		if got := res.GetHeader("Location"); got != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.name, got, tt.location)
+		}
+		body := readBody(t, res)
+		for _, s := range tt.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%s: body misses %q", tt.name, s)
+			}
+		}
+		for _, s := range tt.missing {
+			if strings.Contains(body, s) {
+				t.Errorf("%s: body contains %q", tt.name, s)
+			}
+		}
+	}
+
+	// directories come first, then files, sorted by name
+	res := serveFS(handler, "GET", "/static/files/")
+	body := readBody(t, res)
+	if !(strings.Index(body, "sub/") < strings.Index(body, "a.txt") && strings.Index(body, "a.txt") < strings.Index(body, "b.txt")) {
+		t.Errorf("listing order wrong:\n%s", body)
+	}
+}
+
+func TestRedirectToSlash(t *testing.T) {
+	// "//evil.com/" in Location would send the browser to another host
+	req := &core.Request{Method: "GET", Path: "/evil.com", Url: &url.URL{Path: "//evil.com"}}
+	var conn net.Conn
+	res := core.NewResponse(&conn)
+	redirectToSlash(req, res)
+
+	if got := res.GetHeader("Location"); got != "/evil.com/" {
+		t.Errorf("Location = %q, want /evil.com/", got)
+	}
+}
+
+func TestServeDirectoryOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		target string
+		status int
+	}{
+		{"browse disabled", Options{}, "/files/", 404},
+		{"default index missing", Options{Browse: false}, "/docs/", 404},
+		{"dotfiles allowed", Options{AllowDotfiles: true}, "/files/.env", 200},
+	}
+	for _, tt := range tests {
+		res := serveFS(ServeFSHandler("/", dirFS, tt.opts), "GET", tt.target)
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+	}
+}
+
+func TestServeDirectoryJSON(t *testing.T) {
+	handler := ServeFSHandler("/", dirFS, Options{Browse: true, AllowDotfiles: true})
+	res := serveFSRequest(handler, "GET", "/files/", map[string]string{"Accept": "application/json"})
+
+	if res.GetHeader("Content-Type") != "application/json" {
+		t.Fatalf("Content-Type = %q", res.GetHeader("Content-Type"))
+	}
+	var entries []dirEntry
+	if err := json.Unmarshal([]byte(readBody(t, res)), &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	if got := strings.Join(names, ","); got != "sub,.env,a.txt,b.txt" {
+		t.Errorf("entries = %s, want sub,.env,a.txt,b.txt", got)
+	}
+	if entries[3].Size != 3 || !entries[0].IsDir {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestServeDirectoryNegotiation(t *testing.T) {
+	handler := ServeFSHandler("/", dirFS, Options{Browse: true})
+
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"application/json", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8"},
+		// json named with a lower weight doesn't win
+		{"text/html, application/json;q=0.5", "text/html; charset=utf-8"},
+		{"application/json, text/html;q=0.5", "application/json"},
+		{"", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		res := serveFSRequest(handler, "GET", "/files/", map[string]string{"Accept": tt.accept})
+		if got := res.GetHeader("Content-Type"); got != tt.contentType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if got := res.GetHeader("Vary"); got != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, got)
+		}
+	}
+}
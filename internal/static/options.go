@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"path"
+	"squirrel/core"
+	"strings"
+)
+
+// Options configures how the static files are served
+// exposed to the users as server.StaticOptions
+type Options struct {
+	// index file served for directories, defaults to "index.html"
+	Index string
+	// render a listing of directories without index file
+	// html by default, json if the client accepts application/json
+	Browse bool
+	// serve files and directories starting with "." (.env, .git, ...)
+	// they are answered with 404 by default
+	AllowDotfiles bool
+
+	// cache policies for the served files, first matching rule wins
+	// patterns are matched against the url path of the file
+	//
+	//	CacheRules: []core.CacheRule{
+	//		{Fingerprinted: true, Policy: core.CacheImmutable},
+	//		{Pattern: "*.html", Policy: core.CacheNoStore},
+	//		{Policy: core.CachePolicy{Public: true, MaxAge: time.Hour, StaleWhileRevalidate: time.Minute}},
+	//	}
+	CacheRules []core.CacheRule
+
+	// serve precompressed siblings (app.js.br, app.js.gz) of the requested file
+	// when the client accepts the encoding, brotli is preferred over gzip
+	Precompressed bool
+	// keep hot files in memory, nil means every hit reads the file system
+	// server.NewStaticCache(64 << 20)
+	Cache *FileCache
+
+	// SPA mode for single page applications
+	// unknown paths are answered with the Fallback file instead of 404
+	// so the client side router can take over
+	SPA bool
+	// file served for unknown paths in SPA mode, defaults to "index.html"
+	Fallback string
+	// request paths that never fall back to the html shell, e.g. "/app/api/*"
+	// patterns as in core.MatchPath: "/*" matches everything below,
+	// patterns without "/" match the file name, others use path.Match
+	Exclude []string
+	// paths whose last segment has an extension (/app/logo.png) are real assets
+	// and return 404 when missing, unless FallbackWithExtension is set
+	FallbackWithExtension bool
+	// Cache-Control of the html shell in SPA mode, defaults to "no-cache"
+	// so new deployments are picked up right away
+	ShellCacheControl string
+	// Cache-Control of fingerprinted assets (app.3f9a2c1b.js) in SPA mode
+	// defaults to "public, max-age=31536000, immutable"
+	AssetCacheControl string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Index == "" {
+		o.Index = "index.html"
+	}
+	if o.Fallback == "" {
+		o.Fallback = "index.html"
+	}
+	if o.ShellCacheControl == "" {
+		o.ShellCacheControl = "no-cache"
+	}
+	if o.AssetCacheControl == "" {
+		o.AssetCacheControl = "public, max-age=31536000, immutable"
+	}
+	return o
+}
+
+// checks if the request path may be answered with the fallback file
+func (o Options) canFallback(reqPath string) bool {
+	if !o.SPA {
+		return false
+	}
+
+	for _, pattern := range o.Exclude {
+		if core.MatchPath(pattern, reqPath) {
+			return false
+		}
+	}
+
+	if !o.FallbackWithExtension && path.Ext(reqPath) != "" {
+		return false
+	}
+	return true
+}
+
+// precompressed siblings in the order of preference
+var precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// checks if any segment of the name is a dotfile
+func isHidden(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return true
+		}
+	}
+	return false
+}
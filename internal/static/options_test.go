@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"squirrel/core"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestServeSPA(t *testing.T) {
+	spa := fstest.MapFS{
+		"index.html":             {Data: []byte("<div id=app></div>")},
+		"about.html":             {Data: []byte("about")},
+		"assets/app.3f9a2c1b.js": {Data: []byte("boot()")},
+		"assets/logo.png":        {Data: []byte("png")},
+	}
+	handler := ServeFSHandler("/app", spa, Options{
+		SPA:     true,
+		Exclude: []string{"/app/api/*"},
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"root", "/app/", 200, "<div id=app></div>", "no-cache"},
+		{"client route", "/app/users/7", 200, "<div id=app></div>", "no-cache"},
+		{"other html page", "/app/about.html", 200, "about", "no-cache"},
+		{"fingerprinted asset", "/app/assets/app.3f9a2c1b.js", 200, "boot()", "public, max-age=31536000, immutable"},
+		{"plain asset", "/app/assets/logo.png", 200, "png", ""},
+		{"missing asset", "/app/assets/missing.js", 404, "404 File Not Found", ""},
+		{"excluded path", "/app/api/users", 404, "404 File Not Found", ""},
+	}
+	for _, tt := range tests {
+		res := serveFS(handler, "GET", tt.path)
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+		if got := readBody(t, res); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+		if got := res.GetHeader("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, got, tt.cacheControl)
+		}
+	}
+
+	// missing files with an extension fall back only when asked to
+	handler = ServeFSHandler("/app", spa, Options{SPA: true, FallbackWithExtension: true})
+	if res := serveFS(handler, "GET", "/app/report.pdf"); res.GetStatusCode() != 200 {
+		t.Errorf("FallbackWithExtension: status = %d, want 200", res.GetStatusCode())
+	}
+
+	// without SPA mode unknown paths are 404
+	handler = ServeFSHandler("/app", spa)
+	if res := serveFS(handler, "GET", "/app/users/7"); res.GetStatusCode() != 404 {
+		t.Errorf("without SPA: status = %d, want 404", res.GetStatusCode())
+	}
+}
+
+func TestServeCacheRules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":             {Data: []byte("shell")},
+		"assets/app.3f9a2c1b.js": {Data: []byte("app")},
+		"robots.txt":             {Data: []byte("robots")},
+	}
+	handler := ServeFSHandler("/site", fsys, Options{
+		SPA: true,
+		CacheRules: []core.CacheRule{
+			{Pattern: "/site/robots.txt", Policy: core.CachePolicy{Public: true, MaxAge: time.Hour}},
+			{Pattern: "*.html", Policy: core.CacheNoStore},
+		},
+	})
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/site/robots.txt", "public, max-age=3600"},
+		{"/site/", "no-store"},
+		{"/site/assets/app.3f9a2c1b.js", "public, max-age=31536000, immutable"},
+	}
+	for _, tt := range tests {
+		res := serveFS(handler, "GET", tt.path)
+		if got := res.GetHeader("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
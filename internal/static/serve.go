@@ -1,12 +1,12 @@
 package internal
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"io"
-	"mime"
+	"io/fs"
 	"os"
 	"path"
-	"path/filepath"
 	"squirrel/core"
 	"strings"
 )
@@ -25,84 +25,209 @@ import (
 	- /assets will be the virtual route and the files/folder of the public
 	- directory would be the subpaths automatically
 
+	- the files are looked up through an fs.FS
+	- so the same handler serves a directory on disk (os.DirFS),
+	- files compiled into the binary (embed.FS) or any other file system
 
-*/
 
-func ServeStaticHandler(prefix string, dirpath string) core.HandlerFunc {
-	if prefix == "" {
-		prefix = "/"
-	}
+*/
 
+func ServeStaticHandler(prefix string, dirpath string, opts ...Options) core.HandlerFunc {
 	if dirpath == "" {
 		dirpath = "./public"
 	}
 
-	return func(req *core.Request, res *core.Response) {
+	return ServeFSHandler(prefix, os.DirFS(dirpath), opts...)
+}
 
-		if req.Method != "GET" && req.Method != "HEAD" {
-			// other method than GET and HEAD are not allowed
-			res.SetStatus(405)
-			res.SetHeader("Allow", "GET, HEAD")
-			res.SetHeader("Content-Length", "0")
-			res.Send()
-			return
-		}
+// ServeFSHandler
+// serves the files of fsys under the route prefix
+// works with embed.FS, os.DirFS, fstest.MapFS or any other fs.FS
+func ServeFSHandler(prefix string, fsys fs.FS, opts ...Options) core.HandlerFunc {
+	// "/assets/" is mounted like "/assets", the root mount "/" becomes ""
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o = o.withDefaults()
+
+	return func(req *core.Request, res *core.Response) {
 
 		// replace the route path with directory name
 		// /asset => "public"
 		// cuz  we are storing all the static files over here.
 		relPath := strings.TrimPrefix(req.Path, prefix)
 
-		// if only given, /assets || /assets
-		// setting the default path to index.html
-		if relPath == "" || relPath == "/" {
-			relPath = "index.html"
-		}
-
 		// get the file/folder that the path is trying to access
-		// we are cleaning the relPath to avoid
-		// directory traveral attack like: "../../../core"
-
-		fullpath := path.Join(dirpath, path.Clean(relPath))
-
-		// checking if the path is still inside the public directory or not
-		// preventing the directory traversal attack
-		absBase, _ := filepath.Abs(dirpath)
-		absTargetPath, _ := filepath.Abs(fullpath)
-
-		if !strings.HasPrefix(absTargetPath, absBase) {
+		// preventing the directory traversal attack like: "../../../core"
+		// fs.FS names are always relative to the root of the file system
+		// and must not contain any ".." element
+		name, ok := fsPath(relPath)
+		if !ok {
 			// ohh-ohh, trying to getout of you limit xD
 			res.SetStatus(403)
 			res.Write("403 Forbidden")
-			// res.Send()
 			return
 		}
 
-		file, err := os.Open(fullpath)
+		// .env, .git and friends are not served unless explicitly allowed
+		if !o.AllowDotfiles && isHidden(name) {
+			writeFSError(res, fs.ErrNotExist)
+			return
+		}
+
+		info, err := fs.Stat(fsys, name)
+
+		if err == nil && info.IsDir() {
+			// canonical url of a directory ends with a slash
+			// so relative links inside index.html resolve correctly
+			if !strings.HasSuffix(req.Url.Path, "/") {
+				redirectToSlash(req, res)
+				return
+			}
+
+			// directories are served through their index file
+			// or as a listing if browsing is enabled
+			index := path.Join(name, o.Index)
+			if indexInfo, indexErr := fs.Stat(fsys, index); indexErr == nil && !indexInfo.IsDir() {
+				name = index
+			} else if o.Browse {
+				writeListing(req, res, fsys, name, o)
+				return
+			} else {
+				err = fs.ErrNotExist
+			}
+		}
+
+		// single page applications: unknown routes are handled by the client side router
+		// so they get the html shell instead of 404
+		if errors.Is(err, fs.ErrNotExist) && o.canFallback(req.Path) {
+			name, err = o.Fallback, nil
+		}
+
 		if err != nil {
-			res.SetStatus(404)
-			res.Write("404 File Not Found")
-			res.Send()
+			writeFSError(res, err)
 			return
 		}
-		defer file.Close()
 
-		mimetype := mime.TypeByExtension(filepath.Ext(fullpath))
+		info, err = fs.Stat(fsys, name)
+		if err != nil {
+			writeFSError(res, err)
+			return
+		}
 
-		if mimetype != "" {
-			res.SetHeader("Content-Type", mimetype)
+		// the content type always comes from the requested file
+		// even if a precompressed sibling is served
+		servedName, servedInfo := name, info
+		if o.Precompressed {
+			res.AddHeader("Vary", "Accept-Encoding")
+			acceptEncoding := req.GetHeader("Accept-Encoding")
+			for _, pc := range precompressed {
+				if core.AcceptsEncoding(acceptEncoding, pc.encoding) <= 0 {
+					continue
+				}
+				if sibling, err := fs.Stat(fsys, name+pc.ext); err == nil && !sibling.IsDir() {
+					servedName, servedInfo = name+pc.ext, sibling
+					res.SetHeader("Content-Encoding", pc.encoding)
+					break
+				}
+			}
 		}
 
-		body, err := io.ReadAll(file)
+		var content io.ReadSeeker
+		if o.Cache != nil {
+			content, err = o.Cache.load(prefix+"\x00"+servedName, fsys, servedName, servedInfo)
+		} else {
+			content, err = openFile(fsys, servedName)
+		}
 		if err != nil {
-			res.SetStatus(500)
-			res.Write("Error Parsing Body")
-			res.Send()
+			res.DelHeader("Content-Encoding")
+			writeFSError(res, err)
 			return
 		}
 
-		res.SetHeader("Content-Length", fmt.Sprint(len(body)))
-		res.WriteBytes(body)
-		res.Send()
+		// cache rules of the mount come first, SPA defaults otherwise
+		if policy, ok := core.MatchCacheRule(o.CacheRules, path.Join("/", prefix, name)); ok {
+			res.SetCachePolicy(policy)
+		} else if o.SPA {
+			if name == o.Fallback || path.Ext(name) == ".html" {
+				res.SetHeader("Cache-Control", o.ShellCacheControl)
+			} else if core.IsFingerprinted(name) {
+				res.SetHeader("Cache-Control", o.AssetCacheControl)
+			}
+		}
+
+		// the file is closed by the response once it was sent
+		// ServeContent takes care of Content-Type, Last-Modified and Range requests
+		res.ServeContent(info.Name(), servedInfo.ModTime(), content)
+	}
+}
+
+// redirects /assets/docs to /assets/docs/ keeping the query
+func redirectToSlash(req *core.Request, res *core.Response) {
+	// "//evil.com" would be a protocol relative url to another host
+	location := "/" + strings.TrimLeft(req.Url.Path, "/") + "/"
+	if req.Url.RawQuery != "" {
+		location += "?" + req.Url.RawQuery
+	}
+	res.SetStatus(301)
+	res.SetHeader("Location", location)
+}
+
+// opens the file and returns its seekable content
+func openFile(fsys fs.FS, name string) (io.ReadSeeker, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekable(file)
+}
+
+// fsPath
+// converts the url path into a name valid for fs.FS
+// returns false if the path tries to get out of the root
+func fsPath(relPath string) (string, bool) {
+	for _, segment := range strings.Split(relPath, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+relPath), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+// files of os.DirFS and embed.FS can seek, others are read into memory
+// the returned value closes the file when it is closed
+func seekable(file fs.File) (io.ReadSeeker, error) {
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	defer file.Close()
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(body), nil
+}
+
+// maps errors of the file system to the response
+func writeFSError(res *core.Response, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		res.SetStatus(404)
+		res.Write("404 File Not Found")
+	case errors.Is(err, fs.ErrPermission):
+		res.SetStatus(403)
+		res.Write("403 Forbidden")
+	default:
+		res.SetStatus(500)
+		res.Write("Error Reading File")
 	}
 }
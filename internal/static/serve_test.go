@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"path"
+	"squirrel/core"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testFS = fstest.MapFS{
+	"index.html":   {Data: []byte("<h1>home</h1>"), ModTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	"css/site.css": {Data: []byte("body{}")},
+}
+
+func TestFSPath(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    string
+		ok      bool
+	}{
+		{"index.html", "index.html", true},
+		{"/css/site.css", "css/site.css", true},
+		{"css//site.css", "css/site.css", true},
+		{"./css/site.css", "css/site.css", true},
+		{"/", ".", true},
+		{"../etc/passwd", "", false},
+		{"/css/../../etc/passwd", "", false},
+		{"css/..", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := fsPath(tt.relPath)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("fsPath(%q) = %q, %v, want %q, %v", tt.relPath, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestServeFSHandler(t *testing.T) {
+	handler := ServeFSHandler("/assets", testFS)
+
+	tests := []struct {
+		path        string
+		status      int
+		body        string
+		contentType string
+	}{
+		{"/assets", 301, "", ""},
+		{"/assets/", 200, "<h1>home</h1>", "text/html; charset=utf-8"},
+		{"/assets/css/site.css", 200, "body{}", "text/css; charset=utf-8"},
+		{"/assets/missing.js", 404, "404 File Not Found", ""},
+		// cleaned to /server.go, which is outside of the mount
+		{"/assets/../server.go", 404, "404 File Not Found", ""},
+	}
+	for _, tt := range tests {
+		res := serveFS(handler, "GET", tt.path)
+
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, res.GetStatusCode(), tt.status)
+		}
+		if got := readBody(t, res); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.path, got, tt.body)
+		}
+		if tt.contentType != "" && res.GetHeader("Content-Type") != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.path, res.GetHeader("Content-Type"), tt.contentType)
+		}
+	}
+
+	res := serveFS(handler, "GET", "/assets/index.html")
+	if res.GetHeader("Last-Modified") != "Fri, 01 Mar 2024 12:00:00 GMT" || res.GetHeader("Accept-Ranges") != "bytes" {
+		t.Errorf("index.html headers: Last-Modified %q, Accept-Ranges %q", res.GetHeader("Last-Modified"), res.GetHeader("Accept-Ranges"))
+	}
+}
+
+// file systems whose files can't seek are read into memory
+type plainFS struct{ fs.FS }
+
+type plainFile struct{ fs.File }
+
+func (p plainFS) Open(name string) (fs.File, error) {
+	f, err := p.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return plainFile{f}, nil
+}
+
+func TestServeFSHandlerNotSeekable(t *testing.T) {
+	res := serveFS(ServeFSHandler("/", plainFS{testFS}), "GET", "/css/site.css")
+	if got := readBody(t, res); res.GetStatusCode() != 200 || got != "body{}" {
+		t.Errorf("status = %d, body = %q", res.GetStatusCode(), got)
+	}
+}
+
+func serveFS(handler core.HandlerFunc, method, target string) *core.Response {
+	return serveFSRequest(handler, method, target, map[string]string{})
+}
+
+// request as ParseRequest builds it: req.Path is cleaned, req.Url keeps the raw target
+func serveFSRequest(handler core.HandlerFunc, method, target string, headers map[string]string) *core.Response {
+	u, _ := url.Parse(target)
+	var conn net.Conn
+	res := core.NewResponse(&conn)
+	handler(&core.Request{Method: method, Path: path.Clean(u.Path), Url: u, Headers: headers}, res)
+	return res
+}
+
+func readBody(t *testing.T, res *core.Response) string {
+	t.Helper()
+	if res.GetBody() == nil {
+		return ""
+	}
+	b, err := io.ReadAll(res.GetBody())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
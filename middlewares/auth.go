@@ -0,0 +1,301 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"squirrel/auth"
+	"squirrel/core"
+	"strings"
+)
+
+/*
+	Auth middlewares
+
+	- BasicAuth:  user name and password in the Authorization header (RFC 7617)
+	- BearerAuth: opaque tokens checked by your callback, e.g. against a database
+	- JWT:        signed tokens checked by an auth.Verifier
+
+	the authenticated client is put into the request locals as *auth.Principal:
+	auth.FromRequest(req), req.Get("user") or {{.user}} in templates
+	requests without valid credentials get 401 with a WWW-Authenticate challenge
+	CORS preflights pass untouched, browsers send them without credentials
+
+	server.Use(middlewares.BasicAuth(map[string]string{"admin": "secret"}))
+*/
+
+// ErrUnauthorized is the error for requests without valid credentials
+var ErrUnauthorized = core.NewHTTPError(401, "Unauthorized")
+
+// BasicAuthConfig configures the basic auth middleware
+type BasicAuthConfig struct {
+	// realm shown by browsers in the login prompt, "Restricted" by default
+	Realm string
+	// user names and their passwords
+	Users map[string]string
+	// checks credentials not found in Users and returns the principal
+	// a nil principal means invalid credentials
+	Validate func(user, password string) (*auth.Principal, error)
+	// let requests without credentials through as anonymous
+	Optional bool
+}
+
+// BasicAuth
+// allows the users with their passwords
+// server.Use(middlewares.BasicAuth(map[string]string{"admin": "secret"}))
+func BasicAuth(users map[string]string) func(core.HandlerFunc) core.HandlerFunc {
+	return BasicAuthWithConfig(BasicAuthConfig{Users: users})
+}
+
+// BasicAuthWithConfig
+// returns the basic auth middleware for the given config
+//
+//	server.Use(middlewares.BasicAuthWithConfig(middlewares.BasicAuthConfig{
+//		Realm: "Admin",
+//		Validate: func(user, password string) (*auth.Principal, error) {
+//			return db.CheckPassword(user, password)
+//		},
+//	}))
+func BasicAuthWithConfig(cfg BasicAuthConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Realm == "" {
+		cfg.Realm = "Restricted"
+	}
+	challenge := `Basic realm="` + escapeQuotes(cfg.Realm) + `", charset="UTF-8"`
+
+	// hashed, so comparing takes the same time whatever the length of the password
+	hashed := map[string][32]byte{}
+	for user, password := range cfg.Users {
+		hashed[user] = sha256.Sum256([]byte(password))
+	}
+
+	check := func(user, password string) (*auth.Principal, error) {
+		sum := sha256.Sum256([]byte(password))
+		if expected, ok := hashed[user]; ok {
+			if subtle.ConstantTimeCompare(sum[:], expected[:]) == 1 {
+				return &auth.Principal{ID: user}, nil
+			}
+			return nil, nil
+		}
+		if cfg.Validate != nil {
+			return cfg.Validate(user, password)
+		}
+		return nil, nil
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			if req.IsPreflight() {
+				next(req, res)
+				return
+			}
+			credentials, ok := authorization(req, "Basic")
+			if !ok {
+				unauthorized(cfg.Optional, challenge, next, req, res)
+				return
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(credentials)
+			user, password, found := strings.Cut(string(decoded), ":")
+			if err != nil || !found {
+				deny(challenge, res, nil)
+				return
+			}
+
+			principal, err := check(user, password)
+			if err != nil || principal == nil {
+				deny(challenge, res, err)
+				return
+			}
+			principal.Method = "basic"
+			auth.SetPrincipal(req, principal)
+			next(req, res)
+		}
+	}
+}
+
+// BearerAuthConfig configures the bearer token middleware
+type BearerAuthConfig struct {
+	// realm of the WWW-Authenticate challenge, "api" by default
+	Realm string
+	// checks the token and returns the principal, nil for invalid tokens
+	Validate func(token string) (*auth.Principal, error)
+	// let requests without a token through as anonymous
+	Optional bool
+}
+
+// BearerAuth
+// checks opaque tokens of the Authorization: Bearer header with validate
+//
+//	server.Use(middlewares.BearerAuth(func(token string) (*auth.Principal, error) {
+//		return db.FindToken(token)
+//	}))
+func BearerAuth(validate func(token string) (*auth.Principal, error)) func(core.HandlerFunc) core.HandlerFunc {
+	return BearerAuthWithConfig(BearerAuthConfig{Validate: validate})
+}
+
+// BearerAuthWithConfig
+// returns the bearer token middleware for the given config
+func BearerAuthWithConfig(cfg BearerAuthConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Validate == nil {
+		panic("middlewares: BearerAuthConfig.Validate is required")
+	}
+	if cfg.Realm == "" {
+		cfg.Realm = "api"
+	}
+	challenge := `Bearer realm="` + escapeQuotes(cfg.Realm) + `"`
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			if req.IsPreflight() {
+				next(req, res)
+				return
+			}
+			token, ok := authorization(req, "Bearer")
+			if !ok {
+				unauthorized(cfg.Optional, challenge, next, req, res)
+				return
+			}
+
+			principal, err := cfg.Validate(token)
+			if err != nil || principal == nil {
+				deny(challenge+`, error="invalid_token"`, res, err)
+				return
+			}
+			principal.Method = "bearer"
+			auth.SetPrincipal(req, principal)
+			next(req, res)
+		}
+	}
+}
+
+// JWTConfig configures the jwt middleware
+type JWTConfig struct {
+	// checks signature, expiry, issuer and audience of the tokens
+	Verifier *auth.Verifier
+	// realm of the WWW-Authenticate challenge, "api" by default
+	Realm string
+	// builds the principal from the claims
+	// defaults to sub as ID, "roles" as Roles, "permissions" as Permissions and "scope" / "scp" as Scopes
+	Principal func(claims auth.Claims) (*auth.Principal, error)
+	// let requests without a token through as anonymous
+	Optional bool
+}
+
+// JWT
+// authenticates requests with tokens of the Authorization: Bearer header
+// server.Use(middlewares.JWT(&auth.Verifier{Keys: auth.SecretKey(secret)}))
+func JWT(verifier *auth.Verifier) func(core.HandlerFunc) core.HandlerFunc {
+	return JWTWithConfig(JWTConfig{Verifier: verifier})
+}
+
+// JWTWithConfig
+// returns the jwt middleware for the given config
+func JWTWithConfig(cfg JWTConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Verifier == nil || cfg.Verifier.Keys == nil {
+		panic("middlewares: JWTConfig.Verifier with Keys is required")
+	}
+	if cfg.Realm == "" {
+		cfg.Realm = "api"
+	}
+	if cfg.Principal == nil {
+		cfg.Principal = claimsPrincipal
+	}
+	challenge := `Bearer realm="` + escapeQuotes(cfg.Realm) + `"`
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			if req.IsPreflight() {
+				next(req, res)
+				return
+			}
+			token, ok := authorization(req, "Bearer")
+			if !ok {
+				unauthorized(cfg.Optional, challenge, next, req, res)
+				return
+			}
+
+			claims, err := cfg.Verifier.Verify(token)
+			if err != nil {
+				deny(challenge+`, error="invalid_token"`+jwtErrorDescription(err), res, err)
+				return
+			}
+
+			principal, err := cfg.Principal(claims)
+			if err != nil || principal == nil {
+				deny(challenge+`, error="invalid_token"`, res, err)
+				return
+			}
+			principal.Method = "jwt"
+			principal.Claims = claims
+			auth.SetPrincipal(req, principal)
+			next(req, res)
+		}
+	}
+}
+
+func claimsPrincipal(claims auth.Claims) (*auth.Principal, error) {
+	scopes := claims.Strings("scope")
+	if scopes == nil {
+		scopes = claims.Strings("scp")
+	}
+	return &auth.Principal{
+		ID:          claims.Subject(),
+		Roles:       claims.Strings("roles"),
+		Permissions: claims.Strings("permissions"),
+		Scopes:      scopes,
+	}, nil
+}
+
+// only the well known reasons are told to the client, not key fetching errors
+func jwtErrorDescription(err error) string {
+	for _, known := range []error{
+		auth.ErrTokenExpired, auth.ErrTokenNotValidYet, auth.ErrInvalidSignature,
+		auth.ErrInvalidIssuer, auth.ErrInvalidAudience, auth.ErrMalformedToken,
+		auth.ErrUnsupportedAlg, auth.ErrUnknownKey,
+	} {
+		if errors.Is(err, known) {
+			return `, error_description="` + strings.TrimPrefix(known.Error(), "auth: ") + `"`
+		}
+	}
+	return ""
+}
+
+// returns the credentials of the Authorization header with the given scheme
+func authorization(req *core.Request, scheme string) (string, bool) {
+	header := req.GetHeader("Authorization")
+	if len(header) <= len(scheme)+1 || !strings.EqualFold(header[:len(scheme)], scheme) || header[len(scheme)] != ' ' {
+		return "", false
+	}
+	credentials := strings.TrimSpace(header[len(scheme)+1:])
+	return credentials, credentials != ""
+}
+
+// no credentials: anonymous if optional, otherwise challenge the client
+func unauthorized(optional bool, challenge string, next core.HandlerFunc, req *core.Request, res *core.Response) {
+	if optional {
+		next(req, res)
+		return
+	}
+	deny(challenge, res, nil)
+}
+
+// invalid credentials, err is kept for the error handler but never shown to the client
+func deny(challenge string, res *core.Response, err error) {
+	res.SetHeader("WWW-Authenticate", challenge)
+	if err != nil {
+		var httpErr *core.HTTPError
+		if errors.As(err, &httpErr) {
+			res.SetError(err)
+			return
+		}
+		// a copy, WithInternal changes the error
+		res.SetError(core.NewHTTPError(ErrUnauthorized.Code, ErrUnauthorized.Message).WithInternal(err))
+		return
+	}
+	res.SetError(ErrUnauthorized)
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"encoding/base64"
+	"errors"
+	"squirrel/auth"
+	"squirrel/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runs the request through the middleware, returns the response and the principal the handler saw
+func runAuth(mw func(core.HandlerFunc) core.HandlerFunc, authorization string) (*core.Response, *auth.Principal, bool) {
+	headers := map[string]string{}
+	if authorization != "" {
+		headers["Authorization"] = authorization
+	}
+	var principal *auth.Principal
+	called := false
+	res := testResponse()
+	mw(func(req *core.Request, res *core.Response) {
+		called = true
+		principal = auth.FromRequest(req)
+	})(testRequest("GET", "/admin", headers), res)
+	return res, principal, called
+}
+
+func basic(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func TestBasicAuth(t *testing.T) {
+	strict := BasicAuthWithConfig(BasicAuthConfig{
+		Realm: `Admin "area"`,
+		Users: map[string]string{"ada": "lovelace"},
+		Validate: func(user, password string) (*auth.Principal, error) {
+			if user == "grace" && password == "hopper" {
+				return &auth.Principal{ID: "grace"}, nil
+			}
+			return nil, nil
+		},
+	})
+	optional := BasicAuthWithConfig(BasicAuthConfig{Users: map[string]string{"ada": "lovelace"}, Optional: true})
+
+	tests := []struct {
+		name          string
+		mw            func(core.HandlerFunc) core.HandlerFunc
+		authorization string
+		user          string // principal seen by the handler, "-" if not called
+	}{
+		{"listed user", strict, basic("ada", "lovelace"), "ada"},
+		{"scheme ignores case", strict, "basic " + base64.StdEncoding.EncodeToString([]byte("ada:lovelace")), "ada"},
+		{"validate callback", strict, basic("grace", "hopper"), "grace"},
+		{"wrong password", strict, basic("ada", "babbage"), "-"},
+		{"listed user doesn't fall back to validate", strict, basic("ada", "hopper"), "-"},
+		{"no colon", strict, "Basic " + base64.StdEncoding.EncodeToString([]byte("ada")), "-"},
+		{"not base64", strict, "Basic %%%", "-"},
+		{"other scheme", strict, "Bearer token", "-"},
+		{"missing", strict, "", "-"},
+		{"optional anonymous", optional, "", ""},
+		{"optional still checks", optional, basic("ada", "babbage"), "-"},
+	}
+	for _, tt := range tests {
+		res, principal, called := runAuth(tt.mw, tt.authorization)
+		got := "-"
+		if called {
+			got = ""
+			if principal != nil {
+				got = principal.ID
+			}
+		}
+		if got != tt.user {
+			t.Errorf("%s: principal = %q, want %q", tt.name, got, tt.user)
+		}
+		if !called {
+			if !errors.Is(res.GetError(), ErrUnauthorized) {
+				t.Errorf("%s: error = %v, want %v", tt.name, res.GetError(), ErrUnauthorized)
+			}
+			if !strings.HasPrefix(res.GetHeader("WWW-Authenticate"), "Basic realm=") {
+				t.Errorf("%s: WWW-Authenticate = %q", tt.name, res.GetHeader("WWW-Authenticate"))
+			}
+		}
+	}
+
+	res, _, _ := runAuth(strict, "")
+	if got, want := res.GetHeader("WWW-Authenticate"), `Basic realm="Admin \"area\"", charset="UTF-8"`; got != want {
+		t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	mw := BearerAuth(func(token string) (*auth.Principal, error) {
+		switch token {
+		case "good":
+			return &auth.Principal{ID: "bot"}, nil
+		case "banned":
+			return nil, core.NewHTTPError(403, "Banned")
+		}
+		return nil, nil
+	})
+
+	tests := []struct {
+		name, authorization, challenge string
+		code                           int
+	}{
+		{"valid", "Bearer good", "", 0},
+		{"invalid", "Bearer bad", `Bearer realm="api", error="invalid_token"`, 401},
+		{"missing", "", `Bearer realm="api"`, 401},
+		{"http error from validate", "Bearer banned", `Bearer realm="api", error="invalid_token"`, 403},
+	}
+	for _, tt := range tests {
+		res, principal, called := runAuth(mw, tt.authorization)
+		code := 0
+		var httpErr *core.HTTPError
+		if errors.As(res.GetError(), &httpErr) {
+			code = httpErr.Code
+		}
+		if code != tt.code || res.GetHeader("WWW-Authenticate") != tt.challenge {
+			t.Errorf("%s: status %d, WWW-Authenticate %q, want %d, %q", tt.name, code, res.GetHeader("WWW-Authenticate"), tt.code, tt.challenge)
+		}
+		if tt.code == 0 && (!called || principal == nil || principal.ID != "bot" || principal.Method != "bearer") {
+			t.Errorf("%s: handler ran %v with principal %+v", tt.name, called, principal)
+		}
+	}
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	mw := JWT(&auth.Verifier{Keys: auth.SecretKey(secret)})
+	sign := func(claims auth.Claims) string {
+		token, err := auth.Sign(claims, "HS256", "", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name, authorization, challenge string
+	}{
+		{"valid", sign(auth.Claims{"sub": "ada", "roles": []string{"admin"}, "scope": "read write", "exp": now + 60}), ""},
+		{"expired", sign(auth.Claims{"sub": "ada", "exp": now - 60}), `Bearer realm="api", error="invalid_token", error_description="token expired"`},
+		{"other key", "Bearer " + func() string {
+			token, _ := auth.Sign(auth.Claims{"sub": "ada"}, "HS256", "", []byte("another secret of thirty-two by"))
+			return token
+		}(), `Bearer realm="api", error="invalid_token", error_description="invalid signature"`},
+		{"missing", "", `Bearer realm="api"`},
+	}
+	for _, tt := range tests {
+		res, principal, called := runAuth(mw, tt.authorization)
+		if got := res.GetHeader("WWW-Authenticate"); got != tt.challenge {
+			t.Errorf("%s: WWW-Authenticate = %q, want %q", tt.name, got, tt.challenge)
+		}
+		if called != (tt.challenge == "") {
+			t.Errorf("%s: handler ran %v", tt.name, called)
+		}
+		if called && (principal.ID != "ada" || !principal.HasRole("admin") || !principal.HasScope("write") || principal.Method != "jwt") {
+			t.Errorf("%s: principal = %+v", tt.name, principal)
+		}
+	}
+}
+
+func TestAuthSkipsPreflight(t *testing.T) {
+	preflight := map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"}
+	tests := []struct {
+		name string
+		mw   func(core.HandlerFunc) core.HandlerFunc
+	}{
+		{"basic", BasicAuth(map[string]string{"admin": "secret"})},
+		{"bearer", BearerAuth(func(token string) (*auth.Principal, error) { return nil, nil })},
+		{"jwt", JWT(&auth.Verifier{Keys: auth.SecretKey([]byte("secret"))})},
+		{"authorize", Authorize(auth.Roles("admin"))},
+	}
+	for _, tt := range tests {
+		called := false
+		res := testResponse()
+		tt.mw(func(req *core.Request, res *core.Response) { called = true })(testRequest("OPTIONS", "/admin", preflight), res)
+		if !called || res.GetError() != nil {
+			t.Errorf("%s: preflight called = %v, err = %v", tt.name, called, res.GetError())
+		}
+
+		// OPTIONS without the preflight headers is checked as usual
+		called = false
+		res = testResponse()
+		tt.mw(func(req *core.Request, res *core.Response) { called = true })(testRequest("OPTIONS", "/admin", nil), res)
+		if called {
+			t.Errorf("%s: plain OPTIONS reached the handler", tt.name)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"squirrel/auth"
+	"squirrel/core"
+	"time"
+)
+
+/*
+	Authorize middleware
+
+	- runs after an auth middleware put the principal into the request
+	- anonymous requests get 401 with a WWW-Authenticate challenge,
+	  principals denied by a policy (auth.ErrForbidden) get 403
+	- a core.HTTPError of a policy is answered as is, any other error
+	  (e.g. the database of an Owner lookup is down) is a 500 and no denial
+	- every denial is reported to the audit function, logged by default
+
+	server.Get("/admin", admin, middlewares.Authorize(auth.Roles("admin")))
+	routes and groups of the mux have a shortcut: server.Require(auth.Roles("admin"))
+*/
+
+// ErrForbidden is the error for principals denied by a policy
+var ErrForbidden = core.NewHTTPError(403, "Forbidden")
+
+// AuthorizeConfig configures the authorize middleware
+type AuthorizeConfig struct {
+	// every policy has to allow the request
+	Policies []auth.Policy
+	// WWW-Authenticate of anonymous requests, `Bearer realm="api"` by default
+	// e.g. `Basic realm="Admin", charset="UTF-8"` behind BasicAuth
+	Challenge string
+	// called for every denied request, logs the denial by default
+	Audit func(d auth.Denial)
+}
+
+// Authorize
+// requires an authenticated principal allowed by every policy
+func Authorize(policies ...auth.Policy) func(core.HandlerFunc) core.HandlerFunc {
+	return AuthorizeWithConfig(AuthorizeConfig{Policies: policies})
+}
+
+// AuthorizeWithConfig
+// returns the authorize middleware for the given config
+func AuthorizeWithConfig(cfg AuthorizeConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Challenge == "" {
+		cfg.Challenge = `Bearer realm="api"`
+	}
+	if cfg.Audit == nil {
+		cfg.Audit = LogDenial
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			// preflights carry no credentials, the actual request is checked
+			if req.IsPreflight() {
+				next(req, res)
+				return
+			}
+			principal := auth.FromRequest(req)
+			if principal == nil {
+				cfg.Audit(denial(req, nil, 401, errors.New("not authenticated")))
+				deny(cfg.Challenge, res, nil)
+				return
+			}
+
+			for _, policy := range cfg.Policies {
+				err := policy.Authorize(req, principal)
+				if err == nil {
+					continue
+				}
+
+				var httpErr *core.HTTPError
+				switch {
+				case errors.As(err, &httpErr):
+					if httpErr.Code < 500 {
+						cfg.Audit(denial(req, principal, httpErr.Code, err))
+					}
+					res.SetError(err)
+				case errors.Is(err, auth.ErrForbidden):
+					cfg.Audit(denial(req, principal, ErrForbidden.Code, err))
+					// a copy, WithInternal changes the error
+					res.SetError(core.NewHTTPError(ErrForbidden.Code, ErrForbidden.Message).WithInternal(err))
+				default:
+					// the policy couldn't decide, answered with 500 by the error handler
+					res.SetError(err)
+				}
+				return
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+// LogDenial
+// the default audit function, writes the denial to the standard logger
+func LogDenial(d auth.Denial) {
+	who := d.Principal
+	if who == "" {
+		who = "anonymous"
+	}
+	log.Printf("authz: denied %s %s for %s from %s (%d): %v", d.Method, d.Path, who, d.ClientIP, d.Status, d.Reason)
+}
+
+func denial(req *core.Request, p *auth.Principal, status int, reason error) auth.Denial {
+	d := auth.Denial{
+		Time:     time.Now(),
+		Method:   req.Method,
+		Path:     req.Path,
+		ClientIP: req.ClientIP(),
+		Status:   status,
+		Reason:   reason,
+	}
+	if p != nil {
+		d.Principal = p.ID
+	}
+	return d
+}
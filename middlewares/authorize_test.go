@@ -0,0 +1,99 @@
+package middlewares
+
+import (
+	"errors"
+	"squirrel/auth"
+	"squirrel/core"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	gone := core.NewHTTPError(404, "Not Found")
+	hidden := auth.PolicyFunc(func(req *core.Request, p *auth.Principal) error { return gone })
+	undecided := auth.PolicyFunc(func(req *core.Request, p *auth.Principal) error { return errors.New("db: connection refused") })
+	ada := &auth.Principal{ID: "ada", Roles: []string{"editor"}}
+
+	tests := []struct {
+		name      string
+		policies  []auth.Policy
+		principal *auth.Principal
+		code      int // 0 if the handler runs
+	}{
+		{"allowed", []auth.Policy{auth.Roles("editor")}, ada, 0},
+		{"no policies", nil, ada, 0},
+		{"anonymous", []auth.Policy{auth.Roles("editor")}, nil, 401},
+		{"anonymous without policies", nil, nil, 401},
+		{"denied", []auth.Policy{auth.Roles("editor"), auth.Roles("admin")}, ada, 403},
+		{"http error passes through", []auth.Policy{hidden}, ada, 404},
+		{"undecided policy", []auth.Policy{undecided}, ada, 500},
+	}
+	for _, tt := range tests {
+		var audited []auth.Denial
+		mw := AuthorizeWithConfig(AuthorizeConfig{
+			Policies: tt.policies,
+			Audit:    func(d auth.Denial) { audited = append(audited, d) },
+		})
+
+		req := testRequest("DELETE", "/posts/1", nil)
+		if tt.principal != nil {
+			auth.SetPrincipal(req, tt.principal)
+		}
+		called := false
+		res := testResponse()
+		mw(func(req *core.Request, res *core.Response) { called = true })(req, res)
+
+		code := 0
+		if res.GetError() != nil {
+			code = core.AsHTTPError(res.GetError()).Code
+		}
+		if code != tt.code || called != (tt.code == 0) {
+			t.Errorf("%s: status %d (handler ran %v), want %d", tt.name, code, called, tt.code)
+		}
+
+		if tt.code == 401 && res.GetHeader("WWW-Authenticate") != `Bearer realm="api"` {
+			t.Errorf("%s: WWW-Authenticate = %q", tt.name, res.GetHeader("WWW-Authenticate"))
+		}
+		// allowed and undecided requests are no denials
+		if tt.code == 0 || tt.code == 500 {
+			if len(audited) != 0 {
+				t.Errorf("%s: audited %+v", tt.name, audited)
+			}
+			continue
+		}
+		if len(audited) != 1 {
+			t.Errorf("%s: %d audit entries, want 1", tt.name, len(audited))
+			continue
+		}
+		d := audited[0]
+		if d.Status != tt.code || d.Method != "DELETE" || d.Path != "/posts/1" || d.Reason == nil {
+			t.Errorf("%s: audit entry = %+v", tt.name, d)
+		}
+		if tt.principal != nil && d.Principal != "ada" || tt.principal == nil && d.Principal != "" {
+			t.Errorf("%s: audit principal = %q", tt.name, d.Principal)
+		}
+	}
+}
+
+func TestAuthorizeKeepsErrForbidden(t *testing.T) {
+	// the internal reason is attached to a copy, never to the shared error
+	req := testRequest("GET", "/admin", nil)
+	auth.SetPrincipal(req, &auth.Principal{ID: "ada"})
+	res := testResponse()
+	AuthorizeWithConfig(AuthorizeConfig{Policies: []auth.Policy{auth.Roles("admin")}, Audit: func(auth.Denial) {}})(func(*core.Request, *core.Response) {})(req, res)
+
+	if ErrForbidden.Err != nil {
+		t.Errorf("ErrForbidden changed: internal error %v", ErrForbidden.Err)
+	}
+	if !errors.Is(res.GetError(), auth.ErrForbidden) {
+		t.Errorf("error = %v, want it to wrap auth.ErrForbidden", res.GetError())
+	}
+}
+
+func TestAuthorizeChallenge(t *testing.T) {
+	res := testResponse()
+	AuthorizeWithConfig(AuthorizeConfig{Challenge: `Basic realm="Admin"`, Audit: func(auth.Denial) {}})(
+		func(*core.Request, *core.Response) {})(testRequest("GET", "/admin", nil), res)
+	if got := res.GetHeader("WWW-Authenticate"); got != `Basic realm="Admin"` {
+		t.Errorf("WWW-Authenticate = %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"squirrel/core"
+)
+
+/*
+	Cache-Control middlewares
+
+	- CacheControl applies one policy, handy as route specific middleware
+	- CacheControlRules picks the policy by the request path, handy as global middleware
+	- handlers that set Cache-Control themselves always win
+	- error responses are never given a cache policy, also errors the mux turns into a status later
+*/
+
+// CacheControl applies the policy to the responses of the route
+// server.Get("/products", handler, middlewares.CacheControl(core.CachePolicy{Public: true, MaxAge: time.Minute}))
+func CacheControl(policy core.CachePolicy) func(core.HandlerFunc) core.HandlerFunc {
+	return CacheControlRules(core.CacheRule{Policy: policy})
+}
+
+// CacheControlRules applies the policy of the first rule matching the request path
+//
+//	server.Use(middlewares.CacheControlRules(
+//		core.CacheRule{Pattern: "/api/*", Policy: core.CacheNoStore},
+//		core.CacheRule{Policy: core.CacheRevalidate},
+//	))
+func CacheControlRules(rules ...core.CacheRule) func(core.HandlerFunc) core.HandlerFunc {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			next(req, res)
+
+			if res.Sent() || res.GetHeader("Cache-Control") != "" || res.GetStatusCode() >= 400 || res.GetError() != nil {
+				return
+			}
+
+			if policy, ok := core.MatchCacheRule(rules, req.Path); ok {
+				res.SetCachePolicy(policy)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"squirrel/core"
+	"testing"
+	"time"
+)
+
+func TestCacheControlRules(t *testing.T) {
+	mw := CacheControlRules(
+		core.CacheRule{Pattern: "/api/*", Policy: core.CacheNoStore},
+		core.CacheRule{Policy: core.CachePolicy{Public: true, MaxAge: time.Minute}},
+	)
+
+	tests := []struct {
+		name    string
+		path    string
+		handler core.HandlerFunc
+		want    string
+	}{
+		{"first rule", "/api/users", textHandler("[]", nil), "no-store"},
+		{"fallback rule", "/products", textHandler("[]", nil), "public, max-age=60"},
+		{"handler wins", "/products", textHandler("[]", map[string]string{"Cache-Control": "private"}), "private"},
+		{"error response", "/products", func(req *core.Request, res *core.Response) {
+			res.SetStatus(404)
+		}, ""},
+		{"recorded error", "/products", func(req *core.Request, res *core.Response) {
+			res.SetError(core.NewHTTPError(500))
+		}, ""},
+	}
+	for _, tt := range tests {
+		res := testResponse()
+		mw(tt.handler)(testRequest("GET", tt.path, nil), res)
+		if got := res.GetHeader("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	res := testResponse()
+	CacheControl(core.CacheImmutable)(textHandler("x", nil))(testRequest("GET", "/anything", nil), res)
+	if got := res.GetHeader("Cache-Control"); got != core.CacheImmutable.String() {
+		t.Errorf("CacheControl: Cache-Control = %q", got)
+	}
+}
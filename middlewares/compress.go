@@ -0,0 +1,277 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"squirrel/core"
+	"strconv"
+	"strings"
+)
+
+/*
+	Compress middleware
+
+	- the handler runs first and writes the response as usual
+	- afterwards the body is compressed with the encoding the client prefers
+	- negotiated from the Accept-Encoding header (gzip or deflate, with q-values)
+
+	server.Use(middlewares.Compress)
+*/
+
+// CompressConfig configures the compression middleware
+type CompressConfig struct {
+	// compression level for gzip / deflate
+	// 0 means flate.DefaultCompression
+	Level int
+	// bodies smaller than MinLength bytes are sent as they are
+	// 0 means 1024 bytes
+	MinLength int64
+	// content types that are never compressed
+	// in addition to the built-in list of already compressed types
+	SkipTypes []string
+}
+
+// content types that are already compressed
+// compressing them again only costs cpu
+var compressedPrefixes = []string{"image/", "video/", "audio/", "font/woff"}
+
+var compressedTypes = map[string]bool{
+	"application/zip":              true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-bzip2":          true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/zstd":             true,
+}
+
+// bodies up to this size are compressed in memory and sent with a Content-Length
+// bigger ones are compressed while they are sent, so memory stays flat
+const maxBufferedCompress = 1 << 20
+
+// Compress compresses responses using the default config
+// server.Use(middlewares.Compress)
+func Compress(next core.HandlerFunc) core.HandlerFunc {
+	return CompressWithConfig(CompressConfig{})(next)
+}
+
+// CompressWithConfig
+// returns the compression middleware for the given config
+// server.Use(middlewares.CompressWithConfig(middlewares.CompressConfig{MinLength: 256}))
+func CompressWithConfig(cfg CompressConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Level == 0 {
+		cfg.Level = flate.DefaultCompression
+	}
+	if cfg.MinLength == 0 {
+		cfg.MinLength = 1024
+	}
+
+	skip := map[string]bool{}
+	for _, t := range cfg.SkipTypes {
+		skip[strings.ToLower(t)] = true
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			next(req, res)
+
+			// nothing to compress
+			if res.Sent() || res.GetBody() == nil || req.Method == "HEAD" {
+				return
+			}
+
+			status := res.GetStatusCode()
+			if status < 200 || status == 204 || status == 304 {
+				return
+			}
+
+			// already encoded by the handler (e.g. precompressed file)
+			// or the handler asked proxies and us not to touch the body
+			if res.GetHeader("Content-Encoding") != "" ||
+				strings.Contains(res.GetHeader("Cache-Control"), "no-transform") {
+				return
+			}
+
+			mediaType := strings.ToLower(strings.TrimSpace(strings.Split(res.GetHeader("Content-Type"), ";")[0]))
+			if skip[mediaType] || isCompressedType(mediaType) {
+				return
+			}
+
+			// from here on the body depends on the Accept-Encoding of the client
+			res.AddHeader("Vary", "Accept-Encoding")
+
+			length := res.ContentLength()
+			if length < 0 {
+				// streams may still announce their length
+				if n, err := strconv.ParseInt(res.GetHeader("Content-Length"), 10, 64); err == nil {
+					length = n
+				}
+			}
+			if length >= 0 && length < cfg.MinLength {
+				return
+			}
+
+			encoding := NegotiateEncoding(req.GetHeader("Accept-Encoding"))
+			if encoding == "" {
+				return
+			}
+
+			// ranges refer to the bytes of the uncompressed content
+			// so a range request is answered uncompressed
+			if req.GetHeader("Range") != "" && res.GetHeader("Accept-Ranges") == "bytes" {
+				return
+			}
+
+			if n := res.ContentLength(); n >= 0 && n <= maxBufferedCompress {
+				// buffered body, compress it right away
+				if !compressBuffered(res, encoding, cfg.Level) {
+					return
+				}
+			} else {
+				// streaming body (or a big file), compress lazily while Send() reads it
+				res.SetBody(&compressReader{
+					src:      res.GetBody(),
+					encoding: encoding,
+					level:    cfg.Level,
+				})
+			}
+
+			res.SetHeader("Content-Encoding", encoding)
+			res.DelHeader("Content-Length") // length of the original body is stale now
+			res.DelHeader("Accept-Ranges")  // compressed body can't be served in ranges
+
+			// the compressed body is a different representation
+			// so a strong validator of the original can't be used for it
+			if etag := res.GetHeader("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				res.SetHeader("ETag", "W/"+etag)
+			}
+		}
+	}
+}
+
+func isCompressedType(mediaType string) bool {
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	for _, prefix := range compressedPrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return compressedTypes[mediaType]
+}
+
+// compresses the whole body in memory
+// returns false if the compressed body is not smaller than the original
+func compressBuffered(res *core.Response, encoding string, level int) bool {
+	body := res.GetBody()
+	defer body.Close()
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		res.SetError(err)
+		return false
+	}
+
+	var buf bytes.Buffer
+	enc, err := newEncoder(encoding, &buf, level)
+	if err == nil {
+		_, err = enc.Write(raw)
+	}
+	if err == nil {
+		err = enc.Close()
+	}
+
+	if err != nil || buf.Len() >= len(raw) {
+		res.WriteBytes(raw)
+		return false
+	}
+
+	res.WriteBytes(buf.Bytes())
+	return true
+}
+
+// "deflate" content coding is the zlib format (RFC 9110 section 8.4.1.2)
+func newEncoder(encoding string, w io.Writer, level int) (io.WriteCloser, error) {
+	if encoding == "gzip" {
+		return gzip.NewWriterLevel(w, level)
+	}
+	return zlib.NewWriterLevel(w, level)
+}
+
+// compressReader compresses the source stream on the fly
+// no goroutine is involved, so nothing leaks if the body is never read
+type compressReader struct {
+	src      io.ReadCloser
+	encoding string
+	level    int
+	enc      io.WriteCloser
+	out      bytes.Buffer
+	chunk    []byte
+	done     bool
+}
+
+func (c *compressReader) Read(p []byte) (int, error) {
+	if c.enc == nil {
+		enc, err := newEncoder(c.encoding, &c.out, c.level)
+		if err != nil {
+			return 0, err
+		}
+		c.enc = enc
+		c.chunk = make([]byte, 32*1024)
+	}
+
+	// keep feeding the encoder until it produced some output
+	for c.out.Len() == 0 && !c.done {
+		n, err := c.src.Read(c.chunk)
+		if n > 0 {
+			if _, werr := c.enc.Write(c.chunk[:n]); werr != nil {
+				return 0, werr
+			}
+		}
+		if err == io.EOF {
+			c.done = true
+			if cerr := c.enc.Close(); cerr != nil {
+				return 0, cerr
+			}
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if c.out.Len() == 0 && c.done {
+		return 0, io.EOF
+	}
+	return c.out.Read(p)
+}
+
+func (c *compressReader) Close() error {
+	return c.src.Close()
+}
+
+// NegotiateEncoding
+// picks the content coding for the response from the Accept-Encoding header
+// returns "gzip", "deflate" or "" when the body should be sent as it is
+//
+//	"gzip;q=0.5, deflate"  => "deflate"
+//	"*"                    => "gzip"
+//	"gzip;q=0, identity"   => ""
+func NegotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	gzipQ := core.AcceptsEncoding(acceptEncoding, "gzip")
+	deflateQ := core.AcceptsEncoding(acceptEncoding, "deflate")
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	}
+	return ""
+}
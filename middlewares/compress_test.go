@@ -0,0 +1,211 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/textproto"
+	"squirrel/core"
+	"strings"
+	"testing"
+)
+
+// request as ParseRequest would build it, header names canonicalized
+func testRequest(method, path string, headers map[string]string) *core.Request {
+	canonical := map[string]string{}
+	for k, v := range headers {
+		canonical[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	return &core.Request{Method: method, Path: path, Headers: canonical}
+}
+
+// response without a connection, for inspecting what the middleware did before Send
+func testResponse() *core.Response {
+	var conn net.Conn
+	return core.NewResponse(&conn)
+}
+
+func readBody(t *testing.T, res *core.Response) string {
+	t.Helper()
+	if res.GetBody() == nil {
+		return ""
+	}
+	b, err := io.ReadAll(res.GetBody())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"deflate;q=0.5, gzip;q=0.5", "gzip"},
+		{"x-gzip", "gzip"},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"gzip;q=0, identity", ""},
+		{"identity", ""},
+		{"br", ""},
+		{"GZIP;Q=1", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("squirrels hide nuts for the winter. ", 100)
+
+	tests := []struct {
+		name     string
+		method   string
+		accept   string
+		handler  core.HandlerFunc
+		encoding string // expected Content-Encoding
+		vary     bool
+	}{
+		{"gzip", "GET", "gzip", textHandler(long, nil), "gzip", true},
+		{"deflate", "GET", "deflate", textHandler(long, nil), "deflate", true},
+		{"client without compression", "GET", "", textHandler(long, nil), "", true},
+		{"small body", "GET", "gzip", textHandler("short", nil), "", true},
+		{"head", "HEAD", "gzip", textHandler(long, nil), "", false},
+		{"image", "GET", "gzip", textHandler(long, map[string]string{"Content-Type": "image/png"}), "", false},
+		{"svg is text", "GET", "gzip", textHandler(long, map[string]string{"Content-Type": "image/svg+xml"}), "gzip", true},
+		{"already encoded", "GET", "gzip", textHandler(long, map[string]string{"Content-Encoding": "br"}), "br", false},
+		{"no-transform", "GET", "gzip", textHandler(long, map[string]string{"Cache-Control": "no-transform"}), "", false},
+		{"not modified", "GET", "gzip", func(req *core.Request, res *core.Response) {
+			res.SetStatus(304)
+			res.Write(long)
+		}, "", false},
+		{"stream", "GET", "gzip", func(req *core.Request, res *core.Response) {
+			res.SetBody(io.NopCloser(strings.NewReader(long)))
+		}, "gzip", true},
+	}
+	for _, tt := range tests {
+		req := testRequest(tt.method, "/", map[string]string{"Accept-Encoding": tt.accept})
+		res := testResponse()
+		Compress(tt.handler)(req, res)
+
+		if got := res.GetHeader("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.encoding)
+		}
+		if got := res.GetHeader("Vary") == "Accept-Encoding"; got != tt.vary {
+			t.Errorf("%s: Vary = %q", tt.name, res.GetHeader("Vary"))
+		}
+
+		body := readBody(t, res)
+		switch tt.encoding {
+		case "gzip", "deflate":
+			if got := decompress(t, tt.encoding, body); got != long {
+				t.Errorf("%s: decompressed body differs", tt.name)
+			}
+			if res.GetHeader("Content-Length") != "" {
+				t.Errorf("%s: stale Content-Length %s", tt.name, res.GetHeader("Content-Length"))
+			}
+		}
+	}
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	req := testRequest("GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	res := testResponse()
+	Compress(textHandler(strings.Repeat("a", 2048), map[string]string{"ETag": `"v1"`}))(req, res)
+
+	if got := res.GetHeader("ETag"); got != `W/"v1"` {
+		t.Errorf("ETag = %q, want W/\"v1\"", got)
+	}
+}
+
+func TestCompressConfig(t *testing.T) {
+	mw := CompressWithConfig(CompressConfig{MinLength: 256, SkipTypes: []string{"application/x-ndjson"}})
+	body := strings.Repeat("abc", 100) // below the default MinLength
+
+	req := testRequest("GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	res := testResponse()
+	mw(textHandler(body, nil))(req, res)
+	if res.GetHeader("Content-Encoding") != "gzip" {
+		t.Error("body above MinLength not compressed")
+	}
+
+	res = testResponse()
+	mw(textHandler(body, map[string]string{"Content-Type": "application/x-ndjson"}))(req, res)
+	if res.GetHeader("Content-Encoding") != "" {
+		t.Error("skipped type compressed")
+	}
+}
+
+func textHandler(body string, headers map[string]string) core.HandlerFunc {
+	return func(req *core.Request, res *core.Response) {
+		res.SetHeader("Content-Type", "text/plain; charset=utf-8")
+		for k, v := range headers {
+			res.SetHeader(k, v)
+		}
+		res.Write(body)
+	}
+}
+
+func decompress(t *testing.T, encoding, body string) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	if encoding == "gzip" {
+		r, err = gzip.NewReader(bytes.NewReader([]byte(body)))
+	} else {
+		r, err = zlib.NewReader(bytes.NewReader([]byte(body)))
+	}
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	return string(b)
+}
+
+func TestCompressRanges(t *testing.T) {
+	long := strings.Repeat("a", 4096)
+	content := textHandler(long, map[string]string{"Accept-Ranges": "bytes"})
+
+	// a range refers to the uncompressed bytes
+	req := testRequest("GET", "/", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"})
+	res := testResponse()
+	Compress(content)(req, res)
+	if res.GetHeader("Content-Encoding") != "" || res.GetHeader("Accept-Ranges") != "bytes" {
+		t.Errorf("range request: Content-Encoding %q, Accept-Ranges %q", res.GetHeader("Content-Encoding"), res.GetHeader("Accept-Ranges"))
+	}
+
+	// the compressed body can't be requested in ranges
+	req = testRequest("GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	res = testResponse()
+	Compress(content)(req, res)
+	if res.GetHeader("Content-Encoding") != "gzip" || res.GetHeader("Accept-Ranges") != "" {
+		t.Errorf("full request: Content-Encoding %q, Accept-Ranges %q", res.GetHeader("Content-Encoding"), res.GetHeader("Accept-Ranges"))
+	}
+}
+
+func TestCompressLargeBodyLazily(t *testing.T) {
+	long := strings.Repeat("0123456789", maxBufferedCompress/10+1)
+	req := testRequest("GET", "/", map[string]string{"Accept-Encoding": "gzip"})
+	res := testResponse()
+	Compress(textHandler(long, nil))(req, res)
+
+	// compressed while it is sent, so the length is unknown up front
+	if res.GetHeader("Content-Encoding") != "gzip" || res.ContentLength() != -1 {
+		t.Fatalf("Content-Encoding %q, length %d", res.GetHeader("Content-Encoding"), res.ContentLength())
+	}
+	if got := decompress(t, "gzip", readBody(t, res)); got != long {
+		t.Error("decompressed body differs")
+	}
+}
@@ -0,0 +1,178 @@
+package middlewares
+
+import (
+	"squirrel/core"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	CORS middleware
+
+	- browsers only let pages of other origins read our responses
+	  if the response allows it with Access-Control-Allow-* headers
+	- for requests with custom headers or methods the browser asks first with
+	  an OPTIONS preflight request, answered here without calling the route handler
+	- the mux answers OPTIONS for every registered path, so preflights reach this middleware,
+	  also when it is attached to a route or group; put it before auth middlewares there,
+	  preflights carry no credentials
+
+	server.Use(middlewares.CORS)
+*/
+
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	// origins allowed to read the responses
+	// "https://example.com", "https://*.example.com" (any subdomain) or "*" (anyone)
+	// defaults to "*", except with AllowCredentials where the origins have to be listed
+	AllowOrigins []string
+	// decides about origins not in AllowOrigins
+	AllowOriginFunc func(origin string) bool
+	// methods allowed in preflight requests
+	// defaults to GET, HEAD, PUT, PATCH, POST, DELETE
+	AllowMethods []string
+	// request headers allowed in preflight requests
+	// empty allows the headers the browser asks for
+	AllowHeaders []string
+	// response headers the page may read besides the simple ones
+	ExposeHeaders []string
+	// allow cookies and authorization headers
+	// needs explicit AllowOrigins or AllowOriginFunc, "*" panics
+	AllowCredentials bool
+	// how long browsers may cache the preflight result
+	// 0 leaves it to the browser (5 seconds by default)
+	MaxAge time.Duration
+}
+
+// CORS allows requests from any origin
+// server.Use(middlewares.CORS)
+func CORS(next core.HandlerFunc) core.HandlerFunc {
+	return CORSWithConfig(CORSConfig{})(next)
+}
+
+// CORSWithConfig
+// returns the CORS middleware for the given config
+//
+//	server.Use(middlewares.CORSWithConfig(middlewares.CORSConfig{
+//		AllowOrigins:     []string{"https://app.example.com", "https://*.example.dev"},
+//		AllowCredentials: true,
+//		MaxAge:           time.Hour,
+//	}))
+func CORSWithConfig(cfg CORSConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if len(cfg.AllowOrigins) == 0 && cfg.AllowOriginFunc == nil {
+		if cfg.AllowCredentials {
+			panic("middlewares: CORSConfig.AllowCredentials needs AllowOrigins or AllowOriginFunc")
+		}
+		cfg.AllowOrigins = []string{"*"}
+	}
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE"}
+	}
+
+	anyOrigin := false
+	var exact []string
+	var wildcards [][2]string // scheme://  and .domain
+	for _, origin := range cfg.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			// any site could read the responses of logged in users
+			if cfg.AllowCredentials {
+				panic(`middlewares: CORSConfig.AllowOrigins "*" can't be combined with AllowCredentials`)
+			}
+			anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "*")
+			wildcards = append(wildcards, [2]string{scheme, domain})
+		default:
+			exact = append(exact, origin)
+		}
+	}
+
+	allowed := func(origin string) bool {
+		lower := strings.ToLower(origin)
+		if anyOrigin {
+			return true
+		}
+		for _, o := range exact {
+			if lower == o {
+				return true
+			}
+		}
+		for _, w := range wildcards {
+			if strings.HasPrefix(lower, w[0]) && strings.HasSuffix(lower, w[1]) && len(lower) > len(w[0])+len(w[1]) {
+				return true
+			}
+		}
+		return cfg.AllowOriginFunc != nil && cfg.AllowOriginFunc(origin)
+	}
+
+	allowMethods := strings.Join(cfg.AllowMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
+	// "*" is the same for every origin, anything else depends on the Origin header
+	wildcardResponse := anyOrigin
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			origin := req.GetHeader("Origin")
+			preflight := req.IsPreflight()
+
+			if !wildcardResponse {
+				res.AddHeader("Vary", "Origin")
+			}
+			if preflight {
+				res.AddHeader("Vary", "Access-Control-Request-Method")
+				res.AddHeader("Vary", "Access-Control-Request-Headers")
+			}
+
+			// same origin requests and non browser clients
+			if origin == "" {
+				next(req, res)
+				return
+			}
+
+			if !allowed(origin) {
+				if preflight {
+					// no cors headers, the browser blocks the actual request
+					res.SetStatus(204)
+					return
+				}
+				next(req, res)
+				return
+			}
+
+			if wildcardResponse {
+				res.SetHeader("Access-Control-Allow-Origin", "*")
+			} else {
+				res.SetHeader("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				res.SetHeader("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					res.SetHeader("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next(req, res)
+				return
+			}
+
+			// preflight, answered without the route handler
+			res.SetHeader("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				res.SetHeader("Access-Control-Allow-Headers", allowHeaders)
+			} else if requested := req.GetHeader("Access-Control-Request-Headers"); requested != "" {
+				res.SetHeader("Access-Control-Allow-Headers", requested)
+			}
+			if cfg.MaxAge > 0 {
+				res.SetHeader("Access-Control-Max-Age", maxAge)
+			}
+			res.SetStatus(204)
+		}
+	}
+}
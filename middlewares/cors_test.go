@@ -0,0 +1,123 @@
+package middlewares
+
+import (
+	"net"
+	"squirrel/core"
+	"testing"
+)
+
+// runs the middleware for a request with the headers, reports whether the handler was called
+func runCORS(cfg CORSConfig, method string, headers map[string]string) (*core.Response, bool) {
+	var conn net.Conn
+	req := &core.Request{Method: method, Path: "/api", Headers: headers}
+	res := core.NewResponse(&conn)
+	called := false
+	CORSWithConfig(cfg)(func(req *core.Request, res *core.Response) { called = true })(req, res)
+	return res, called
+}
+
+func TestCORSOrigins(t *testing.T) {
+	list := CORSConfig{AllowOrigins: []string{"https://app.example.com", "https://*.example.dev/"}}
+	credentials := CORSConfig{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true}
+	byFunc := CORSConfig{AllowOriginFunc: func(origin string) bool { return origin == "https://partner.example.org" }}
+
+	tests := []struct {
+		name   string
+		cfg    CORSConfig
+		origin string
+		want   string // Access-Control-Allow-Origin
+	}{
+		{"default allows anyone", CORSConfig{}, "https://evil.example", "*"},
+		{"exact", list, "https://app.example.com", "https://app.example.com"},
+		{"exact ignores case", list, "https://App.Example.com", "https://App.Example.com"},
+		{"other scheme", list, "http://app.example.com", ""},
+		{"other port", list, "https://app.example.com:8443", ""},
+		{"suffix is no match", list, "https://app.example.com.evil.example", ""},
+		{"subdomain wildcard", list, "https://preview-42.example.dev", "https://preview-42.example.dev"},
+		{"nested subdomain", list, "https://a.b.example.dev", "https://a.b.example.dev"},
+		{"wildcard needs a subdomain", list, "https://example.dev", ""},
+		{"wildcard needs the dot", list, "https://evilexample.dev", ""},
+		{"wildcard keeps the scheme", list, "http://preview.example.dev", ""},
+		{"null origin", list, "null", ""},
+		{"credentials echo the origin", credentials, "https://app.example.com", "https://app.example.com"},
+		{"credentials with null origin", credentials, "null", ""},
+		{"origin func", byFunc, "https://partner.example.org", "https://partner.example.org"},
+		{"origin func denies", byFunc, "https://evil.example", ""},
+	}
+	for _, tt := range tests {
+		res, called := runCORS(tt.cfg, "GET", map[string]string{"Origin": tt.origin})
+		if got := res.GetHeader("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.want)
+		}
+		if !called {
+			t.Errorf("%s: handler not called", tt.name)
+		}
+		wantCredentials := ""
+		if tt.cfg.AllowCredentials && tt.want != "" {
+			wantCredentials = "true"
+		}
+		if got := res.GetHeader("Access-Control-Allow-Credentials"); got != wantCredentials {
+			t.Errorf("%s: Access-Control-Allow-Credentials = %q, want %q", tt.name, got, wantCredentials)
+		}
+		if tt.want != "*" && res.GetHeader("Vary") == "" {
+			t.Errorf("%s: no Vary: Origin", tt.name)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cfg := CORSConfig{AllowOrigins: []string{"https://app.example.com"}, AllowHeaders: []string{"Content-Type"}}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		origin  string
+		methods string
+		handler bool
+	}{
+		{"allowed", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"}, 204, "https://app.example.com", "GET, HEAD, PUT, PATCH, POST, DELETE", false},
+		{"other origin", map[string]string{"Origin": "https://evil.example", "Access-Control-Request-Method": "PUT"}, 204, "", "", false},
+		{"plain options", map[string]string{"Origin": "https://app.example.com"}, 200, "https://app.example.com", "", true},
+		{"no origin", map[string]string{"Access-Control-Request-Method": "PUT"}, 200, "", "", true},
+	}
+	for _, tt := range tests {
+		res, called := runCORS(cfg, "OPTIONS", tt.headers)
+		if got := res.GetStatusCode(); got != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.status)
+		}
+		if got := res.GetHeader("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.origin)
+		}
+		if got := res.GetHeader("Access-Control-Allow-Methods"); got != tt.methods {
+			t.Errorf("%s: Access-Control-Allow-Methods = %q, want %q", tt.name, got, tt.methods)
+		}
+		if called != tt.handler {
+			t.Errorf("%s: handler called = %v, want %v", tt.name, called, tt.handler)
+		}
+	}
+}
+
+func TestCORSCredentialsNeedOrigins(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   CORSConfig
+		panic bool
+	}{
+		{"default origins", CORSConfig{AllowCredentials: true}, true},
+		{"explicit wildcard", CORSConfig{AllowCredentials: true, AllowOrigins: []string{"https://app.example.com", "*"}}, true},
+		{"listed origins", CORSConfig{AllowCredentials: true, AllowOrigins: []string{"https://app.example.com"}}, false},
+		{"origin func", CORSConfig{AllowCredentials: true, AllowOriginFunc: func(string) bool { return false }}, false},
+		{"wildcard without credentials", CORSConfig{AllowOrigins: []string{"*"}}, false},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if got := recover() != nil; got != tt.panic {
+					t.Errorf("%s: panic = %v, want %v", tt.name, got, tt.panic)
+				}
+			}()
+			CORSWithConfig(tt.cfg)
+		}()
+	}
+}
@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"squirrel/core"
+)
+
+/*
+	CSP violation reports
+
+	browsers post violations of the Content-Security-Policy to the report uri
+	- application/csp-report:  {"csp-report": {"document-uri": ...}}        (report-uri)
+	- application/reports+json: [{"type": "csp-violation", "body": {...}}]  (report-to)
+	both formats end up as CSPReport
+	the report route must be exempt from the CSRF middleware, browsers send no token
+
+	server.Post("/csp-report", middlewares.CSPReportHandler(func(r middlewares.CSPReport, req *core.Request) {
+		log.Printf("csp: %s blocked %s on %s", r.EffectiveDirective, r.BlockedURI, r.DocumentURI)
+	}))
+*/
+
+// CSPReport is one violation of the policy
+type CSPReport struct {
+	DocumentURI        string `json:"documentURI"`
+	Referrer           string `json:"referrer,omitempty"`
+	BlockedURI         string `json:"blockedURI"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"` // "enforce" or "report"
+	SourceFile         string `json:"sourceFile,omitempty"`
+	LineNumber         int    `json:"lineNumber,omitempty"`
+	ColumnNumber       int    `json:"columnNumber,omitempty"`
+	StatusCode         int    `json:"statusCode,omitempty"`
+	Sample             string `json:"sample,omitempty"`
+}
+
+// reports bigger than this are dropped
+const maxCSPReportSize = 64 << 10
+
+// {} or {"csp-report": {}} decode fine, but tell nothing
+var errEmptyCSPReport = errors.New("csp report without document-uri and blocked-uri")
+
+// report-uri format, with dashed names
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		OriginalPolicy     string `json:"original-policy"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+		StatusCode         int    `json:"status-code"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// report-to format (Reporting API)
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		OriginalPolicy     string `json:"originalPolicy"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		StatusCode         int    `json:"statusCode"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+// CSPReportHandler
+// returns a handler for the report uri, calling fn for every violation
+// answers 204, or 400 for bodies that are no reports
+// and for reports without document-uri and blocked-uri, fn never sees those
+// browsers send reports without csrf token, exempt the route from the CSRF middleware
+// (CSRFConfig.Exempt: []string{"/csp-report"})
+func CSPReportHandler(fn func(report CSPReport, req *core.Request)) core.HandlerFunc {
+	return func(req *core.Request, res *core.Response) {
+		reports, err := parseCSPReports(req)
+		for _, report := range reports {
+			if err == nil && report.DocumentURI == "" && report.BlockedURI == "" {
+				err = errEmptyCSPReport
+			}
+		}
+		if err != nil {
+			res.SetError(core.NewHTTPError(400, "Invalid CSP Report").WithInternal(err))
+			return
+		}
+		for _, report := range reports {
+			fn(report, req)
+		}
+		res.SetStatus(204)
+	}
+}
+
+func parseCSPReports(req *core.Request) ([]CSPReport, error) {
+	if req.Body == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, maxCSPReportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCSPReportSize {
+		return nil, core.ErrFormTooLarge
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.GetHeader("Content-Type"))
+	if mediaType == "application/reports+json" {
+		var batch []reportingAPIReport
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, err
+		}
+		var reports []CSPReport
+		for _, r := range batch {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			b := r.Body
+			reports = append(reports, CSPReport{
+				DocumentURI:        b.DocumentURL,
+				Referrer:           b.Referrer,
+				BlockedURI:         b.BlockedURL,
+				EffectiveDirective: b.EffectiveDirective,
+				OriginalPolicy:     b.OriginalPolicy,
+				Disposition:        b.Disposition,
+				SourceFile:         b.SourceFile,
+				LineNumber:         b.LineNumber,
+				ColumnNumber:       b.ColumnNumber,
+				StatusCode:         b.StatusCode,
+				Sample:             b.Sample,
+			})
+		}
+		return reports, nil
+	}
+
+	// application/csp-report, some browsers send application/json
+	var legacy legacyCSPReport
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	r := legacy.Report
+	directive := r.EffectiveDirective
+	if directive == "" {
+		directive = r.ViolatedDirective
+	}
+	return []CSPReport{{
+		DocumentURI:        r.DocumentURI,
+		Referrer:           r.Referrer,
+		BlockedURI:         r.BlockedURI,
+		EffectiveDirective: directive,
+		OriginalPolicy:     r.OriginalPolicy,
+		Disposition:        r.Disposition,
+		SourceFile:         r.SourceFile,
+		LineNumber:         r.LineNumber,
+		ColumnNumber:       r.ColumnNumber,
+		StatusCode:         r.StatusCode,
+		Sample:             r.ScriptSample,
+	}}, nil
+}
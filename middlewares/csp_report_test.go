@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"errors"
+	"io"
+	"squirrel/core"
+	"strings"
+	"testing"
+)
+
+func TestCSPReportHandler(t *testing.T) {
+	tests := []struct {
+		name, contentType, body string
+		status                  int
+		want                    []CSPReport
+	}{
+		{"report-uri", "application/csp-report", `{"csp-report": {
+			"document-uri": "https://example.com/page", "blocked-uri": "https://evil.com/x.js",
+			"violated-directive": "script-src-elem", "original-policy": "script-src 'self'",
+			"disposition": "enforce", "line-number": 12, "script-sample": "alert(1)"}}`, 204,
+			[]CSPReport{{
+				DocumentURI: "https://example.com/page", BlockedURI: "https://evil.com/x.js",
+				EffectiveDirective: "script-src-elem", OriginalPolicy: "script-src 'self'",
+				Disposition: "enforce", LineNumber: 12, Sample: "alert(1)",
+			}}},
+		{"report-uri as json", "application/json", `{"csp-report": {"document-uri": "https://example.com/",
+			"blocked-uri": "inline", "effective-directive": "style-src", "violated-directive": "style-src-elem"}}`, 204,
+			[]CSPReport{{DocumentURI: "https://example.com/", BlockedURI: "inline", EffectiveDirective: "style-src"}}},
+		{"report-to", "application/reports+json", `[
+			{"type": "csp-violation", "body": {"documentURL": "https://example.com/a", "blockedURL": "eval",
+				"effectiveDirective": "script-src", "disposition": "report", "statusCode": 200}},
+			{"type": "deprecation", "body": {"id": "x"}},
+			{"type": "csp-violation", "body": {"documentURL": "https://example.com/b", "blockedURL": "https://cdn.example.net/font.woff",
+				"effectiveDirective": "font-src"}}]`, 204,
+			[]CSPReport{
+				{DocumentURI: "https://example.com/a", BlockedURI: "eval", EffectiveDirective: "script-src", Disposition: "report", StatusCode: 200},
+				{DocumentURI: "https://example.com/b", BlockedURI: "https://cdn.example.net/font.woff", EffectiveDirective: "font-src"},
+			}},
+		{"not json", "application/csp-report", "<html>", 400, nil},
+		{"report-to not a list", "application/reports+json", `{"type": "csp-violation"}`, 400, nil},
+		{"empty object", "application/csp-report", `{}`, 400, nil},
+		{"empty report", "application/csp-report", `{"csp-report": {"effective-directive": "script-src"}}`, 400, nil},
+		{"empty report in a batch", "application/reports+json", `[
+			{"type": "csp-violation", "body": {"documentURL": "https://example.com/a", "blockedURL": "eval"}},
+			{"type": "csp-violation", "body": {}}
+		]`, 400, nil},
+		{"only blocked uri", "application/csp-report", `{"csp-report": {"blocked-uri": "inline"}}`, 204,
+			[]CSPReport{{BlockedURI: "inline"}}},
+		{"too large", "application/csp-report", `{"csp-report": {"sample": "` + strings.Repeat("x", maxCSPReportSize) + `"}}`, 400, nil},
+	}
+	for _, tt := range tests {
+		var got []CSPReport
+		handler := CSPReportHandler(func(report CSPReport, req *core.Request) { got = append(got, report) })
+
+		req := testRequest("POST", "/csp-report", map[string]string{"Content-Type": tt.contentType})
+		req.Body = io.NopCloser(strings.NewReader(tt.body))
+		res := testResponse()
+		handler(req, res)
+
+		status := res.GetStatusCode()
+		var httpErr *core.HTTPError
+		if errors.As(res.GetError(), &httpErr) {
+			status = httpErr.Code
+		}
+		if status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.status)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d reports, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: report %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"squirrel/cookies"
+	"squirrel/core"
+	"strings"
+)
+
+/*
+	CSRF middleware
+
+	- unsafe requests (POST, PUT, PATCH, DELETE) from other sites are rejected with 403:
+	  first by Sec-Fetch-Site / Origin / Referer, then by a token the other site can't know
+	- the token lives in the session (synchronizer token) or in a signed cookie (double submit)
+	- with the session middleware in front, the cookie token is bound to the session id,
+	  so a cookie planted by a sibling subdomain doesn't fit the victim's session
+	- forms send it back in a hidden field, javascript in the X-CSRF-Token header
+	- multipart bodies are only parsed until the token field, put it before the file fields
+	  (within the first core.MaxFormSize bytes) or send the header for big uploads,
+	  the whole body is still read by the server first, see server.SetMaxBodySize
+
+	server.Use(middlewares.CSRF)
+
+	<form method="post">
+		<input type="hidden" name="csrf_token" value="{{.csrf}}">
+	</form>
+*/
+
+// CSRFConfig configures the CSRF middleware
+type CSRFConfig struct {
+	// keep the token in req.Session() instead of a cookie
+	// requires the session middleware in front of the CSRF middleware
+	Session bool
+	// codec signing the token cookie (double submit)
+	// nil means a random key, tokens are invalid after a restart then
+	Codec *cookies.Codec
+	// template of the token cookie
+	// defaults: Name "_csrf", Path "/", HttpOnly, SameSite=Lax
+	Cookie cookies.Cookie
+	// form field with the token, "csrf_token" by default
+	// multipart forms work as well, the field has to come before big file parts
+	FieldName string
+	// header with the token, "X-CSRF-Token" by default
+	HeaderName string
+	// key of the token in the request locals (and so in templates), "csrf" by default
+	ContextKey string
+	// other origins allowed to send unsafe requests, e.g. "https://admin.example.com"
+	TrustedOrigins []string
+	// paths that are not protected, e.g. webhooks signed in another way
+	// patterns as in core.MatchPath: "/webhooks/*" matches everything below /webhooks
+	Exempt []string
+	// skips the check for the request when it returns true
+	Skip func(req *core.Request) bool
+}
+
+var (
+	// ErrCSRFToken is the error for requests without a valid token
+	ErrCSRFToken = core.NewHTTPError(403, "Invalid CSRF Token")
+	// ErrCSRFOrigin is the error for requests coming from another site
+	ErrCSRFOrigin = core.NewHTTPError(403, "Cross-Site Request Blocked")
+)
+
+// session key of the synchronizer token
+const csrfSessionKey = "_csrf"
+
+// CSRF protects against cross-site request forgery using the default config
+// server.Use(middlewares.CSRF)
+func CSRF(next core.HandlerFunc) core.HandlerFunc {
+	return CSRFWithConfig(CSRFConfig{})(next)
+}
+
+// CSRFWithConfig
+// returns the CSRF middleware for the given config
+//
+//	server.Use(middlewares.CSRFWithConfig(middlewares.CSRFConfig{
+//		Codec:  cookies.NewSignedCodec(secret),
+//		Exempt: []string{"/webhooks/*"},
+//	}))
+func CSRFWithConfig(cfg CSRFConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Codec == nil {
+		cfg.Codec = cookies.NewSignedCodec(randomToken())
+	}
+	if cfg.Cookie.Name == "" {
+		cfg.Cookie.Name = "_csrf"
+	}
+	if cfg.Cookie.Path == "" {
+		cfg.Cookie.Path = "/"
+	}
+	if cfg.Cookie.SameSite == 0 {
+		cfg.Cookie.SameSite = cookies.SameSiteLaxMode
+	}
+	cfg.Cookie.HttpOnly = true
+	if cfg.FieldName == "" {
+		cfg.FieldName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.ContextKey == "" {
+		cfg.ContextKey = "csrf"
+	}
+
+	trusted := map[string]bool{}
+	for _, origin := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			if csrfExempt(cfg, req) {
+				next(req, res)
+				return
+			}
+
+			token := csrfToken(cfg, req, res)
+			req.Set(cfg.ContextKey, token)
+
+			if !unsafeMethod(req.Method) {
+				next(req, res)
+				return
+			}
+
+			if !sameOrigin(req, trusted) {
+				res.SetError(ErrCSRFOrigin)
+				return
+			}
+
+			sent := req.GetHeader(cfg.HeaderName)
+			if sent == "" {
+				sent = csrfFieldToken(req, cfg.FieldName)
+			}
+			if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				res.SetError(ErrCSRFToken)
+				return
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+// CSRFToken
+// returns the token of the request for forms and javascript clients
+// handlers can also read it with req.Get("csrf") (the default ContextKey)
+func CSRFToken(req *core.Request) string {
+	token, _ := req.Get("csrf").(string)
+	return token
+}
+
+// returns the token of the client, or creates and stores a new one
+func csrfToken(cfg CSRFConfig, req *core.Request, res *core.Response) string {
+	if cfg.Session {
+		session := req.Session()
+		if session == nil {
+			panic("middlewares: CSRFConfig.Session needs the session middleware in front of CSRF")
+		}
+		if token, ok := session.Get(csrfSessionKey).(string); ok && token != "" {
+			return token
+		}
+		token := base64.RawURLEncoding.EncodeToString(randomToken())
+		session.Set(csrfSessionKey, token)
+		return token
+	}
+
+	// "token" or "token.binding" with the hashed session id
+	binding := csrfSessionBinding(req)
+	if value, err := req.SignedCookie(cfg.Codec, cfg.Cookie.Name); err == nil && value != "" {
+		token, bound, _ := strings.Cut(value, ".")
+		if token != "" && subtle.ConstantTimeCompare([]byte(bound), []byte(binding)) == 1 {
+			return token
+		}
+	}
+	token := base64.RawURLEncoding.EncodeToString(randomToken())
+	cookie := cfg.Cookie
+	cookie.Value = token
+	if binding != "" {
+		cookie.Value += "." + binding
+	}
+	res.SetSignedCookie(cfg.Codec, cookie)
+	return token
+}
+
+// hash of the session id the cookie token belongs to, empty without the session middleware
+// the session is marked as modified, so new sessions are saved and keep their id
+func csrfSessionBinding(req *core.Request) string {
+	session := req.Session()
+	if session == nil {
+		return ""
+	}
+	if session.IsNew() {
+		session.Set(csrfSessionKey+"_bound", true)
+	}
+	sum := sha256.Sum256([]byte(session.ID))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+// token of the form field
+// the body is already in memory (core.ParseRequest reads it whole, up to the server's max body size),
+// multipart parts are only parsed until the field shows up, so file parts behind it are skipped,
+// the consumed bytes are put back in front of the body for the handler
+func csrfFieldToken(req *core.Request, field string) string {
+	mediaType, params, _ := mime.ParseMediaType(req.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" || req.Body == nil || params["boundary"] == "" {
+		return req.FormValue(field)
+	}
+
+	body := req.Body
+	var consumed bytes.Buffer
+	limited := &io.LimitedReader{R: io.TeeReader(body, &consumed), N: core.MaxFormSize}
+	defer func() {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(&consumed, body), body}
+	}()
+
+	mr := multipart.NewReader(limited, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == field && part.FileName() == "" {
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			return string(value)
+		}
+		part.Close()
+	}
+}
+
+// first line of defense: where does the request come from
+// browsers send Sec-Fetch-Site, older ones at least Origin or Referer
+// requests without any of them (curl, server to server) are left to the token check
+func sameOrigin(req *core.Request, trusted map[string]bool) bool {
+	origin := req.GetHeader("Origin")
+	if origin == "" {
+		if ref, err := url.Parse(req.GetHeader("Referer")); err == nil && ref.Host != "" {
+			origin = ref.Scheme + "://" + ref.Host
+		}
+	}
+
+	if origin != "" && trusted[strings.ToLower(origin)] {
+		return true
+	}
+
+	switch req.GetHeader("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "same-site", "cross-site":
+		return false
+	}
+
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		// includes "null" from sandboxed frames and privacy redirects
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host())
+}
+
+func csrfExempt(cfg CSRFConfig, req *core.Request) bool {
+	if cfg.Skip != nil && cfg.Skip(req) {
+		return true
+	}
+	for _, pattern := range cfg.Exempt {
+		if core.MatchPath(pattern, req.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func unsafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return false
+	}
+	return true
+}
+
+func randomToken() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("middlewares: reading random bytes: " + err.Error())
+	}
+	return b
+}
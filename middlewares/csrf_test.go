@@ -0,0 +1,252 @@
+package middlewares
+
+import (
+	"errors"
+	"io"
+	"squirrel/cookies"
+	"squirrel/core"
+	"strings"
+	"testing"
+)
+
+// runs the request through the CSRF middleware
+// returns the error recorded on the response and whether the handler ran
+func runCSRF(cfg CSRFConfig, req *core.Request) (error, bool) {
+	called := false
+	res := testResponse()
+	CSRFWithConfig(cfg)(func(req *core.Request, res *core.Response) {
+		called = true
+	})(req, res)
+	return res.GetError(), called
+}
+
+// request carrying the signed token cookie of a client
+func withTokenCookie(t *testing.T, req *core.Request, codec *cookies.Codec, token string) *core.Request {
+	t.Helper()
+	value, err := codec.Encode("_csrf", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Cookies = append(req.Cookies, &cookies.Cookie{Name: "_csrf", Value: value})
+	return req
+}
+
+func TestCSRFCookieToken(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+	cfg := CSRFConfig{Codec: codec}
+	const token = "client-token"
+
+	tests := []struct {
+		name    string
+		method  string
+		cookie  string
+		headers map[string]string
+		want    error
+	}{
+		{"safe method needs no token", "GET", "", nil, nil},
+		{"valid header token", "POST", token, map[string]string{"X-CSRF-Token": token}, nil},
+		{"missing token", "POST", token, nil, ErrCSRFToken},
+		{"wrong token", "POST", token, map[string]string{"X-CSRF-Token": "guess"}, ErrCSRFToken},
+		{"token without cookie", "DELETE", "", map[string]string{"X-CSRF-Token": token}, ErrCSRFToken},
+		// a token captured from one client is useless with the cookie of another
+		{"replayed token of another client", "POST", "other-token", map[string]string{"X-CSRF-Token": token}, ErrCSRFToken},
+	}
+	for _, tt := range tests {
+		req := testRequest(tt.method, "/account", tt.headers)
+		if tt.cookie != "" {
+			withTokenCookie(t, req, codec, tt.cookie)
+		}
+		err, called := runCSRF(cfg, req)
+		if !errors.Is(err, tt.want) || called != (tt.want == nil) {
+			t.Errorf("%s: error = %v (handler ran %v), want %v", tt.name, err, called, tt.want)
+		}
+	}
+}
+
+func TestCSRFFormField(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+	const token = "client-token"
+
+	tests := []struct {
+		name, contentType, body string
+		want                    error
+	}{
+		{"urlencoded", "application/x-www-form-urlencoded", "csrf_token=" + token + "&name=ada", nil},
+		{"multipart", "multipart/form-data; boundary=xyz",
+			"--xyz\r\nContent-Disposition: form-data; name=\"csrf_token\"\r\n\r\n" + token + "\r\n--xyz--\r\n", nil},
+		{"wrong field", "application/x-www-form-urlencoded", "csrf_token=guess", ErrCSRFToken},
+		{"not a form", "application/json", `{"csrf_token":"` + token + `"}`, ErrCSRFToken},
+	}
+	for _, tt := range tests {
+		req := testRequest("POST", "/account", map[string]string{"Content-Type": tt.contentType})
+		req.Body = io.NopCloser(strings.NewReader(tt.body))
+		withTokenCookie(t, req, codec, token)
+		if err, _ := runCSRF(CSRFConfig{Codec: codec}, req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCSRFSessionToken(t *testing.T) {
+	session := core.NewSession("session-a")
+	session.Set(csrfSessionKey, "token-a")
+	other := core.NewSession("session-b")
+	other.Set(csrfSessionKey, "token-b")
+
+	tests := []struct {
+		name    string
+		session *core.Session
+		sent    string
+		want    error
+	}{
+		{"own token", session, "token-a", nil},
+		{"token of another session", other, "token-a", ErrCSRFToken},
+		{"missing token", session, "", ErrCSRFToken},
+	}
+	for _, tt := range tests {
+		headers := map[string]string{}
+		if tt.sent != "" {
+			headers["X-CSRF-Token"] = tt.sent
+		}
+		req := testRequest("POST", "/account", headers)
+		req.SetSession(tt.session)
+		if err, _ := runCSRF(CSRFConfig{Session: true}, req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// a new session gets a token the templates can render
+	fresh := core.NewSession("session-c")
+	req := testRequest("GET", "/form", nil)
+	req.SetSession(fresh)
+	runCSRF(CSRFConfig{Session: true}, req)
+	if token, _ := fresh.Get(csrfSessionKey).(string); token == "" || CSRFToken(req) != token {
+		t.Errorf("fresh session token = %q, CSRFToken = %q", token, CSRFToken(req))
+	}
+}
+
+func TestCSRFSessionWithoutMiddleware(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "session middleware") {
+			t.Errorf("recovered %v, want a panic about the session middleware", r)
+		}
+	}()
+	runCSRF(CSRFConfig{Session: true}, testRequest("GET", "/", nil))
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+	const token = "client-token"
+	cfg := CSRFConfig{Codec: codec, TrustedOrigins: []string{"https://admin.example.com/"}}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    error
+	}{
+		{"no origin headers", nil, nil},
+		{"same-origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, nil},
+		{"typed url", map[string]string{"Sec-Fetch-Site": "none"}, nil},
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site"}, ErrCSRFOrigin},
+		{"same-site fetch", map[string]string{"Sec-Fetch-Site": "same-site"}, ErrCSRFOrigin},
+		{"same origin", map[string]string{"Origin": "https://example.com"}, nil},
+		{"other origin", map[string]string{"Origin": "https://evil.com"}, ErrCSRFOrigin},
+		{"null origin", map[string]string{"Origin": "null"}, ErrCSRFOrigin},
+		{"trusted origin", map[string]string{"Origin": "https://admin.example.com", "Sec-Fetch-Site": "same-site"}, nil},
+		{"same referer", map[string]string{"Referer": "https://example.com/form"}, nil},
+		{"other referer", map[string]string{"Referer": "https://evil.com/form"}, ErrCSRFOrigin},
+	}
+	for _, tt := range tests {
+		headers := map[string]string{"Host": "example.com", "X-CSRF-Token": token}
+		for k, v := range tt.headers {
+			headers[k] = v
+		}
+		req := withTokenCookie(t, testRequest("POST", "/account", headers), codec, token)
+		if err, _ := runCSRF(cfg, req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCSRFExemptAndSkip(t *testing.T) {
+	cfg := CSRFConfig{
+		Exempt: []string{"/webhooks/*", "/api/*.json", "*.beacon"},
+		Skip:   func(req *core.Request) bool { return req.GetHeader("Authorization") != "" },
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		exempt  bool
+	}{
+		{"exempt root", "/webhooks", nil, true},
+		{"exempt below", "/webhooks/stripe/events", nil, true},
+		{"prefix is not below", "/webhooksx", nil, false},
+		{"path.Match pattern", "/api/users.json", nil, true},
+		{"path.Match one segment", "/api/v1/users.json", nil, false},
+		{"file name pattern", "/stats/page.beacon", nil, true},
+		{"skipped", "/account", map[string]string{"Authorization": "Bearer x"}, true},
+		{"protected", "/account", nil, false},
+	}
+	for _, tt := range tests {
+		err, called := runCSRF(cfg, testRequest("POST", tt.path, tt.headers))
+		if called != tt.exempt || (err == nil) != tt.exempt {
+			t.Errorf("%s: handler ran %v (error %v), want exempt %v", tt.name, called, err, tt.exempt)
+		}
+	}
+}
+
+func TestCSRFCookieBoundToSession(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+	const token = "client-token"
+
+	// cookie token bound to the session of the victim
+	victim := testRequest("GET", "/", nil)
+	victim.SetSession(core.NewSession("victim-session"))
+	bound := token + "." + csrfSessionBinding(victim)
+
+	tests := []struct {
+		name    string
+		session string
+		cookie  string
+		want    error
+	}{
+		{"own session", "victim-session", bound, nil},
+		{"planted for another session", "attacker-session", bound, ErrCSRFToken},
+		{"unbound cookie with a session", "victim-session", token, ErrCSRFToken},
+	}
+	for _, tt := range tests {
+		req := testRequest("POST", "/account", map[string]string{"X-CSRF-Token": token})
+		req.SetSession(core.NewSession(tt.session))
+		withTokenCookie(t, req, codec, tt.cookie)
+		if err, _ := runCSRF(CSRFConfig{Codec: codec}, req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCSRFMultipartKeepsBody(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+	const token = "client-token"
+	body := "--xyz\r\nContent-Disposition: form-data; name=\"csrf_token\"\r\n\r\n" + token + "\r\n" +
+		"--xyz\r\nContent-Disposition: form-data; name=\"upload\"; filename=\"a.bin\"\r\n\r\n" + strings.Repeat("x", 4096) + "\r\n--xyz--\r\n"
+
+	req := testRequest("POST", "/upload", map[string]string{"Content-Type": "multipart/form-data; boundary=xyz"})
+	req.Body = io.NopCloser(strings.NewReader(body))
+	withTokenCookie(t, req, codec, token)
+
+	var read string
+	res := testResponse()
+	CSRFWithConfig(CSRFConfig{Codec: codec})(func(req *core.Request, res *core.Response) {
+		b, _ := io.ReadAll(req.Body)
+		read = string(b)
+	})(req, res)
+
+	if res.GetError() != nil {
+		t.Fatalf("error = %v", res.GetError())
+	}
+	if read != body {
+		t.Errorf("handler read %d bytes of the body, want all %d", len(read), len(body))
+	}
+}
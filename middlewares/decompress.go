@@ -0,0 +1,166 @@
+package middlewares
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"squirrel/core"
+	"strings"
+)
+
+/*
+	Decompress middleware
+
+	- clients may upload the body compressed and announce it with Content-Encoding
+	- the middleware wraps req.Body with the matching decompressor
+	- so the handler always reads the plain body
+
+	server.Post("/upload", handler, middlewares.Decompress)
+*/
+
+// DecompressConfig configures the request decompression middleware
+type DecompressConfig struct {
+	// maximum size of the decompressed body in bytes
+	// protects against zip bombs, 0 means 10MB
+	MaxSize int64
+}
+
+// ErrDecompressedTooLarge is returned while reading req.Body
+// once the decompressed body grows beyond DecompressConfig.MaxSize
+// returning it from a HandlerFuncE answers the request with 413
+var ErrDecompressedTooLarge = core.NewHTTPError(413, "Decompressed Body Too Large")
+
+// Decompress decodes gzip and deflate request bodies using the default config
+func Decompress(next core.HandlerFunc) core.HandlerFunc {
+	return DecompressWithConfig(DecompressConfig{})(next)
+}
+
+// DecompressWithConfig
+// returns the request decompression middleware for the given config
+// requests with an unsupported Content-Encoding are answered with 415
+func DecompressWithConfig(cfg DecompressConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 10 << 20
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			header := req.GetHeader("Content-Encoding")
+			if header == "" || req.Body == nil {
+				next(req, res)
+				return
+			}
+
+			// codings are listed in the order they were applied
+			// so they are removed in reverse order
+			codings := strings.Split(header, ",")
+			body := req.Body
+			for i := len(codings) - 1; i >= 0; i-- {
+				coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+				var err error
+				switch coding {
+				case "", "identity":
+					continue
+				case "gzip", "x-gzip":
+					body, err = newGzipBody(body)
+				case "deflate":
+					body, err = newDeflateBody(body)
+				default:
+					res.SetStatus(415)
+					res.SetHeader("Accept-Encoding", "gzip, deflate")
+					res.Write("Unsupported Content-Encoding\n")
+					return
+				}
+
+				if err != nil {
+					res.SetStatus(400)
+					res.Write("Malformed " + coding + " body\n")
+					return
+				}
+			}
+
+			req.Body = &limitedBody{ReadCloser: body, remaining: cfg.MaxSize}
+
+			// the handler sees the decoded body, so the headers describing
+			// the encoded one are no longer true
+			delete(req.Headers, "Content-Encoding")
+			delete(req.Headers, "Content-Length")
+			req.ContentLength = -1
+
+			next(req, res)
+		}
+	}
+}
+
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	var first error
+	for _, c := range d.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func newGzipBody(src io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return &decodedBody{Reader: zr, closers: []io.Closer{zr, src}}, nil
+}
+
+// "deflate" is supposed to be zlib wrapped (RFC 9110 section 8.4.1.2)
+// but plenty of clients send raw deflate, so we look at the header first
+func newDeflateBody(src io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(src)
+	head, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	isZlib := head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0
+	if isZlib {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &decodedBody{Reader: zr, closers: []io.Closer{zr, src}}, nil
+	}
+
+	fr := flate.NewReader(br)
+	return &decodedBody{Reader: fr, closers: []io.Closer{fr, src}}, nil
+}
+
+// limitedBody stops reading once the decompressed body is larger than allowed
+// unlike io.LimitReader it reports an error instead of a silent EOF
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrDecompressedTooLarge
+	}
+
+	// read one byte more than allowed to notice the overflow
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrDecompressedTooLarge
+	}
+	return n, err
+}
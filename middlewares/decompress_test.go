@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"squirrel/core"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func zlibBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func flateBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(`{"name":"squirrel","nuts":42}`)
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		want     string
+	}{
+		{"gzip", "gzip", gzipBytes(plain), 200, string(plain)},
+		{"x-gzip", "x-gzip", gzipBytes(plain), 200, string(plain)},
+		{"zlib deflate", "deflate", zlibBytes(plain), 200, string(plain)},
+		{"raw deflate", "deflate", flateBytes(plain), 200, string(plain)},
+		{"stacked codings", "deflate, gzip", gzipBytes(zlibBytes(plain)), 200, string(plain)},
+		{"identity", "identity", plain, 200, string(plain)},
+		{"no encoding", "", plain, 200, string(plain)},
+		{"unsupported", "br", plain, 415, ""},
+		{"malformed gzip", "gzip", plain, 400, ""},
+	}
+	for _, tt := range tests {
+		req := testRequest("POST", "/upload", map[string]string{
+			"Content-Encoding": tt.encoding,
+			"Content-Length":   "123",
+		})
+		if tt.encoding == "" {
+			delete(req.Headers, "Content-Encoding")
+		}
+		req.Body = io.NopCloser(bytes.NewReader(tt.body))
+		res := testResponse()
+
+		var got string
+		var headers map[string]string
+		DecompressWithConfig(DecompressConfig{})(func(req *core.Request, res *core.Response) {
+			b, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Errorf("%s: read body: %v", tt.name, err)
+			}
+			got = string(b)
+			headers = req.Headers
+		})(req, res)
+
+		if res.GetStatusCode() != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.GetStatusCode(), tt.status)
+		}
+		if got != tt.want {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.status == 200 && tt.encoding != "" {
+			if _, ok := headers["Content-Encoding"]; ok {
+				t.Errorf("%s: Content-Encoding still set for the handler", tt.name)
+			}
+			if _, ok := headers["Content-Length"]; ok {
+				t.Errorf("%s: stale Content-Length still set for the handler", tt.name)
+			}
+		}
+		if tt.status == 415 && res.GetHeader("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("%s: Accept-Encoding = %q", tt.name, res.GetHeader("Accept-Encoding"))
+		}
+	}
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	bomb := gzipBytes([]byte(strings.Repeat("0", 64*1024)))
+
+	tests := []struct {
+		name    string
+		maxSize int64
+		wantErr error
+	}{
+		{"within limit", 64 * 1024, nil},
+		{"exceeds limit", 1024, ErrDecompressedTooLarge},
+	}
+	for _, tt := range tests {
+		req := testRequest("POST", "/upload", map[string]string{"Content-Encoding": "gzip"})
+		req.Body = io.NopCloser(bytes.NewReader(bomb))
+
+		var n int
+		var err error
+		DecompressWithConfig(DecompressConfig{MaxSize: tt.maxSize})(func(req *core.Request, res *core.Response) {
+			var b []byte
+			b, err = io.ReadAll(req.Body)
+			n = len(b)
+		})(req, testResponse())
+
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if int64(n) > tt.maxSize {
+			t.Errorf("%s: read %d bytes, more than MaxSize %d", tt.name, n, tt.maxSize)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"time"
 )
 
-// logger logs the request/response status of the current server
+// logger logs the client ip and the request/response status of the current server
 func Logger(next core.HandlerFunc) core.HandlerFunc {
 	return func(req *core.Request, res *core.Response) {
 		start := time.Now()
@@ -16,7 +16,7 @@ func Logger(next core.HandlerFunc) core.HandlerFunc {
 		if statusCode == 0 {
 			statusCode = 200
 		}
-		logMessage := fmt.Sprintf("[%s] - %s - %s - %d - %s", start.Format("2006-01-02 15:04:05"), req.Method, req.Path, statusCode, duration)
+		logMessage := fmt.Sprintf("[%s] - %s - %s - %s - %d - %s", start.Format("2006-01-02 15:04:05"), req.ClientIP(), req.Method, req.Path, statusCode, duration)
 
 		fmt.Println(logMessage)
 	}
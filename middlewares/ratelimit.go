@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"log"
+	"math"
+	"squirrel/core"
+	"squirrel/ratelimit"
+	"strconv"
+	"time"
+)
+
+/*
+	Rate limit middleware
+
+	- every client gets its own limit, identified by a key: ip address, header, user...
+	- responses carry RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+	- clients over the limit get 429 Too Many Requests with Retry-After
+	- if the store fails the request is let through, a broken backend shouldn't take the site down
+
+	server.Use(middlewares.RateLimit(100, time.Minute))
+*/
+
+// RateLimitConfig configures the rate limit middleware
+type RateLimitConfig struct {
+	// decides about every request
+	// defaults to a token bucket of 100 requests per minute in memory
+	Limiter ratelimit.Limiter
+	// identifies the client, RateLimitByIP by default
+	// requests with an empty key are not limited
+	Key func(req *core.Request) string
+	// skips the limit for the request when it returns true
+	Skip func(req *core.Request) bool
+	// don't send the RateLimit-* headers, 429 responses still get Retry-After
+	HideHeaders bool
+}
+
+// ErrTooManyRequests is the error for clients over their limit
+var ErrTooManyRequests = core.NewHTTPError(429, "Too Many Requests")
+
+// RateLimit
+// allows every ip address limit requests per period using a token bucket in memory
+// server.Use(middlewares.RateLimit(100, time.Minute))
+func RateLimit(limit int, period time.Duration) func(core.HandlerFunc) core.HandlerFunc {
+	return RateLimitWithConfig(RateLimitConfig{
+		Limiter: ratelimit.NewTokenBucket(limit, period, ratelimit.NewMemoryStore(0)),
+	})
+}
+
+// RateLimitWithConfig
+// returns the rate limit middleware for the given config
+//
+//	server.Post("/login", login, middlewares.RateLimitWithConfig(middlewares.RateLimitConfig{
+//		Limiter: ratelimit.NewSlidingWindow(5, time.Minute, ratelimit.NewMemoryStore(100_000)),
+//		Key:     middlewares.RateLimitByHeader("X-API-Key"),
+//	}))
+func RateLimitWithConfig(cfg RateLimitConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Limiter == nil {
+		cfg.Limiter = ratelimit.NewTokenBucket(100, time.Minute, ratelimit.NewMemoryStore(0))
+	}
+	if cfg.Key == nil {
+		cfg.Key = RateLimitByIP
+	}
+	policy := cfg.Limiter.Policy()
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			if cfg.Skip != nil && cfg.Skip(req) {
+				next(req, res)
+				return
+			}
+			key := cfg.Key(req)
+			if key == "" {
+				next(req, res)
+				return
+			}
+
+			result, err := cfg.Limiter.Allow(key)
+			if err != nil {
+				log.Printf("ratelimit: %v", err)
+				next(req, res)
+				return
+			}
+
+			if !cfg.HideHeaders {
+				res.SetHeader("RateLimit-Limit", strconv.Itoa(result.Limit))
+				res.SetHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				res.SetHeader("RateLimit-Reset", ceilSeconds(result.Reset))
+				res.SetHeader("RateLimit-Policy", policy)
+			}
+
+			if !result.Allowed {
+				res.SetHeader("Retry-After", ceilSeconds(result.RetryAfter))
+				res.SetError(ErrTooManyRequests)
+				return
+			}
+			next(req, res)
+		}
+	}
+}
+
+// RateLimitByIP
+// keys the limit by the ip address of the client
+// behind a proxy configure server.TrustProxies, otherwise all clients share the proxy's limit
+func RateLimitByIP(req *core.Request) string {
+	return req.ClientIP()
+}
+
+// RateLimitByHeader
+// keys the limit by a request header, e.g. an api key
+// requests without the header are limited by ip address
+func RateLimitByHeader(name string) func(req *core.Request) string {
+	return func(req *core.Request) string {
+		if value := req.GetHeader(name); value != "" {
+			return name + ":" + value
+		}
+		return RateLimitByIP(req)
+	}
+}
+
+// RateLimitByUser
+// keys the limit by the request local set by the auth middleware, e.g. "user"
+// anonymous requests are limited by ip address
+func RateLimitByUser(localKey string) func(req *core.Request) string {
+	return func(req *core.Request) string {
+		if user := req.Get(localKey); user != nil {
+			if id, ok := user.(interface{ String() string }); ok {
+				return "user:" + id.String()
+			}
+			if id, ok := user.(string); ok && id != "" {
+				return "user:" + id
+			}
+		}
+		return RateLimitByIP(req)
+	}
+}
+
+// whole seconds for the headers, rounded up so clients never retry too early
+func ceilSeconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"errors"
+	"squirrel/core"
+	"squirrel/ratelimit"
+	"testing"
+	"time"
+)
+
+// limiter with a fixed answer
+type stubLimiter struct {
+	result ratelimit.Result
+	err    error
+}
+
+func (l stubLimiter) Allow(key string) (ratelimit.Result, error) { return l.result, l.err }
+func (l stubLimiter) Policy() string                             { return "10;w=60" }
+
+func byClientHeader(req *core.Request) string { return req.GetHeader("X-Client") }
+
+func TestRateLimitHeaders(t *testing.T) {
+	allowed := ratelimit.Result{Allowed: true, Limit: 10, Remaining: 7, Reset: 1500 * time.Millisecond}
+	denied := ratelimit.Result{Limit: 10, Reset: 42 * time.Second, RetryAfter: 2100 * time.Millisecond}
+
+	tests := []struct {
+		name    string
+		cfg     RateLimitConfig
+		client  string
+		handler bool
+		err     error
+		headers map[string]string
+	}{
+		{"allowed", RateLimitConfig{Limiter: stubLimiter{result: allowed}}, "a", true, nil, map[string]string{
+			"RateLimit-Limit": "10", "RateLimit-Remaining": "7", "RateLimit-Reset": "2", "RateLimit-Policy": "10;w=60", "Retry-After": "",
+		}},
+		{"denied", RateLimitConfig{Limiter: stubLimiter{result: denied}}, "a", false, ErrTooManyRequests, map[string]string{
+			"RateLimit-Remaining": "0", "RateLimit-Reset": "42", "Retry-After": "3",
+		}},
+		{"hidden headers", RateLimitConfig{Limiter: stubLimiter{result: denied}, HideHeaders: true}, "a", false, ErrTooManyRequests, map[string]string{
+			"RateLimit-Limit": "", "RateLimit-Policy": "", "Retry-After": "3",
+		}},
+		{"store fails open", RateLimitConfig{Limiter: stubLimiter{err: errors.New("redis down")}}, "a", true, nil, map[string]string{
+			"RateLimit-Limit": "", "Retry-After": "",
+		}},
+		{"no key", RateLimitConfig{Limiter: stubLimiter{result: denied}}, "", true, nil, map[string]string{
+			"RateLimit-Limit": "",
+		}},
+		{"skipped", RateLimitConfig{Limiter: stubLimiter{result: denied}, Skip: func(*core.Request) bool { return true }}, "a", true, nil, map[string]string{
+			"Retry-After": "",
+		}},
+	}
+	for _, tt := range tests {
+		tt.cfg.Key = byClientHeader
+		called := false
+		res := testResponse()
+		RateLimitWithConfig(tt.cfg)(func(req *core.Request, res *core.Response) { called = true })(
+			testRequest("GET", "/", map[string]string{"X-Client": tt.client}), res)
+
+		if called != tt.handler || !errors.Is(res.GetError(), tt.err) {
+			t.Errorf("%s: handler ran %v, error %v, want %v, %v", tt.name, called, res.GetError(), tt.handler, tt.err)
+		}
+		for name, want := range tt.headers {
+			if got := res.GetHeader(name); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := ratelimit.NewMemoryStore(0)
+	defer store.Close()
+	store.Now = func() time.Time { return now }
+	limiter := ratelimit.NewTokenBucket(2, time.Minute, store)
+	limiter.Now = store.Now
+	mw := RateLimitWithConfig(RateLimitConfig{Limiter: limiter, Key: byClientHeader})
+
+	tests := []struct {
+		client     string
+		wait       time.Duration
+		status     int
+		retryAfter string
+	}{
+		{"a", 0, 0, ""},
+		{"a", 0, 0, ""},
+		{"a", 0, 429, "30"},
+		// other clients have their own bucket
+		{"b", 0, 0, ""},
+		{"a", 30 * time.Second, 0, ""},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.wait)
+		res := testResponse()
+		mw(func(req *core.Request, res *core.Response) {})(testRequest("GET", "/", map[string]string{"X-Client": tt.client}), res)
+
+		status := 0
+		var httpErr *core.HTTPError
+		if errors.As(res.GetError(), &httpErr) {
+			status = httpErr.Code
+		}
+		if status != tt.status || res.GetHeader("Retry-After") != tt.retryAfter {
+			t.Errorf("#%d %s: status %d, Retry-After %q, want %d, %q", i, tt.client, status, res.GetHeader("Retry-After"), tt.status, tt.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"encoding/base64"
+	"squirrel/core"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Secure headers middleware
+
+	- sets HSTS, Content-Security-Policy, X-Content-Type-Options, X-Frame-Options,
+	  Referrer-Policy, Permissions-Policy and the Cross-Origin-*-Policy headers
+	- "{nonce}" in the policy is replaced by a random nonce per request,
+	  templates get it as {{.cspNonce}}: <script nonce="{{.cspNonce}}">
+	- routes change the headers with SecureOverride, the nonce stays the same
+	- CSPReportHandler receives the violation reports of browsers
+
+	server.Use(middlewares.Secure)
+*/
+
+// SecureOff turns a header of the SecureConfig off
+const SecureOff = "off"
+
+// DefaultCSP is the Content-Security-Policy used when the config has none
+// scripts and styles need the nonce of the request or have to come from the own origin
+const DefaultCSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+	"img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'self'"
+
+// SecureConfig configures the secure headers middleware
+// empty fields use the defaults, SecureOff doesn't send the header
+type SecureConfig struct {
+	// Strict-Transport-Security max-age, one year by default, negative doesn't send the header
+	HSTSMaxAge time.Duration
+	// apply HSTS to subdomains, and ask for the browsers' preload list
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// Content-Security-Policy, DefaultCSP by default
+	ContentSecurityPolicy string
+	// send the policy as Content-Security-Policy-Report-Only, to try it without breaking pages
+	CSPReportOnly bool
+	// where browsers send violation reports, e.g. "/csp-report" served by CSPReportHandler
+	// added as report-uri and report-to, unless the policy has one of them already
+	CSPReportURI string
+	// X-Content-Type-Options, "nosniff" by default
+	ContentTypeOptions string
+	// X-Frame-Options, "SAMEORIGIN" by default
+	// for newer browsers use frame-ancestors of the policy
+	FrameOptions string
+	// Referrer-Policy, "strict-origin-when-cross-origin" by default
+	ReferrerPolicy string
+	// Permissions-Policy, "camera=(), microphone=(), geolocation=()" by default
+	PermissionsPolicy string
+	// Cross-Origin-Opener-Policy, "same-origin" by default
+	CrossOriginOpenerPolicy string
+	// Cross-Origin-Resource-Policy, "same-origin" by default
+	CrossOriginResourcePolicy string
+	// Cross-Origin-Embedder-Policy, not sent by default ("require-corp" breaks most third party embeds)
+	CrossOriginEmbedderPolicy string
+}
+
+// request local holding the config of the request, for SecureOverride
+const secureConfigKey = "_secure"
+
+// Secure sets the security headers with the default config
+// server.Use(middlewares.Secure)
+func Secure(next core.HandlerFunc) core.HandlerFunc {
+	return SecureWithConfig(SecureConfig{})(next)
+}
+
+// SecureWithConfig
+// returns the secure headers middleware for the given config
+//
+//	server.Use(middlewares.SecureWithConfig(middlewares.SecureConfig{
+//		HSTSIncludeSubdomains: true,
+//		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}' https://cdn.example.com",
+//		CSPReportURI:          "/csp-report",
+//	}))
+func SecureWithConfig(cfg SecureConfig) func(core.HandlerFunc) core.HandlerFunc {
+	cfg = cfg.withDefaults()
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			nonce := base64.StdEncoding.EncodeToString(randomToken()[:16])
+			req.Set("cspNonce", nonce)
+			req.Set(secureConfigKey, cfg)
+			cfg.apply(res, nonce)
+			next(req, res)
+		}
+	}
+}
+
+// SecureOverride
+// changes the security headers for a route, on top of the config of the global middleware
+//
+//	server.Get("/embed", embed, middlewares.SecureOverride(func(cfg *middlewares.SecureConfig) {
+//		cfg.FrameOptions = middlewares.SecureOff
+//		cfg.ContentSecurityPolicy = "frame-ancestors https://partner.example.com"
+//	}))
+func SecureOverride(override func(cfg *SecureConfig)) func(core.HandlerFunc) core.HandlerFunc {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			cfg, ok := req.Get(secureConfigKey).(SecureConfig)
+			if !ok {
+				cfg = SecureConfig{}.withDefaults()
+			}
+			override(&cfg)
+			cfg = cfg.withDefaults()
+
+			nonce := CSPNonce(req)
+			if nonce == "" {
+				nonce = base64.StdEncoding.EncodeToString(randomToken()[:16])
+				req.Set("cspNonce", nonce)
+			}
+			req.Set(secureConfigKey, cfg)
+			cfg.apply(res, nonce)
+			next(req, res)
+		}
+	}
+}
+
+// CSPNonce
+// returns the nonce of the request for scripts and styles
+// templates can also use {{.cspNonce}}
+func CSPNonce(req *core.Request) string {
+	nonce, _ := req.Get("cspNonce").(string)
+	return nonce
+}
+
+func (cfg SecureConfig) withDefaults() SecureConfig {
+	if cfg.HSTSMaxAge == 0 {
+		cfg.HSTSMaxAge = 365 * 24 * time.Hour
+	}
+	defaults := []struct {
+		field *string
+		value string
+	}{
+		{&cfg.ContentSecurityPolicy, DefaultCSP},
+		{&cfg.ContentTypeOptions, "nosniff"},
+		{&cfg.FrameOptions, "SAMEORIGIN"},
+		{&cfg.ReferrerPolicy, "strict-origin-when-cross-origin"},
+		{&cfg.PermissionsPolicy, "camera=(), microphone=(), geolocation=()"},
+		{&cfg.CrossOriginOpenerPolicy, "same-origin"},
+		{&cfg.CrossOriginResourcePolicy, "same-origin"},
+		{&cfg.CrossOriginEmbedderPolicy, SecureOff},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.value
+		}
+	}
+	return cfg
+}
+
+// sets the headers of the config, headers turned off are removed (set by an earlier config)
+func (cfg SecureConfig) apply(res *core.Response, nonce string) {
+	set := func(name, value string) {
+		if value == SecureOff {
+			res.DelHeader(name)
+			return
+		}
+		res.SetHeader(name, value)
+	}
+
+	hsts := SecureOff
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge/time.Second))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	set("Strict-Transport-Security", hsts)
+
+	csp := cfg.ContentSecurityPolicy
+	reporting := false
+	if csp != SecureOff {
+		csp = strings.ReplaceAll(csp, "{nonce}", nonce)
+		// a policy with its own report directives keeps them (and its Reporting-Endpoints)
+		reporting = hasReportDirective(csp)
+		if cfg.CSPReportURI != "" && !reporting {
+			csp += "; report-uri " + cfg.CSPReportURI + "; report-to csp-endpoint"
+			res.SetHeader("Reporting-Endpoints", `csp-endpoint="`+cfg.CSPReportURI+`"`)
+			reporting = true
+		}
+	}
+	if !reporting {
+		res.DelHeader("Reporting-Endpoints")
+	}
+	if cfg.CSPReportOnly {
+		res.DelHeader("Content-Security-Policy")
+		set("Content-Security-Policy-Report-Only", csp)
+	} else {
+		res.DelHeader("Content-Security-Policy-Report-Only")
+		set("Content-Security-Policy", csp)
+	}
+
+	set("X-Content-Type-Options", cfg.ContentTypeOptions)
+	set("X-Frame-Options", cfg.FrameOptions)
+	set("Referrer-Policy", cfg.ReferrerPolicy)
+	set("Permissions-Policy", cfg.PermissionsPolicy)
+	set("Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy)
+	set("Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy)
+	set("Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy)
+}
+
+// reports whether the policy already names where reports go
+func hasReportDirective(csp string) bool {
+	for _, directive := range strings.Split(csp, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), " ")
+		if strings.EqualFold(name, "report-uri") || strings.EqualFold(name, "report-to") {
+			return true
+		}
+	}
+	return false
+}
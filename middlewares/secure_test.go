@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"squirrel/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runs the middlewares in order, returns the response and the nonce the handler saw
+func runSecure(mws ...func(core.HandlerFunc) core.HandlerFunc) (*core.Response, string) {
+	var nonce string
+	handler := func(req *core.Request, res *core.Response) { nonce = CSPNonce(req) }
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	res := testResponse()
+	handler(testRequest("GET", "/", nil), res)
+	return res, nonce
+}
+
+func TestSecureDefaults(t *testing.T) {
+	res, nonce := runSecure(Secure)
+
+	want := map[string]string{
+		"Strict-Transport-Security":    "max-age=31536000",
+		"X-Content-Type-Options":       "nosniff",
+		"X-Frame-Options":              "SAMEORIGIN",
+		"Referrer-Policy":              "strict-origin-when-cross-origin",
+		"Permissions-Policy":           "camera=(), microphone=(), geolocation=()",
+		"Cross-Origin-Opener-Policy":   "same-origin",
+		"Cross-Origin-Resource-Policy": "same-origin",
+		"Cross-Origin-Embedder-Policy": "",
+		"Reporting-Endpoints":          "",
+		"Content-Security-Policy":      strings.ReplaceAll(DefaultCSP, "{nonce}", nonce),
+	}
+	for name, value := range want {
+		if got := res.GetHeader(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if nonce == "" || strings.Contains(res.GetHeader("Content-Security-Policy"), "{nonce}") {
+		t.Errorf("nonce %q not substituted: %q", nonce, res.GetHeader("Content-Security-Policy"))
+	}
+
+	_, other := runSecure(Secure)
+	if other == nonce {
+		t.Errorf("two requests got the same nonce %q", nonce)
+	}
+}
+
+func TestSecureConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  SecureConfig
+		want map[string]string
+	}{
+		{"hsts options", SecureConfig{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true, HSTSPreload: true}, map[string]string{
+			"Strict-Transport-Security": "max-age=3600; includeSubDomains; preload",
+		}},
+		{"hsts off", SecureConfig{HSTSMaxAge: -1}, map[string]string{"Strict-Transport-Security": ""}},
+		{"headers off", SecureConfig{FrameOptions: SecureOff, ContentSecurityPolicy: SecureOff}, map[string]string{
+			"X-Frame-Options": "", "Content-Security-Policy": "", "X-Content-Type-Options": "nosniff",
+		}},
+		{"report only", SecureConfig{ContentSecurityPolicy: "default-src 'self'", CSPReportOnly: true}, map[string]string{
+			"Content-Security-Policy": "", "Content-Security-Policy-Report-Only": "default-src 'self'",
+		}},
+		{"report uri", SecureConfig{ContentSecurityPolicy: "default-src 'self'", CSPReportURI: "/csp-report"}, map[string]string{
+			"Content-Security-Policy": "default-src 'self'; report-uri /csp-report; report-to csp-endpoint",
+			"Reporting-Endpoints":     `csp-endpoint="/csp-report"`,
+		}},
+		{"own report directive wins", SecureConfig{ContentSecurityPolicy: "default-src 'self'; report-uri https://reports.example.com", CSPReportURI: "/csp-report"}, map[string]string{
+			"Content-Security-Policy": "default-src 'self'; report-uri https://reports.example.com",
+			"Reporting-Endpoints":     "",
+		}},
+		{"own report-to", SecureConfig{ContentSecurityPolicy: "default-src 'self'; Report-To main", CSPReportURI: "/csp-report"}, map[string]string{
+			"Content-Security-Policy": "default-src 'self'; Report-To main",
+		}},
+	}
+	for _, tt := range tests {
+		res, _ := runSecure(SecureWithConfig(tt.cfg))
+		for name, value := range tt.want {
+			if got := res.GetHeader(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, value)
+			}
+		}
+	}
+}
+
+func TestSecureOverride(t *testing.T) {
+	global := SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: "script-src 'nonce-{nonce}'",
+		CSPReportURI:          "/csp-report",
+	})
+
+	tests := []struct {
+		name     string
+		override func(cfg *SecureConfig)
+		want     map[string]string // "{nonce}" is replaced by the nonce of the request
+	}{
+		{"keeps the global config", func(cfg *SecureConfig) { cfg.FrameOptions = SecureOff }, map[string]string{
+			"X-Frame-Options":         "",
+			"Content-Security-Policy": "script-src 'nonce-{nonce}'; report-uri /csp-report; report-to csp-endpoint",
+		}},
+		{"new policy, same nonce", func(cfg *SecureConfig) { cfg.ContentSecurityPolicy = "style-src 'nonce-{nonce}'" }, map[string]string{
+			"Content-Security-Policy": "style-src 'nonce-{nonce}'; report-uri /csp-report; report-to csp-endpoint",
+		}},
+		{"switch to report only", func(cfg *SecureConfig) { cfg.CSPReportOnly = true }, map[string]string{
+			"Content-Security-Policy":             "",
+			"Content-Security-Policy-Report-Only": "script-src 'nonce-{nonce}'; report-uri /csp-report; report-to csp-endpoint",
+		}},
+		{"policy off drops the reporting endpoint", func(cfg *SecureConfig) { cfg.ContentSecurityPolicy = SecureOff }, map[string]string{
+			"Content-Security-Policy": "", "Reporting-Endpoints": "",
+		}},
+	}
+	for _, tt := range tests {
+		var globalNonce string
+		capture := func(next core.HandlerFunc) core.HandlerFunc {
+			return func(req *core.Request, res *core.Response) {
+				globalNonce = CSPNonce(req)
+				next(req, res)
+			}
+		}
+		res, nonce := runSecure(global, capture, SecureOverride(tt.override))
+		if nonce == "" || nonce != globalNonce {
+			t.Errorf("%s: nonce %q after the override, %q before", tt.name, nonce, globalNonce)
+		}
+		for name, value := range tt.want {
+			value = strings.ReplaceAll(value, "{nonce}", nonce)
+			if got := res.GetHeader(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, got, value)
+			}
+		}
+	}
+
+	// without the global middleware the override starts from the defaults
+	res, nonce := runSecure(SecureOverride(func(cfg *SecureConfig) { cfg.ReferrerPolicy = "no-referrer" }))
+	if res.GetHeader("Referrer-Policy") != "no-referrer" || res.GetHeader("X-Content-Type-Options") != "nosniff" || nonce == "" {
+		t.Errorf("override alone: Referrer-Policy %q, X-Content-Type-Options %q, nonce %q",
+			res.GetHeader("Referrer-Policy"), res.GetHeader("X-Content-Type-Options"), nonce)
+	}
+}
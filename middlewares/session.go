@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"log"
+	"squirrel/cookies"
+	"squirrel/core"
+	"squirrel/sessions"
+	"time"
+)
+
+/*
+	Session middleware
+
+	- loads the session of the session cookie from the store, or starts a new one
+	- handlers use it through req.Session()
+	- afterwards the session is saved and the cookie is set
+	- new sessions nobody wrote to are not saved, so visitors don't get a cookie for nothing
+
+	server.Use(middlewares.Session(sessions.NewMemoryStore(time.Minute)))
+*/
+
+// SessionConfig configures the session middleware
+type SessionConfig struct {
+	Store sessions.Store
+	// template of the session cookie
+	// defaults: Name "squirrel_session", Path "/", HttpOnly, SameSite=Lax
+	// without MaxAge the cookie is deleted when the browser closes
+	Cookie cookies.Cookie
+	// sessions without a request for this long expire
+	// 0 means 30 minutes, negative disables the idle timeout
+	IdleTimeout time.Duration
+	// sessions expire this long after they were created, no matter how active they are
+	// 0 means 24 hours, negative disables the absolute timeout
+	AbsoluteTimeout time.Duration
+}
+
+// Session
+// returns the session middleware with the default config for the store
+func Session(store sessions.Store) func(core.HandlerFunc) core.HandlerFunc {
+	return SessionWithConfig(SessionConfig{Store: store})
+}
+
+// SessionWithConfig
+// returns the session middleware for the given config
+//
+//	server.Use(middlewares.SessionWithConfig(middlewares.SessionConfig{
+//		Store:       store,
+//		Cookie:      cookies.Cookie{Name: "sid", Secure: true},
+//		IdleTimeout: 15 * time.Minute,
+//	}))
+func SessionWithConfig(cfg SessionConfig) func(core.HandlerFunc) core.HandlerFunc {
+	if cfg.Store == nil {
+		panic("middlewares: SessionConfig.Store is required")
+	}
+	if cfg.Cookie.Name == "" {
+		cfg.Cookie.Name = "squirrel_session"
+	}
+	if cfg.Cookie.Path == "" {
+		cfg.Cookie.Path = "/"
+	}
+	if cfg.Cookie.SameSite == 0 {
+		cfg.Cookie.SameSite = cookies.SameSiteLaxMode
+	}
+	cfg.Cookie.HttpOnly = true
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.AbsoluteTimeout == 0 {
+		cfg.AbsoluteTimeout = 24 * time.Hour
+	}
+
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			token := ""
+			if c := req.GetCookie(cfg.Cookie.Name); c != nil {
+				token = c.Value
+			}
+
+			session := loadSession(cfg, token)
+			if session == nil {
+				session = core.NewSession(sessions.NewID())
+			}
+			req.SetSession(session)
+
+			next(req, res)
+
+			saveSession(cfg, res, session, token)
+		}
+	}
+}
+
+// returns the session of the token, nil if there is none or it expired
+func loadSession(cfg SessionConfig, token string) *core.Session {
+	if token == "" {
+		return nil
+	}
+
+	session, err := cfg.Store.Load(token)
+	if err != nil {
+		log.Printf("session: loading session: %v", err)
+		return nil
+	}
+	if session == nil {
+		return nil
+	}
+
+	now := time.Now()
+	idle := cfg.IdleTimeout > 0 && now.Sub(session.LastSeen) > cfg.IdleTimeout
+	absolute := cfg.AbsoluteTimeout > 0 && now.Sub(session.CreatedAt) > cfg.AbsoluteTimeout
+	if idle || absolute {
+		cfg.Store.Delete(token)
+		return nil
+	}
+	return session
+}
+
+func saveSession(cfg SessionConfig, res *core.Response, session *core.Session, token string) {
+	if session.Destroyed() {
+		if token != "" {
+			cfg.Store.Delete(token)
+			expired := cfg.Cookie
+			expired.Value = ""
+			expired.MaxAge = -1
+			res.SetCookie(expired)
+		}
+		return
+	}
+
+	// nothing worth a cookie
+	if session.IsNew() && !session.Modified() {
+		return
+	}
+
+	// new id against session fixation, the old one must not work anymore
+	if session.Regenerated() && !session.IsNew() {
+		cfg.Store.Delete(token)
+		session.ID = sessions.NewID()
+	}
+
+	// without idle timeout unchanged sessions don't have to be written again
+	if !session.Modified() && cfg.IdleTimeout < 0 {
+		return
+	}
+
+	now := time.Now()
+	session.LastSeen = now
+
+	var ttl time.Duration
+	if cfg.IdleTimeout > 0 {
+		ttl = cfg.IdleTimeout
+	}
+	if cfg.AbsoluteTimeout > 0 {
+		remaining := session.CreatedAt.Add(cfg.AbsoluteTimeout).Sub(now)
+		if ttl == 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	newToken, err := cfg.Store.Save(session, ttl)
+	if err != nil {
+		log.Printf("session: saving session: %v", err)
+		if res.GetError() == nil {
+			res.SetError(err)
+		}
+		return
+	}
+
+	// persistent cookies are refreshed on every save, session cookies only when the token changes
+	if newToken != token || cfg.Cookie.MaxAge > 0 {
+		cookie := cfg.Cookie
+		cookie.Value = newToken
+		res.SetCookie(cookie)
+	}
+}
@@ -0,0 +1,182 @@
+package middlewares
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"squirrel/cookies"
+	"squirrel/core"
+	"squirrel/sessions"
+	"testing"
+	"time"
+)
+
+// runs the handler behind the middleware and returns the cookies set by the response
+func sendWithCookie(t *testing.T, mw func(core.HandlerFunc) core.HandlerFunc, handler core.HandlerFunc, cookie string) []*http.Cookie {
+	t.Helper()
+	req := testRequest("GET", "/", nil)
+	if cookie != "" {
+		req.Cookies = []*cookies.Cookie{{Name: "squirrel_session", Value: cookie}}
+	}
+
+	client, server := net.Pipe()
+	res := core.NewResponse(&server)
+	mw(handler)(req, res)
+
+	done := make(chan struct{})
+	go func() {
+		res.Send()
+		server.Close()
+		close(done)
+	}()
+	defer func() { <-done }()
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Cookies()
+}
+
+func sessionCookie(cookies []*http.Cookie) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == "squirrel_session" {
+			return c
+		}
+	}
+	return nil
+}
+
+// stores a session that was created and last used at the given times
+func storedSession(t *testing.T, store sessions.Store, created, lastSeen time.Time) string {
+	t.Helper()
+	s := core.NewSession(sessions.NewID())
+	s.Set("user", "ada")
+	s.CreatedAt, s.LastSeen = created, lastSeen
+	token, err := store.Save(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	store := sessions.NewMemoryStore(time.Hour)
+	defer store.Close()
+	mw := SessionWithConfig(SessionConfig{Store: store, IdleTimeout: 10 * time.Minute, AbsoluteTimeout: time.Hour})
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		created  time.Time
+		lastSeen time.Time
+		valid    bool
+	}{
+		{"active", now.Add(-30 * time.Minute), now.Add(-time.Minute), true},
+		{"idle", now.Add(-30 * time.Minute), now.Add(-11 * time.Minute), false},
+		{"too old", now.Add(-2 * time.Hour), now.Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		token := storedSession(t, store, tt.created, tt.lastSeen)
+
+		var user any
+		var isNew bool
+		sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+			user, isNew = req.Session().Get("user"), req.Session().IsNew()
+		}, token)
+
+		if valid := user == "ada" && !isNew; valid != tt.valid {
+			t.Errorf("%s: session valid = %v, want %v", tt.name, valid, tt.valid)
+		}
+		if s, _ := store.Load(token); (s != nil) != tt.valid {
+			t.Errorf("%s: expired session still stored", tt.name)
+		}
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	store := sessions.NewMemoryStore(time.Hour)
+	defer store.Close()
+	mw := Session(store)
+
+	// visitors without session data don't get a cookie
+	got := sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {}, "")
+	if sessionCookie(got) != nil {
+		t.Error("cookie set for an untouched session")
+	}
+
+	// the first write starts the session
+	got = sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+		req.Session().Set("user", "ada")
+	}, "")
+	c := sessionCookie(got)
+	if c == nil || !c.HttpOnly || c.Path != "/" || c.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("session cookie = %+v", c)
+	}
+	token := c.Value
+
+	// login: a new id, the old one is gone
+	got = sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+		req.Session().Regenerate()
+	}, token)
+	c = sessionCookie(got)
+	if c == nil || c.Value == token {
+		t.Fatalf("regenerate kept the id: %+v", c)
+	}
+	if s, _ := store.Load(token); s != nil {
+		t.Error("old session id still valid after Regenerate")
+	}
+	if s, _ := store.Load(c.Value); s == nil || s.Get("user") != "ada" {
+		t.Error("values lost on Regenerate")
+	}
+	token = c.Value
+
+	// logout: gone from the store and the client
+	got = sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+		req.Session().Destroy()
+	}, token)
+	c = sessionCookie(got)
+	if c == nil || c.MaxAge != -1 {
+		t.Errorf("Destroy didn't expire the cookie: %+v", c)
+	}
+	if s, _ := store.Load(token); s != nil {
+		t.Error("session still stored after Destroy")
+	}
+}
+
+func TestSessionFlash(t *testing.T) {
+	store := sessions.NewMemoryStore(time.Hour)
+	defer store.Close()
+	mw := Session(store)
+
+	got := sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+		req.Session().AddFlash("saved")
+		req.Session().AddFlash("check your mail", "info")
+		if len(req.Session().Flashes()) != 0 {
+			t.Error("flash readable in the request that added it")
+		}
+	}, "")
+	token := sessionCookie(got).Value
+
+	tests := []struct {
+		name string
+		want []any
+		info []any
+	}{
+		{"next request", []any{"saved"}, []any{"check your mail"}},
+		{"request after", nil, nil},
+	}
+	for _, tt := range tests {
+		var flashes, info []any
+		sendWithCookie(t, mw, func(req *core.Request, res *core.Response) {
+			flashes, info = req.Session().Flashes(), req.Session().Flashes("info")
+		}, token)
+		if len(flashes) != len(tt.want) || (len(flashes) > 0 && flashes[0] != tt.want[0]) {
+			t.Errorf("%s: Flashes() = %v, want %v", tt.name, flashes, tt.want)
+		}
+		if len(info) != len(tt.info) {
+			t.Errorf("%s: Flashes(info) = %v, want %v", tt.name, info, tt.info)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// TokenBucket
+// every key has a bucket of limit tokens, every request takes one
+// the bucket refills at limit tokens per period, so bursts up to limit are allowed
+type TokenBucket struct {
+	limit  int
+	period time.Duration
+	store  Store
+	// Now returns the current time, time.Now unless replaced (e.g. by tests)
+	Now func() time.Time
+}
+
+// NewTokenBucket
+// allows limit requests per period, in bursts of up to limit requests
+func NewTokenBucket(limit int, period time.Duration, store Store) *TokenBucket {
+	if limit <= 0 || period <= 0 {
+		panic("ratelimit: limit and period must be positive")
+	}
+	return &TokenBucket{limit: limit, period: period, store: store, Now: time.Now}
+}
+
+func (t *TokenBucket) Allow(key string) (Result, error) {
+	limit := float64(t.limit)
+	rate := limit / t.period.Seconds() // tokens per second
+	res := Result{Limit: t.limit}
+
+	err := t.store.Update(key, t.period, func(s *State) {
+		now := t.Now()
+		if s.Time.IsZero() {
+			s.Value = limit
+		} else {
+			s.Value = math.Min(limit, s.Value+now.Sub(s.Time).Seconds()*rate)
+		}
+		s.Time = now
+
+		if s.Value >= 1 {
+			s.Value--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = seconds((1 - s.Value) / rate)
+		}
+		res.Remaining = int(s.Value)
+		res.Reset = seconds((limit - s.Value) / rate)
+	})
+	return res, err
+}
+
+func (t *TokenBucket) Policy() string {
+	return fmt.Sprintf("%d;w=%d", t.limit, int(t.period.Seconds()))
+}
+
+// SlidingWindow
+// allows limit requests in any window
+// the count of the previous window is weighted by how much of it still overlaps,
+// so clients can't send twice the limit around the boundary of two windows
+type SlidingWindow struct {
+	limit  int
+	window time.Duration
+	store  Store
+	// Now returns the current time, time.Now unless replaced (e.g. by tests)
+	Now func() time.Time
+}
+
+// NewSlidingWindow
+// allows limit requests per window
+func NewSlidingWindow(limit int, window time.Duration, store Store) *SlidingWindow {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: limit and window must be positive")
+	}
+	return &SlidingWindow{limit: limit, window: window, store: store, Now: time.Now}
+}
+
+func (w *SlidingWindow) Allow(key string) (Result, error) {
+	limit := float64(w.limit)
+	res := Result{Limit: w.limit}
+
+	err := w.store.Update(key, 2*w.window, func(s *State) {
+		now := w.Now()
+		switch {
+		case s.Time.IsZero() || now.Sub(s.Time) >= 2*w.window:
+			s.Value, s.Prev, s.Time = 0, 0, now
+		case now.Sub(s.Time) >= w.window:
+			s.Prev, s.Value, s.Time = s.Value, 0, s.Time.Add(w.window)
+		}
+
+		elapsed := now.Sub(s.Time)
+		overlap := 1 - elapsed.Seconds()/w.window.Seconds()
+		estimate := s.Prev*overlap + s.Value
+
+		if estimate+1 <= limit {
+			s.Value++
+			estimate++
+			res.Allowed = true
+		} else {
+			res.RetryAfter = w.retryAfter(s, elapsed)
+		}
+		res.Remaining = int(math.Max(0, math.Floor(limit-estimate)))
+		// requests of this window weigh in until the end of the next one
+		res.Reset = s.Time.Add(2 * w.window).Sub(now)
+	})
+	return res, err
+}
+
+// time until the weighted count dropped enough for one more request
+func (w *SlidingWindow) retryAfter(s *State, elapsed time.Duration) time.Duration {
+	free := float64(w.limit) - 1
+
+	// still in this window: Prev*(1 - t/W) + Value <= limit-1
+	if s.Value <= free && s.Prev > 0 {
+		t := seconds(w.window.Seconds() * (1 - (free-s.Value)/s.Prev))
+		return t - elapsed
+	}
+
+	// in the next window, where this one weighs Value*(1 - t/W)
+	t := seconds(w.window.Seconds() * math.Max(0, 1-free/s.Value))
+	return w.window - elapsed + t
+}
+
+func (w *SlidingWindow) Policy() string {
+	return fmt.Sprintf("%d;w=%d", w.limit, int(w.window.Seconds()))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
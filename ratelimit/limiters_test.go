@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock the tests move forward by hand
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// one request of a limiter test: wait, then ask
+type step struct {
+	wait       time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func runSteps(t *testing.T, name string, clock *fakeClock, limiter Limiter, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		clock.Advance(s.wait)
+		res, err := limiter.Allow("client")
+		if err != nil {
+			t.Fatalf("%s #%d: %v", name, i, err)
+		}
+		if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter {
+			t.Errorf("%s #%d: Allowed %v, Remaining %d, RetryAfter %v, want %v, %d, %v",
+				name, i, res.Allowed, res.Remaining, res.RetryAfter, s.allowed, s.remaining, s.retryAfter)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(0)
+	defer store.Close()
+	store.Now = clock.Now
+	limiter := NewTokenBucket(3, 3*time.Second, store)
+	limiter.Now = clock.Now
+
+	runSteps(t, "token bucket", clock, limiter, []step{
+		{0, true, 2, 0},
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		// one token per second refills
+		{0, false, 0, time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{500 * time.Millisecond, true, 0, 0},
+		// never more than limit tokens, however long the client waited
+		{time.Hour, true, 2, 0},
+	})
+
+	res, _ := limiter.Allow("other")
+	if res.Reset != time.Second || res.Limit != 3 {
+		t.Errorf("Reset = %v, Limit = %d, want 1s, 3", res.Reset, res.Limit)
+	}
+	if got := limiter.Policy(); got != "3;w=3" {
+		t.Errorf("Policy = %q, want %q", got, "3;w=3")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(0)
+	defer store.Close()
+	store.Now = clock.Now
+	limiter := NewSlidingWindow(4, 10*time.Second, store)
+	limiter.Now = clock.Now
+
+	runSteps(t, "sliding window", clock, limiter, []step{
+		{0, true, 3, 0},
+		{0, true, 2, 0},
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		// the full previous window still counts at the boundary, no double burst
+		{0, false, 0, 12500 * time.Millisecond},
+		{10 * time.Second, false, 0, 2500 * time.Millisecond},
+		// a quarter into the next window the previous one weighs 3
+		{2500 * time.Millisecond, true, 0, 0},
+		{0, false, 0, 2500 * time.Millisecond},
+		{2500 * time.Millisecond, true, 0, 0},
+		// two idle windows forget everything
+		{time.Minute, true, 3, 0},
+	})
+
+	if got := limiter.Policy(); got != "4;w=10" {
+		t.Errorf("Policy = %q, want %q", got, "4;w=10")
+	}
+}
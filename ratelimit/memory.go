@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// number of shards of the memory store, keys of different shards never wait for each other
+const memoryShards = 64
+
+// MemoryStore keeps the state of every key in memory
+// the keys are spread over shards with their own lock, so busy servers don't contend on one mutex
+// state is lost on restart and not shared between processes
+type MemoryStore struct {
+	shards  [memoryShards]memoryShard
+	maxKeys int // per shard
+	stop    chan struct{}
+	once    sync.Once
+	// Now returns the current time for expiring keys, time.Now unless replaced (e.g. by tests)
+	Now func() time.Time
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	state   State
+	expires time.Time
+}
+
+// NewMemoryStore
+// creates an in memory store holding at most maxKeys keys (0 means no limit)
+// when it is full, expired keys are evicted first, then random ones
+// expired keys are also swept every minute
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	m := &MemoryStore{stop: make(chan struct{}), Now: time.Now}
+	if maxKeys > 0 {
+		m.maxKeys = max(1, maxKeys/memoryShards)
+	}
+	for i := range m.shards {
+		m.shards[i].entries = map[string]*memoryEntry{}
+	}
+	go m.sweep(time.Minute)
+	return m
+}
+
+func (m *MemoryStore) Update(key string, ttl time.Duration, fn func(s *State)) error {
+	shard := m.shard(key)
+	now := m.Now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expires) {
+		if !ok && m.maxKeys > 0 && len(shard.entries) >= m.maxKeys {
+			shard.evict(now, m.maxKeys)
+		}
+		entry = &memoryEntry{}
+		shard.entries[key] = entry
+	}
+
+	fn(&entry.state)
+	entry.expires = now.Add(ttl)
+	return nil
+}
+
+// store.Len()
+// returns the number of stored keys, including expired ones not swept yet
+func (m *MemoryStore) Len() int {
+	n := 0
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// store.Close()
+// stops sweeping expired keys
+func (m *MemoryStore) Close() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+func (m *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &m.shards[h.Sum32()%memoryShards]
+}
+
+// makes room for one key, the caller holds the lock
+func (s *memoryShard) evict(now time.Time, maxKeys int) {
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	if len(s.entries) >= maxKeys {
+		// map iteration order is random, so is the evicted key
+		for key := range s.entries {
+			delete(s.entries, key)
+			return
+		}
+	}
+}
+
+func (m *MemoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			now := m.Now()
+			for i := range m.shards {
+				shard := &m.shards[i]
+				shard.mu.Lock()
+				for key, entry := range shard.entries {
+					if now.After(entry.expires) {
+						delete(shard.entries, key)
+					}
+				}
+				shard.mu.Unlock()
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// keys that land in the same shard as key
+func sameShard(m *MemoryStore, key string, n int) []string {
+	var keys []string
+	for i := 0; len(keys) < n; i++ {
+		k := fmt.Sprintf("key-%d", i)
+		if m.shard(k) == m.shard(key) && k != key {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestMemoryStoreMaxKeys(t *testing.T) {
+	store := NewMemoryStore(memoryShards)
+	defer store.Close()
+	for i := 0; i < 1000; i++ {
+		store.Update(fmt.Sprintf("client-%d", i), time.Hour, func(s *State) { s.Value = 1 })
+	}
+	if n := store.Len(); n > memoryShards {
+		t.Errorf("Len = %d, want at most %d", n, memoryShards)
+	}
+}
+
+func TestMemoryStoreEvictsExpiredFirst(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(2 * memoryShards) // two keys per shard
+	defer store.Close()
+	store.Now = clock.Now
+
+	keys := sameShard(store, "short", 2)
+	store.Update("short", time.Second, func(s *State) { s.Value = 1 })
+	store.Update(keys[0], time.Hour, func(s *State) { s.Value = 2 })
+
+	clock.Advance(2 * time.Second)
+	store.Update(keys[1], time.Hour, func(s *State) { s.Value = 3 })
+
+	shard := store.shard("short")
+	if _, ok := shard.entries["short"]; ok {
+		t.Errorf("expired key kept while the shard was full")
+	}
+	if _, ok := shard.entries[keys[0]]; !ok {
+		t.Errorf("live key evicted although an expired one was there")
+	}
+	if len(shard.entries) != 2 {
+		t.Errorf("shard holds %d keys, want 2", len(shard.entries))
+	}
+}
+
+func TestMemoryStoreExpiredStateIsReset(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryStore(0)
+	defer store.Close()
+	store.Now = clock.Now
+
+	store.Update("client", time.Minute, func(s *State) { s.Value = 5 })
+	clock.Advance(30 * time.Second)
+	store.Update("client", time.Minute, func(s *State) {
+		if s.Value != 5 {
+			t.Errorf("live state = %v, want 5", s.Value)
+		}
+	})
+	clock.Advance(2 * time.Minute)
+	store.Update("client", time.Minute, func(s *State) {
+		if s.Value != 0 || !s.Time.IsZero() {
+			t.Errorf("expired state = %+v, want the zero State", *s)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+/*
+Package ratelimit provides the limiters and stores of the rate limit middleware
+
+	limiter := ratelimit.NewTokenBucket(10, time.Minute, ratelimit.NewMemoryStore(0))
+	server.Post("/login", login, middlewares.RateLimitWithConfig(middlewares.RateLimitConfig{Limiter: limiter}))
+
+	- TokenBucket:   allows bursts up to the limit, refills steadily over the period
+	- SlidingWindow: at most limit requests in any window, smooth at window boundaries
+
+the state of every client is kept in a Store: MemoryStore or an external backend
+*/
+package ratelimit
+
+import (
+	"time"
+)
+
+// Result is the decision about one request
+type Result struct {
+	Allowed    bool
+	Limit      int           // requests allowed per period / window
+	Remaining  int           // requests left right now
+	Reset      time.Duration // until the limit is fully available again
+	RetryAfter time.Duration // until the next request is allowed, 0 if allowed
+}
+
+// Limiter decides whether the client with the key may make another request
+type Limiter interface {
+	Allow(key string) (Result, error)
+	// Policy describes the limit for the RateLimit-Policy header: "100;w=60"
+	Policy() string
+}
+
+// State is the stored state of one key
+// the limiters use the fields as they need them:
+//
+//	TokenBucket:   Value = tokens left,           Time = last refill
+//	SlidingWindow: Value = requests this window,  Prev = requests last window, Time = window start
+type State struct {
+	Value float64   `json:"v"`
+	Prev  float64   `json:"p,omitempty"`
+	Time  time.Time `json:"t"`
+}
+
+// Store keeps the state of every key
+// external backends (redis, a database) have to apply Update atomically per key,
+// e.g. with a transaction or a lock, otherwise concurrent requests slip through
+type Store interface {
+	// Update calls fn with the state of the key (zero State for new keys)
+	// and stores the changed state for ttl
+	Update(key string, ttl time.Duration, fn func(s *State)) error
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"net"
+	"net/url"
+	"squirrel/core"
+	"strings"
+)
+
+/*
+	Canonical urls
+
+	- every page should be reachable under a single url, duplicates hurt caches and search engines
+	- the mux redirects requests to their canonical url before routing them:
+	  http://Example.com/Users/ => https://www.example.com/users
+	- all the rules are applied at once, so the client is redirected a single time
+*/
+
+// TrailingSlash decides what happens to a trailing slash of the request path
+type TrailingSlash int
+
+const (
+	TrailingSlashIgnore TrailingSlash = iota // leave the path as it is (default)
+	TrailingSlashStrip                       // /users/ => /users
+	TrailingSlashAdd                         // /users  => /users/ (not for files like /app.js)
+)
+
+// WWW decides between the www and the bare host
+type WWW int
+
+const (
+	WWWIgnore WWW = iota // leave the host as it is (default)
+	WWWAdd               // example.com     => www.example.com
+	WWWRemove            // www.example.com => example.com
+)
+
+// CanonicalOptions configures the redirects to canonical urls
+//
+//	server.Canonical(server.CanonicalOptions{
+//		TrailingSlash: server.TrailingSlashStrip,
+//		LowercasePath: true,
+//		ForceHTTPS:    true,
+//		WWW:           server.WWWRemove,
+//	})
+type CanonicalOptions struct {
+	TrailingSlash TrailingSlash
+	// redirects /Users/Alice to /users/alice
+	LowercasePath bool
+	// redirects http to https
+	// squirrel itself speaks plain http, the scheme is taken from the
+	// forwarded headers of the proxy terminating tls, see server.TrustProxies
+	// Listen fails without trusted proxies, every request would look like http
+	ForceHTTPS bool
+	WWW        WWW
+	// status code of the redirects
+	// defaults to 301 for GET and HEAD and 308 otherwise, so the method and body are kept
+	Code int
+}
+
+// server.Canonical(opts)
+// redirects requests to their canonical url before routing them
+// the path rules don't apply below static mounts, file names are case sensitive
+// and directories redirect to their trailing slash themselves
+func (sm *SquirrelMux) Canonical(opts CanonicalOptions) {
+	sm.canonical = &opts
+}
+
+// server.AllowRedirectHosts("example.com", "*.example.com")
+// hosts res.Redirect may send clients to in every handler
+// the host of the request itself is always allowed
+func (sm *SquirrelMux) AllowRedirectHosts(hosts ...string) {
+	sm.redirectHosts = append(sm.redirectHosts, hosts...)
+}
+
+// canonicalRedirect
+// redirects the request if its url is not canonical
+// reports whether a redirect was sent
+func (sm *SquirrelMux) canonicalRedirect(req *core.Request, res *core.Response) bool {
+	opts := sm.canonical
+	if opts == nil || req.Url == nil {
+		return false
+	}
+
+	scheme := req.Scheme()
+	host := req.Host()
+	p := req.Url.Path
+	if p == "" {
+		p = "/"
+	}
+
+	if !sm.isStaticPath(req.Path) {
+		if opts.LowercasePath {
+			p = strings.ToLower(p)
+		}
+
+		switch opts.TrailingSlash {
+		case TrailingSlashStrip:
+			if p = strings.TrimRight(p, "/"); p == "" {
+				p = "/"
+			}
+		case TrailingSlashAdd:
+			last := p[strings.LastIndex(p, "/")+1:]
+			if !strings.HasSuffix(p, "/") && !strings.Contains(last, ".") {
+				p += "/"
+			}
+		}
+	}
+
+	if opts.ForceHTTPS {
+		scheme = "https"
+	}
+
+	if host != "" {
+		name, port := splitHost(host)
+		switch {
+		case opts.WWW == WWWAdd && !strings.HasPrefix(name, "www.") && strings.Contains(name, ".") && net.ParseIP(name) == nil:
+			name = "www." + name
+		case opts.WWW == WWWRemove:
+			name = strings.TrimPrefix(name, "www.")
+		}
+		// the default port of the old scheme is wrong for the new one
+		if scheme != req.Scheme() && (port == "80" || port == "443") {
+			port = ""
+		}
+		host = name
+		if port != "" {
+			host = net.JoinHostPort(name, port)
+		}
+	}
+
+	sameOrigin := scheme == req.Scheme() && strings.EqualFold(host, req.Host())
+	if sameOrigin && p == req.Url.Path {
+		return false
+	}
+
+	target := &url.URL{Path: p, RawQuery: req.Url.RawQuery}
+	if !sameOrigin {
+		if host == "" {
+			// no host to send an absolute url to
+			return false
+		}
+		target.Scheme, target.Host = scheme, host
+	}
+
+	code := opts.Code
+	if code == 0 {
+		code = 301
+		if req.Method != "GET" && req.Method != "HEAD" {
+			code = 308
+		}
+	}
+
+	// the canonical host is derived from the request host, so it is safe
+	res.AllowRedirectHosts(target.Hostname())
+	if err := res.Redirect(target.String(), code); err != nil {
+		res.SetError(err)
+	}
+	sm.finish(req, res)
+	return true
+}
+
+func splitHost(host string) (name, port string) {
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		return strings.ToLower(h), p
+	}
+	return strings.ToLower(host), ""
+}
+
+// reports whether the path is served by a static mount
+func (sm *SquirrelMux) isStaticPath(p string) bool {
+	for _, rt := range sm.routes {
+		if rt.prefix && matchPrefix(rt.pattern, p) {
+			return true
+		}
+	}
+	return false
+}
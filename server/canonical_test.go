@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/url"
+	"squirrel/core"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCanonical(t *testing.T) {
+	sm := SpawnServer()
+	sm.Canonical(CanonicalOptions{
+		TrailingSlash: TrailingSlashStrip,
+		LowercasePath: true,
+		ForceHTTPS:    true,
+		WWW:           WWWRemove,
+	})
+	// the test client is the tls terminating proxy
+	if err := sm.TrustProxies("loopback"); err != nil {
+		t.Fatal(err)
+	}
+	ok := func(req *core.Request, res *core.Response) { res.Write("ok") }
+	sm.Get("/users", ok)
+	sm.Post("/users", ok)
+	sm.ServeFS("/Assets", fstest.MapFS{"App.js": {Data: []byte("js")}})
+	base := serve(t, sm)
+
+	https := map[string]string{"X-Forwarded-Proto": "https", "Host": "example.com"}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		headers  map[string]string
+		status   int
+		location string
+	}{
+		{"canonical", "GET", "/users", https, 200, ""},
+		{"trailing slash", "GET", "/users/", https, 301, "/users"},
+		{"uppercase", "GET", "/Users?page=2", https, 301, "/users?page=2"},
+		{"post keeps method", "POST", "/users/", https, 308, "/users"},
+		{"http", "GET", "/users", map[string]string{"Host": "example.com"}, 301, "https://example.com/users"},
+		{"www and path at once", "GET", "/Users/", map[string]string{"Host": "www.example.com:80"}, 301, "https://example.com/users"},
+		{"static path untouched", "GET", "/Assets/App.js", https, 200, ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, tt.method, base+tt.path, tt.headers, "")
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+		if got := res.Header.Get("Location"); got != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.name, got, tt.location)
+		}
+	}
+}
+
+func TestCanonicalAddOptions(t *testing.T) {
+	sm := SpawnServer()
+	sm.Canonical(CanonicalOptions{TrailingSlash: TrailingSlashAdd, WWW: WWWAdd, Code: 302})
+	sm.Get("/docs", func(req *core.Request, res *core.Response) { res.Write("docs") })
+	base := serve(t, sm)
+
+	tests := []struct {
+		name     string
+		path     string
+		host     string
+		status   int
+		location string
+	}{
+		{"add slash", "/docs", "www.example.com", 302, "/docs/"},
+		{"files keep their name", "/app.js", "www.example.com", 404, ""},
+		{"add www", "/docs/", "example.com", 302, "http://www.example.com/docs/"},
+		{"no www for ips", "/docs/", "127.0.0.1", 200, ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, "GET", base+tt.path, map[string]string{"Host": tt.host}, "")
+		if res.StatusCode != tt.status || res.Header.Get("Location") != tt.location {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, res.StatusCode, res.Header.Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestAllowRedirectHosts(t *testing.T) {
+	sm := SpawnServer()
+	sm.AllowRedirectHosts("*.example.com")
+	sm.GetE("/go", func(req *core.Request, res *core.Response) error {
+		return res.Redirect(req.Query("to")[0], 302)
+	})
+	base := serve(t, sm)
+
+	tests := []struct {
+		to       string
+		status   int
+		location string
+	}{
+		{"https://api.example.com/", 302, "https://api.example.com/"},
+		{"https://example.com.evil.com/", 400, ""},
+		{"//evil.com", 400, ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, "GET", base+"/go?to="+url.QueryEscape(tt.to), map[string]string{"Host": "app.test"}, "")
+		if res.StatusCode != tt.status || res.Header.Get("Location") != tt.location {
+			t.Errorf("to %s: %d %q, want %d %q", tt.to, res.StatusCode, res.Header.Get("Location"), tt.status, tt.location)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"squirrel/core"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sm := SpawnServer()
+	sm.Get("/doc", func(req *core.Request, res *core.Response) {
+		res.SetLastModified(modified)
+		res.Write("document body")
+	})
+	base := serve(t, sm)
+
+	first, body := do(t, "GET", base+"/doc", nil, "")
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != 200 || body != "document body" || etag == "" {
+		t.Fatalf("GET /doc = %d %q with ETag %q", first.StatusCode, body, etag)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"etag match", map[string]string{"If-None-Match": etag}, 304},
+		{"etag changed", map[string]string{"If-None-Match": `"old"`}, 200},
+		{"not modified since", map[string]string{"If-Modified-Since": modified.Format(core.TimeFormat)}, 304},
+		{"modified since", map[string]string{"If-Modified-Since": modified.Add(-time.Minute).Format(core.TimeFormat)}, 200},
+		{"if-match fails", map[string]string{"If-Match": `"old"`}, 412},
+	}
+	for _, tt := range tests {
+		res, body := do(t, "GET", base+"/doc", tt.headers, "")
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+		if tt.status == 304 {
+			if body != "" || res.Header.Get("Content-Type") != "" || res.Header.Get("Content-Length") != "" {
+				t.Errorf("%s: 304 describes a body: %q %v", tt.name, body, res.Header)
+			}
+			if res.Header.Get("ETag") != etag {
+				t.Errorf("%s: 304 ETag = %q, want %q", tt.name, res.Header.Get("ETag"), etag)
+			}
+		}
+	}
+}
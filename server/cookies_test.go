@@ -0,0 +1,48 @@
+package server
+
+import (
+	"squirrel/cookies"
+	"squirrel/core"
+	"testing"
+)
+
+func TestSignedCookies(t *testing.T) {
+	codec := cookies.NewSignedCodec([]byte("0123456789abcdef0123456789abcdef"))
+
+	sm := SpawnServer()
+	sm.Get("/set", func(req *core.Request, res *core.Response) {
+		res.SetSignedCookie(codec, cookies.Cookie{Name: "theme", Value: "dark", Path: "/"})
+	})
+	sm.Get("/get", func(req *core.Request, res *core.Response) {
+		theme, err := req.SignedCookie(codec, "theme")
+		if err != nil {
+			res.Text(400, err.Error())
+			return
+		}
+		res.Text(200, theme)
+	})
+	base := serve(t, sm)
+
+	set, _ := do(t, "GET", base+"/set", nil, "")
+	signed := set.Cookies()
+	if len(signed) != 1 || signed[0].Value == "dark" {
+		t.Fatalf("Set-Cookie = %v", signed)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		status int
+		body   string
+	}{
+		{"signed", "theme=" + signed[0].Value, 200, "dark"},
+		{"forged", "theme=dark", 400, ""},
+		{"missing", "", 400, ""},
+	}
+	for _, tt := range tests {
+		res, body := do(t, "GET", base+"/get", map[string]string{"Cookie": tt.cookie}, "")
+		if res.StatusCode != tt.status || (tt.body != "" && body != tt.body) {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, res.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"squirrel/auth"
+	"squirrel/core"
+	"squirrel/middlewares"
+	"testing"
+)
+
+func TestAutomaticOptions(t *testing.T) {
+	sm := SpawnServer()
+	sm.Use(middlewares.CORSWithConfig(middlewares.CORSConfig{AllowOrigins: []string{"https://app.example.com"}}))
+	ok := func(req *core.Request, res *core.Response) { res.Text(200, "ok") }
+	sm.Get("/users/:id", ok)
+	sm.Delete("/users/:id", ok)
+	base := serve(t, sm)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+		allow   string
+		origin  string
+	}{
+		{"allowed methods", "/users/7", nil, 204, "GET, DELETE, OPTIONS", ""},
+		{"preflight", "/users/7", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"}, 204, "", "https://app.example.com"},
+		{"unknown path", "/posts/7", nil, 404, "", ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, "OPTIONS", base+tt.path, tt.headers, "")
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+		if got := res.Header.Get("Allow"); got != tt.allow {
+			t.Errorf("%s: Allow = %q, want %q", tt.name, got, tt.allow)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.origin)
+		}
+	}
+}
+
+func TestAutomaticOptionsRouteMiddlewares(t *testing.T) {
+	sm := SpawnServer()
+	cors := middlewares.CORSWithConfig(middlewares.CORSConfig{AllowOrigins: []string{"https://app.example.com"}})
+	ok := func(req *core.Request, res *core.Response) { res.Text(200, "ok") }
+	sm.Get("/public", ok)
+	sm.PUT("/api/users/:id", ok, cors)
+	base := serve(t, sm)
+
+	preflight := map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"}
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+	}{
+		{"cors of the route", "/api/users/7", "https://app.example.com"},
+		{"route without cors", "/public", ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, "OPTIONS", base+tt.path, preflight, "")
+		if res.StatusCode != 204 {
+			t.Errorf("%s: status = %d, want 204", tt.name, res.StatusCode)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.origin)
+		}
+	}
+}
+
+func TestPreflightSkipsAuth(t *testing.T) {
+	sm := SpawnServer()
+	cors := middlewares.CORSWithConfig(middlewares.CORSConfig{AllowOrigins: []string{"https://app.example.com"}})
+	ok := func(req *core.Request, res *core.Response) { res.Text(200, "ok") }
+	// auth in front of cors, the preflight still reaches cors
+	sm.PUT("/api/users/:id", ok, middlewares.BasicAuth(map[string]string{"admin": "secret"}), sm.Require(auth.Roles("admin")), cors)
+	base := serve(t, sm)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+		origin  string
+	}{
+		{"preflight", "OPTIONS", map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"}, 204, "https://app.example.com"},
+		{"plain options", "OPTIONS", nil, 401, ""},
+		{"actual request", "PUT", map[string]string{"Origin": "https://app.example.com"}, 401, ""},
+	}
+	for _, tt := range tests {
+		res, _ := do(t, tt.method, base+"/api/users/7", tt.headers, "")
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+		if got := res.Header.Get("Access-Control-Allow-Origin"); got != tt.origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.origin)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"log"
+	"squirrel/core"
+)
+
+// ErrorHandler is the central place where errors returned by handlers end up
+// it maps the error to a response for the client
+// and is the only place where the error gets logged
+type ErrorHandler func(err error, req *core.Request, res *core.Response)
+
+// DefaultErrorHandler
+// typed errors (core.HTTPError, also when wrapped) are answered with their own code and message
+// any other error is answered with 500 Internal Server Error, without leaking the error text
+func DefaultErrorHandler(err error, req *core.Request, res *core.Response) {
+	he := core.AsHTTPError(err)
+
+	log.Printf("Error at: (%s %s) %v", req.Method, req.Path, err)
+
+	// nothing left to do if the handler already sent the response
+	if res.Sent() {
+		return
+	}
+
+	// headers describing the body of the handler are wrong for the error message
+	for _, header := range errorResetHeaders {
+		res.DelHeader(header)
+	}
+	res.SetHeader("Content-Type", "text/plain; charset=utf-8")
+	res.SetStatus(he.Code)
+	res.Write(he.Message + "\n")
+}
+
+// headers set by handlers and middlewares (e.g. Compress, cache policies)
+// that must not survive on an error response
+var errorResetHeaders = []string{
+	"Content-Encoding", "Content-Range", "Content-Disposition",
+	"ETag", "Last-Modified", "Cache-Control", "Expires",
+}
+
+// server.SetErrorHandler(fn)
+// overrides the default error handler of the mux
+func (sm *SquirrelMux) SetErrorHandler(fn ErrorHandler) {
+	sm.errorHandler = fn
+}
+
+// hands the recorded error (if any) to the error handler of the mux
+// answers conditional requests (304 / 412) and range requests (206 / 416)
+// and sends the response to the client
+func (sm *SquirrelMux) finish(req *core.Request, res *core.Response) {
+	if err := res.GetError(); err != nil {
+		handler := sm.errorHandler
+		if handler == nil {
+			handler = DefaultErrorHandler
+		}
+		handler(err, req, res)
+	}
+	res.HandleConditional(req, sm.etagMode)
+	res.HandleRange(req)
+	res.Send()
+}
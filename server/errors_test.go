@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"squirrel/core"
+	"testing"
+)
+
+func TestErrorHandling(t *testing.T) {
+	DisableAutoRecover()
+	sm := SpawnServer()
+	sm.GetE("/ok", func(req *core.Request, res *core.Response) error {
+		res.Write("ok")
+		return nil
+	})
+	sm.GetE("/missing", func(req *core.Request, res *core.Response) error {
+		return core.NewHTTPError(404, "user not found")
+	})
+	sm.GetE("/wrapped", func(req *core.Request, res *core.Response) error {
+		return fmt.Errorf("loading user: %w", core.NewHTTPError(409))
+	})
+	sm.GetE("/internal", func(req *core.Request, res *core.Response) error {
+		res.Write("half written")
+		return errors.New("db: connection refused")
+	})
+	sm.Get("/plain", func(req *core.Request, res *core.Response) {
+		res.SetError(core.NewHTTPError(400, "bad id"))
+	})
+	base := serve(t, sm)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", 200, "ok"},
+		{"/missing", 404, "user not found\n"},
+		{"/wrapped", 409, "Conflict\n"},
+		{"/internal", 500, "Internal Server Error\n"},
+		{"/plain", 400, "bad id\n"},
+	}
+	for _, tt := range tests {
+		res, body := do(t, "GET", base+tt.path, nil, "")
+		if res.StatusCode != tt.status || body != tt.body {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, res.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestCustomErrorHandlerAndMiddlewareE(t *testing.T) {
+	sm := SpawnServer()
+	sm.SetErrorHandler(func(err error, req *core.Request, res *core.Response) {
+		res.SetStatus(core.AsHTTPError(err).Code)
+		res.JSON(map[string]string{"error": err.Error()})
+	})
+	// turns not found errors of the handlers into 410
+	sm.UseE(func(next core.HandlerFuncE) core.HandlerFuncE {
+		return func(req *core.Request, res *core.Response) error {
+			err := next(req, res)
+			if he := core.AsHTTPError(err); err != nil && he.Code == 404 {
+				return core.NewHTTPError(410, "Gone")
+			}
+			return err
+		}
+	})
+	sm.GetE("/gone", func(req *core.Request, res *core.Response) error {
+		return core.NewHTTPError(404)
+	})
+	base := serve(t, sm)
+
+	res, body := do(t, "GET", base+"/gone", nil, "")
+	var got map[string]string
+	if err := json.Unmarshal([]byte(body), &got); err != nil || res.StatusCode != 410 || got["error"] != "410 Gone" {
+		t.Errorf("GET /gone = %d %q", res.StatusCode, body)
+	}
+}
+
+func TestErrorResponseDropsBodyHeaders(t *testing.T) {
+	sm := SpawnServer()
+	sm.GetE("/download", func(req *core.Request, res *core.Response) error {
+		res.SetHeader("Content-Type", "application/pdf")
+		res.SetHeader("Content-Disposition", `attachment; filename="report.pdf"`)
+		res.SetHeader("ETag", `"v1"`)
+		res.SetHeader("Cache-Control", "public, max-age=3600")
+		res.SetHeader("X-Request-Id", "abc")
+		return core.NewHTTPError(403, "not yours")
+	})
+	base := serve(t, sm)
+
+	res, body := do(t, "GET", base+"/download", nil, "")
+	if res.StatusCode != 403 || body != "not yours\n" {
+		t.Errorf("GET /download = %d %q", res.StatusCode, body)
+	}
+	want := map[string]string{
+		"Content-Type":        "text/plain; charset=utf-8",
+		"Content-Disposition": "",
+		"ETag":                "",
+		"Cache-Control":       "",
+		// headers unrelated to the body stay
+		"X-Request-Id": "abc",
+	}
+	for name, value := range want {
+		if got := res.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"slices"
+	"squirrel/auth"
+	"squirrel/core"
+	"squirrel/middlewares"
+	"strings"
+)
+
+/*
+	Route groups and authorization
+
+	- a group registers routes below a prefix, with middlewares shared by all of them
+	- Require attaches authorization policies to a route or a whole group,
+	  anonymous requests get 401, denied principals 403
+	- denials are reported to the audit function of the mux
+
+	admin := server.Group("/admin", middlewares.JWT(verifier)).Require(auth.Roles("admin"))
+	admin.Get("/users", listUsers)
+	admin.Delete("/users/:id", deleteUser, server.Require(auth.Permissions("users:delete")))
+*/
+
+// Group registers routes below a common prefix
+type Group struct {
+	mux        *SquirrelMux
+	prefix     string
+	middleware []Middleware
+}
+
+// server.Group(prefix, middlewares...)
+// returns a group registering its routes below prefix
+// the middlewares run after the global ones and before the ones of the route
+func (sm *SquirrelMux) Group(prefix string, mws ...Middleware) *Group {
+	return &Group{mux: sm, prefix: strings.TrimSuffix(prefix, "/"), middleware: mws}
+}
+
+// group.Group(prefix, middlewares...)
+// returns a nested group, it inherits the middlewares of the group
+func (g *Group) Group(prefix string, mws ...Middleware) *Group {
+	return &Group{
+		mux:        g.mux,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: slices.Concat(g.middleware, mws),
+	}
+}
+
+// group.Use(middleware)
+// adds a middleware to the routes registered afterwards
+func (g *Group) Use(mw Middleware) *Group {
+	g.middleware = append(g.middleware, mw)
+	return g
+}
+
+// group.Require(policies...)
+// requires every policy for the routes registered afterwards
+func (g *Group) Require(policies ...auth.Policy) *Group {
+	return g.Use(g.mux.Require(policies...))
+}
+
+func (g *Group) Get(path string, handler core.HandlerFunc, mws ...Middleware) {
+	g.mux.Get(g.path(path), handler, g.with(mws)...)
+}
+
+func (g *Group) Post(path string, handler core.HandlerFunc, mws ...Middleware) {
+	g.mux.Post(g.path(path), handler, g.with(mws)...)
+}
+
+func (g *Group) PUT(path string, handler core.HandlerFunc, mws ...Middleware) {
+	g.mux.PUT(g.path(path), handler, g.with(mws)...)
+}
+
+func (g *Group) Delete(path string, handler core.HandlerFunc, mws ...Middleware) {
+	g.mux.Delete(g.path(path), handler, g.with(mws)...)
+}
+
+func (g *Group) GetE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	g.Get(path, handler.HandlerFunc(), mws...)
+}
+
+func (g *Group) PostE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	g.Post(path, handler.HandlerFunc(), mws...)
+}
+
+func (g *Group) PutE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	g.PUT(path, handler.HandlerFunc(), mws...)
+}
+
+func (g *Group) DeleteE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	g.Delete(path, handler.HandlerFunc(), mws...)
+}
+
+// "/admin" + "/" is "/admin", not "/admin/"
+func (g *Group) path(p string) string {
+	if p == "" || p == "/" {
+		if g.prefix == "" {
+			return "/"
+		}
+		return g.prefix
+	}
+	return g.prefix + p
+}
+
+// group middlewares first, a copy so routes never share the backing array
+func (g *Group) with(mws []Middleware) []Middleware {
+	return slices.Concat(g.middleware, mws)
+}
+
+// server.Require(policies...)
+// returns a route middleware requiring every policy
+// server.Get("/admin", admin, server.Require(auth.Roles("admin")))
+func (sm *SquirrelMux) Require(policies ...auth.Policy) Middleware {
+	return middlewares.AuthorizeWithConfig(middlewares.AuthorizeConfig{
+		Policies: policies,
+		// looked up per request, so SetAuditLogger works in any order
+		Audit: func(d auth.Denial) { sm.audit(d) },
+	})
+}
+
+// server.SetAuditLogger(fn)
+// receives every request denied by server.Require, instead of the standard logger
+func (sm *SquirrelMux) SetAuditLogger(fn func(d auth.Denial)) {
+	sm.auditLogger = fn
+}
+
+func (sm *SquirrelMux) audit(d auth.Denial) {
+	if sm.auditLogger != nil {
+		sm.auditLogger(d)
+		return
+	}
+	middlewares.LogDenial(d)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/base64"
+	"squirrel/auth"
+	"squirrel/core"
+	"squirrel/middlewares"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// appends its name to the X-Trace header, so the order of middlewares is visible
+func trace(name string) Middleware {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return func(req *core.Request, res *core.Response) {
+			res.AddHeader("X-Trace", name)
+			next(req, res)
+		}
+	}
+}
+
+func TestGroupMiddlewares(t *testing.T) {
+	sm := SpawnServer()
+	sm.Use(trace("global"))
+	ok := func(req *core.Request, res *core.Response) { res.Text(200, "ok") }
+
+	api := sm.Group("/api/", trace("api"))
+	api.Get("/", ok)
+	api.Get("/users", ok, trace("route"))
+
+	v2 := api.Group("/v2", trace("v2"))
+	v2.Get("/users", ok)
+
+	// added later, only routes registered afterwards get it
+	api.Use(trace("late"))
+	api.Get("/late", ok)
+
+	sm.Get("/public", ok)
+	base := serve(t, sm)
+
+	tests := []struct {
+		path, trace string
+	}{
+		{"/api", "global, api"},
+		{"/api/users", "global, api, route"},
+		{"/api/v2/users", "global, api, v2"},
+		{"/api/late", "global, api, late"},
+		{"/public", "global"},
+	}
+	for _, tt := range tests {
+		res, body := do(t, "GET", base+tt.path, nil, "")
+		if res.StatusCode != 200 || body != "ok" {
+			t.Errorf("%s: %d %q", tt.path, res.StatusCode, body)
+		}
+		if got := strings.Join(res.Header.Values("X-Trace"), ", "); got != tt.trace {
+			t.Errorf("%s: middlewares = %q, want %q", tt.path, got, tt.trace)
+		}
+	}
+}
+
+func TestGroupRequire(t *testing.T) {
+	sm := SpawnServer()
+	var mu sync.Mutex
+	var denials []auth.Denial
+	sm.SetAuditLogger(func(d auth.Denial) {
+		mu.Lock()
+		denials = append(denials, d)
+		mu.Unlock()
+	})
+
+	users := map[string]string{"admin": "secret", "ada": "lovelace"}
+	roles := map[string][]string{"admin": {"admin"}}
+	basic := middlewares.BasicAuthWithConfig(middlewares.BasicAuthConfig{
+		Optional: true,
+		Validate: func(user, password string) (*auth.Principal, error) {
+			if users[user] != "" && users[user] == password {
+				return &auth.Principal{ID: user, Roles: roles[user]}, nil
+			}
+			return nil, nil
+		},
+	})
+	ok := func(req *core.Request, res *core.Response) { res.Text(200, "ok") }
+
+	admin := sm.Group("/admin", basic).Require(auth.Roles("admin"))
+	admin.Get("/users", ok)
+	admin.Delete("/users/:id", ok, sm.Require(auth.Permissions("users:delete")))
+	base := serve(t, sm)
+
+	tests := []struct {
+		name, method, path, user, password string
+		status                             int
+	}{
+		{"admin", "GET", "/admin/users", "admin", "secret", 200},
+		{"anonymous", "GET", "/admin/users", "", "", 401},
+		{"other role", "GET", "/admin/users", "ada", "lovelace", 403},
+		{"route policy on top of the group", "DELETE", "/admin/users/7", "admin", "secret", 403},
+	}
+	for _, tt := range tests {
+		headers := map[string]string{}
+		if tt.user != "" {
+			headers["Authorization"] = basicHeader(tt.user, tt.password)
+		}
+		res, _ := do(t, tt.method, base+tt.path, headers, "")
+		if res.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, res.StatusCode, tt.status)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(denials) != 3 {
+		t.Fatalf("audit logger got %d denials, want 3", len(denials))
+	}
+	if d := denials[2]; d.Principal != "admin" || d.Status != 403 || d.Path != "/admin/users/7" {
+		t.Errorf("last denial = %+v", d)
+	}
+}
+
+func basicHeader(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"squirrel/core"
+	"strings"
+	"testing"
+)
+
+func TestTrustProxies(t *testing.T) {
+	echo := func(req *core.Request, res *core.Response) {
+		res.Text(200, req.ClientIP()+" "+req.Scheme()+" "+req.Host())
+	}
+	headers := map[string]string{"Host": "internal", "X-Forwarded-For": "203.0.113.9", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}
+
+	direct := SpawnServer()
+	direct.Get("/", echo)
+	_, body := do(t, "GET", serve(t, direct)+"/", headers, "")
+	if want := "127.0.0.1 http internal"; body != want {
+		t.Errorf("untrusted peer: %q, want %q", body, want)
+	}
+
+	proxied := SpawnServer()
+	if err := proxied.TrustProxies("loopback"); err != nil {
+		t.Fatal(err)
+	}
+	proxied.Get("/", echo)
+	_, body = do(t, "GET", serve(t, proxied)+"/", headers, "")
+	if want := "203.0.113.9 https example.com"; body != want {
+		t.Errorf("trusted proxy: %q, want %q", body, want)
+	}
+
+	if err := proxied.TrustProxies("not a cidr"); err == nil {
+		t.Errorf("TrustProxies accepted an invalid range")
+	}
+}
+
+func TestTrustProxyHeader(t *testing.T) {
+	sm := SpawnServer()
+	if err := sm.TrustProxies("loopback"); err != nil {
+		t.Fatal(err)
+	}
+	sm.TrustProxyHeader(core.ProxyForwarded)
+	sm.Get("/", func(req *core.Request, res *core.Response) {
+		res.Text(200, req.ClientIP()+" "+req.Scheme()+" "+req.Host())
+	})
+	base := serve(t, sm)
+
+	// X-Forwarded-* come from the client, the proxy only sets Forwarded
+	_, body := do(t, "GET", base+"/", map[string]string{
+		"Host":              "internal",
+		"Forwarded":         "for=203.0.113.9;proto=https;host=example.com",
+		"X-Forwarded-For":   "1.2.3.4",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "evil.example",
+	}, "")
+	if want := "203.0.113.9 https example.com"; body != want {
+		t.Errorf("Forwarded proxy: %q, want %q", body, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("TrustProxyHeader accepted an unknown header")
+		}
+	}()
+	sm.TrustProxyHeader("X-Client-IP")
+}
+
+func TestForceHTTPSNeedsTrustedProxies(t *testing.T) {
+	sm := SpawnServer()
+	sm.Canonical(CanonicalOptions{ForceHTTPS: true})
+	if err := sm.Listen("127.0.0.1:0"); err == nil || !strings.Contains(err.Error(), "TrustProxies") {
+		t.Errorf("Listen = %v, want an error about TrustProxies", err)
+	}
+}
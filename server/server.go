@@ -1,9 +1,13 @@
 package server
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"net"
+	"net/netip"
+	"squirrel/auth"
 	"squirrel/core"
 	internal "squirrel/internal/static"
 	"squirrel/middlewares"
@@ -28,6 +32,28 @@ Serve package handles the following things
 
 type Middleware func(core.HandlerFunc) core.HandlerFunc
 
+// MiddlewareE is the middleware for error returning handlers
+// it can inspect, handle or replace the error returned by the next handler
+//
+//	func Audit(next core.HandlerFuncE) core.HandlerFuncE {
+//		return func(req *core.Request, res *core.Response) error {
+//			err := next(req, res)
+//			if err != nil {
+//				auditLog(req, err)
+//			}
+//			return err
+//		}
+//	}
+type MiddlewareE func(core.HandlerFuncE) core.HandlerFuncE
+
+// mw.Middleware()
+// converts MiddlewareE into plain Middleware so it can sit in the same chain
+func (mw MiddlewareE) Middleware() Middleware {
+	return func(next core.HandlerFunc) core.HandlerFunc {
+		return mw(next.E()).HandlerFunc()
+	}
+}
+
 /*
 
 	Explanation:
@@ -54,6 +80,8 @@ type route struct {
 	method  string
 	pattern string
 	handler core.HandlerFunc
+	// prefix routes match every path starting with the pattern (static files)
+	prefix bool
 	// for route specific middleware
 	// a route may have more than one middleware
 	middleware []Middleware
@@ -64,6 +92,26 @@ type SquirrelMux struct {
 	// global middlewares
 	// also an application have more than one middleware
 	middleware []Middleware
+	// maps the errors returned by the handlers to the response
+	errorHandler ErrorHandler
+	// how etags are generated for buffered responses
+	etagMode core.ETagMode
+	// view engine used by res.Render
+	views core.Renderer
+	// pretty print JSON and XML responses by default
+	pretty bool
+	// redirects to canonical urls, nil when disabled
+	canonical *CanonicalOptions
+	// hosts res.Redirect may send the client to
+	redirectHosts []string
+	// receives the requests denied by server.Require, nil logs them
+	auditLogger func(d auth.Denial)
+	// proxies whose forwarded headers are believed
+	trustedProxies []netip.Prefix
+	// the forwarded headers they set, X-Forwarded-* when empty
+	proxyHeader core.ProxyHeader
+	// requests with a bigger body are refused, core.DefaultMaxBodySize when 0
+	maxBodySize int64
 }
 
 var autoRecoverEnabled = true
@@ -88,14 +136,125 @@ func (sm *SquirrelMux) Use(mw Middleware) {
 	sm.middleware = append(sm.middleware, mw)
 }
 
+// global middleware for error returning handlers
+// server.UseE(MiddlewareE)
+func (sm *SquirrelMux) UseE(mw MiddlewareE) {
+	sm.Use(mw.Middleware())
+}
+
+// server.SetETagMode(core.ETagWeak)
+// chooses how etags are generated for buffered responses
+// core.ETagStrong (default), core.ETagWeak or core.ETagOff
+func (sm *SquirrelMux) SetETagMode(mode core.ETagMode) {
+	sm.etagMode = mode
+}
+
+// StaticOptions configures ServeStatic and ServeFS
+//
+//	server.ServeStatic("/app", "dist", server.StaticOptions{
+//		SPA:     true,
+//		Exclude: []string{"/app/api/*"},
+//	})
+type StaticOptions = internal.Options
+
+// StaticCache keeps hot static files in memory, see NewStaticCache
+// the zero value caches nothing
+type StaticCache = internal.FileCache
+
+// StaticCacheStats are the hit / miss / eviction metrics of a StaticCache
+type StaticCacheStats = internal.CacheStats
+
+// server.NewStaticCache(maxBytes)
+// creates an LRU cache for static files holding at most maxBytes
+// entries are invalidated when the modtime of the file changes
+//
+//	cache := server.NewStaticCache(64 << 20)
+//	server.ServeStatic("/assets", "public", server.StaticOptions{Cache: cache})
+//	log.Println(cache.Stats().Hits)
+func NewStaticCache(maxBytes int64) *StaticCache {
+	return internal.NewFileCache(maxBytes)
+}
+
+// server.TrustProxies(cidrs...)
+// believes the forwarded headers of requests coming from these addresses,
+// X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host unless TrustProxyHeader says otherwise
+// see req.ClientIP(), req.Scheme() and req.Host()
+// server.TrustProxies("10.0.0.0/8", "loopback")
+func (sm *SquirrelMux) TrustProxies(cidrs ...string) error {
+	prefixes, err := core.ParseTrustedProxies(cidrs...)
+	if err != nil {
+		return err
+	}
+	sm.trustedProxies = append(sm.trustedProxies, prefixes...)
+	return nil
+}
+
+// server.TrustProxyHeader(core.ProxyForwarded)
+// chooses the forwarded headers the trusted proxies set, only those are read
+// core.ProxyXForwardedFor (default), core.ProxyForwarded or core.ProxyXRealIP
+func (sm *SquirrelMux) TrustProxyHeader(header core.ProxyHeader) {
+	switch header {
+	case core.ProxyXForwardedFor, core.ProxyForwarded, core.ProxyXRealIP:
+		sm.proxyHeader = header
+	default:
+		panic(fmt.Sprintf("server: unknown proxy header %q", header))
+	}
+}
+
+// server.SetMaxBodySize(50 << 20)
+// refuses requests whose body is bigger than n bytes with 413, before the body is read
+// core.DefaultMaxBodySize (10 MB) by default, n <= 0 restores it
+func (sm *SquirrelMux) SetMaxBodySize(n int64) {
+	sm.maxBodySize = n
+}
+
+// server.SetViews(views.New("templates").Layout("layouts/base"))
+// attaches the view engine used by res.Render
+func (sm *SquirrelMux) SetViews(renderer core.Renderer) {
+	sm.views = renderer
+}
+
+// server.SetPretty(true)
+// indents JSON and XML responses by default, handy in development
+// handlers can still toggle it with res.SetPretty
+func (sm *SquirrelMux) SetPretty(pretty bool) {
+	sm.pretty = pretty
+}
+
 // methods to serve static files
 // server.ServeStatic("prefix", "dirpath")
-func (sm *SquirrelMux) ServeStatic(prefix, dirpath string) {
-	sm.routes = append(sm.routes, route{
-		method:  "GET",
-		pattern: prefix,
-		handler: internal.ServeStaticHandler(prefix, dirpath),
-	})
+func (sm *SquirrelMux) ServeStatic(prefix, dirpath string, opts ...StaticOptions) {
+	handler := internal.ServeStaticHandler(prefix, dirpath, opts...)
+	// HEAD gets the headers of the GET, Send leaves out the body
+	for _, method := range []string{"GET", "HEAD"} {
+		sm.routes = append(sm.routes, route{
+			method:  method,
+			pattern: prefix,
+			prefix:  true,
+			handler: handler,
+		})
+	}
+}
+
+// methods to serve static files from any fs.FS
+// works with embed.FS, os.DirFS, fstest.MapFS or overlay file systems
+//
+//	//go:embed public
+//	var assets embed.FS
+//
+//	public, _ := fs.Sub(assets, "public")
+//	server.ServeFS("/assets", public)
+func (sm *SquirrelMux) ServeFS(prefix string, fsys fs.FS, opts ...StaticOptions) {
+	handler := internal.ServeFSHandler(prefix, fsys, opts...)
+	// HEAD gets the headers of the GET, Send leaves out the body
+	for _, method := range []string{"GET", "HEAD"} {
+		sm.routes = append(sm.routes, route{
+			method:  method,
+			pattern: prefix,
+			prefix:  true,
+			handler: handler,
+		})
+	}
 }
 
 // differnt http methods
@@ -137,8 +296,32 @@ func (sm *SquirrelMux) Delete(path string, handler core.HandlerFunc, mws ...Midd
 	})
 }
 
+// error returning variants of the http methods
+// the returned error is passed to the ErrorHandler of the mux
+
+func (sm *SquirrelMux) GetE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	sm.Get(path, handler.HandlerFunc(), mws...)
+}
+
+func (sm *SquirrelMux) PostE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	sm.Post(path, handler.HandlerFunc(), mws...)
+}
+
+func (sm *SquirrelMux) PutE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	sm.PUT(path, handler.HandlerFunc(), mws...)
+}
+
+func (sm *SquirrelMux) DeleteE(path string, handler core.HandlerFuncE, mws ...Middleware) {
+	sm.Delete(path, handler.HandlerFunc(), mws...)
+}
+
 func (sm *SquirrelMux) Listen(addr string) error {
 
+	// squirrel speaks plain http, without a proxy telling the scheme every request would be redirected
+	if sm.canonical != nil && sm.canonical.ForceHTTPS && len(sm.trustedProxies) == 0 {
+		return fmt.Errorf("server: Canonical ForceHTTPS needs the tls proxy in server.TrustProxies")
+	}
+
 	// listen to the tcp connection request
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -161,23 +344,44 @@ func (sm *SquirrelMux) Listen(addr string) error {
 			defer conn.Close()
 
 			// parse the incoming request
-			req, err := core.ParseRequest(conn)
-			if err != nil {
-				log.Printf("Error while parsing request %v", err)
+			maxBodySize := sm.maxBodySize
+			if maxBodySize <= 0 {
+				maxBodySize = core.DefaultMaxBodySize
+			}
+			req, parseErr := core.ParseRequestLimit(conn, maxBodySize)
+			if parseErr != nil && !errors.Is(parseErr, core.ErrBodyTooLarge) {
+				log.Printf("Error while parsing request %v", parseErr)
 				return
 			}
 
 			// create new response object for the server to send back to the client
 			// also for handler function
 			res := core.NewResponse(&conn)
+			res.SetRequest(req)
+			res.SetPretty(sm.pretty)
+			if sm.views != nil {
+				res.SetRenderer(sm.views)
+			}
+			req.TrustProxies(sm.trustedProxies, sm.proxyHeader)
+			res.AllowRedirectHosts(sm.redirectHosts...)
+
+			// too large bodies are answered without reading them, the connection is closed afterwards
+			if parseErr != nil {
+				res.SetError(parseErr)
+				sm.finish(req, res)
+				return
+			}
+
+			// non canonical urls are redirected before routing
+			if sm.canonicalRedirect(req, res) {
+				return
+			}
 
 			// things to do:
 			// get params if any
 			// get the middlewares if any (both global and route specific)
 			// send the response to the client
 
-			params := map[string]string{} // for registering the params if any
-
 			// go through each routes in the mux server and call the route that matches the specific pattern
 			// matching includes:
 			// simple mathcing the patterns to attaching the middlewares for that routes to matching the exact params
@@ -187,39 +391,45 @@ func (sm *SquirrelMux) Listen(addr string) error {
 				if rt.method != req.Method {
 					continue // doesn't match with the incoming req, so skip and compare other route
 				}
-				// create handler along with available middlewares
-				routeHandler := rt.handler
 
-				// temporrary static file server
-				if req.Method == rt.method && strings.HasPrefix(req.Path, rt.pattern) {
-					routeHandler(req, res)
-					res.Send()
-					return
+				params := map[string]string{} // for registering the params if any
+
+				// static file routes are mounted on a prefix
+				// every other route has to match the pattern segment by segment
+				matched := false
+				if rt.prefix {
+					matched = matchPrefix(rt.pattern, req.Path)
+				} else {
+					matched = matchPattern(rt.pattern, req.Path, params)
 				}
 
-				if matched := matchPattern(rt.pattern, req.Path, params); matched {
+				if matched {
 					// do something
 					req.Params = params
 
-					// explanation of middleware handler at top
-
-					// routeHandler := rt.handler
-
-					// handling route specific middlewares
-					// cause we are executing all middlewares in reverse order of its implementation
-					for i := len(rt.middleware) - 1; i >= 0; i-- {
-						routeHandler = rt.middleware[i](routeHandler) // wrapping the handler with global middleware
-					}
-
-					// handling global  middlewares
-					for i := len(sm.middleware) - 1; i >= 0; i-- {
-						routeHandler = sm.middleware[i](routeHandler) // keep on wrapping the handler with middlewares
-						// so that middleware executes first and then the handler
-					}
+					// create handler along with available middlewares
+					routeHandler := sm.wrap(rt.handler, rt.middleware)
 
 					// calling the handler function
+					// errors recorded by the handler are handled by the mux before sending
 					routeHandler(req, res)
-					res.Send()
+					sm.finish(req, res)
+					return
+				}
+			}
+
+			// OPTIONS requests for registered paths are answered with the allowed methods
+			// the global middlewares and those of the route asked for run too,
+			// so CORS can answer preflight requests also when it is attached to a route or group
+			if req.Method == "OPTIONS" {
+				if allowed := sm.allowedMethods(req.Path); len(allowed) > 0 {
+					var routeMiddleware []Middleware
+					if rt, params, ok := sm.optionsRoute(req); ok {
+						req.Params = params
+						routeMiddleware = rt.middleware
+					}
+					sm.wrap(optionsHandler(allowed), routeMiddleware)(req, res)
+					sm.finish(req, res)
 					return
 				}
 			}
@@ -233,6 +443,83 @@ func (sm *SquirrelMux) Listen(addr string) error {
 	}
 }
 
+// wrap
+// wraps the handler with the route specific and the global middlewares
+// explanation of middleware handler at top
+func (sm *SquirrelMux) wrap(handler core.HandlerFunc, routeMiddleware []Middleware) core.HandlerFunc {
+
+	// handling route specific middlewares
+	// cause we are executing all middlewares in reverse order of its implementation
+	for i := len(routeMiddleware) - 1; i >= 0; i-- {
+		handler = routeMiddleware[i](handler) // wrapping the handler with route middleware
+	}
+
+	// handling global  middlewares
+	for i := len(sm.middleware) - 1; i >= 0; i-- {
+		handler = sm.middleware[i](handler) // keep on wrapping the handler with middlewares
+		// so that middleware executes first and then the handler
+	}
+
+	return handler
+}
+
+// methods of the routes matching the path, in registration order
+func (sm *SquirrelMux) allowedMethods(path string) []string {
+	var methods []string
+	seen := map[string]bool{}
+	for _, rt := range sm.routes {
+		matched := false
+		if rt.prefix {
+			matched = matchPrefix(rt.pattern, path)
+		} else {
+			matched = matchPattern(rt.pattern, path, map[string]string{})
+		}
+		if matched && !seen[rt.method] {
+			seen[rt.method] = true
+			methods = append(methods, rt.method)
+		}
+	}
+	if len(methods) > 0 && !seen["OPTIONS"] {
+		methods = append(methods, "OPTIONS")
+	}
+	return methods
+}
+
+// route whose middlewares answer an OPTIONS request:
+// the one of the method a preflight asks for (Access-Control-Request-Method), else the first one of the path
+func (sm *SquirrelMux) optionsRoute(req *core.Request) (route, map[string]string, bool) {
+	requested := req.GetHeader("Access-Control-Request-Method")
+	var first *route
+	var firstParams map[string]string
+	for i, rt := range sm.routes {
+		if rt.prefix {
+			continue
+		}
+		params := map[string]string{}
+		if !matchPattern(rt.pattern, req.Path, params) {
+			continue
+		}
+		if rt.method == requested {
+			return rt, params, true
+		}
+		if first == nil {
+			first, firstParams = &sm.routes[i], params
+		}
+	}
+	if first == nil {
+		return route{}, nil, false
+	}
+	return *first, firstParams, true
+}
+
+// answers OPTIONS with the allowed methods of the path
+func optionsHandler(methods []string) core.HandlerFunc {
+	return func(req *core.Request, res *core.Response) {
+		res.SetHeader("Allow", strings.Join(methods, ", "))
+		res.SetStatus(204)
+	}
+}
+
 // pattern ==> represents the path registered in while creating routes
 // path ==> represents the actual incoming path from the client and saved in the req.Path
 
@@ -264,3 +551,11 @@ func matchPattern(pattern, path string, params map[string]string) bool {
 
 	return true
 }
+
+// prefix => /assets
+// matches /assets and everything below it like /assets/js/app.js
+// but not /assetsfoo
+func matchPrefix(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"squirrel/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+// starts the mux on a free local port and returns its base url
+func serve(t *testing.T, sm *SquirrelMux) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	go sm.Listen(addr)
+	for i := 0; i < 100; i++ {
+		// a whole request, a connection closed without one is an error for the server
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Write([]byte("GET /probe HTTP/1.1\r\nHost: test\r\n\r\n"))
+			io.ReadAll(conn)
+			conn.Close()
+			return "http://" + addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server at %s didn't start", addr)
+	return ""
+}
+
+// the server closes every connection after the response
+var testClient = &http.Client{
+	Transport: &http.Transport{DisableKeepAlives: true},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// sends the request and returns the response with its body read
+func do(t *testing.T, method, url string, headers map[string]string, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	res, err := testClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("%s %s: reading body: %v", method, url, err)
+	}
+	return res, string(b)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	sm := SpawnServer()
+	sm.SetMaxBodySize(16)
+	sm.Post("/upload", func(req *core.Request, res *core.Response) {
+		body, _ := io.ReadAll(req.Body)
+		res.Text(200, string(body))
+	})
+	base := serve(t, sm)
+
+	res, body := do(t, "POST", base+"/upload", nil, "small")
+	if res.StatusCode != 200 || body != "small" {
+		t.Errorf("small body = %d %q", res.StatusCode, body)
+	}
+
+	// only the headers are sent, the server has to answer without waiting for the body
+	conn, err := net.Dial("tcp", strings.TrimPrefix(base, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	conn.Write([]byte("POST /upload HTTP/1.1\r\nHost: test\r\nContent-Length: 1073741824\r\n\r\n"))
+	raw, _ := io.ReadAll(conn)
+	if !strings.HasPrefix(string(raw), "HTTP/1.1 413") {
+		t.Errorf("too large body = %q, want 413", raw)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestServeFS(t *testing.T) {
+	sm := SpawnServer()
+	sm.ServeFS("/assets", fstest.MapFS{
+		"app.js": {Data: []byte("console.log('squirrel')")},
+	})
+	base := serve(t, sm)
+
+	tests := []struct {
+		name         string
+		path         string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"file", "/assets/app.js", nil, 200, "console.log('squirrel')", ""},
+		{"range", "/assets/app.js", map[string]string{"Range": "bytes=0-6"}, 206, "console", "bytes 0-6/23"},
+		{"unsatisfiable range", "/assets/app.js", map[string]string{"Range": "bytes=100-"}, 416, "", "bytes */23"},
+		{"missing", "/assets/nope.js", nil, 404, "404 File Not Found", ""},
+	}
+	for _, tt := range tests {
+		res, body := do(t, "GET", base+tt.path, tt.headers, "")
+		if res.StatusCode != tt.status || body != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, res.StatusCode, body, tt.status, tt.body)
+		}
+		if got := res.Header.Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", tt.name, got, tt.contentRange)
+		}
+	}
+}
+
+func TestServeFSHeadAndSlashPrefix(t *testing.T) {
+	sm := SpawnServer()
+	sm.ServeFS("/assets/", fstest.MapFS{
+		"app.js": {Data: []byte("console.log('squirrel')")},
+	})
+	base := serve(t, sm)
+
+	tests := []struct {
+		name   string
+		method string
+		status int
+		body   string
+		length string
+	}{
+		{"get", "GET", 200, "console.log('squirrel')", "23"},
+		{"head", "HEAD", 200, "", "23"},
+		{"post", "POST", 404, "Route Not Found\n", "16"},
+	}
+	for _, tt := range tests {
+		res, body := do(t, tt.method, base+"/assets/app.js", nil, "")
+		if res.StatusCode != tt.status || body != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, res.StatusCode, body, tt.status, tt.body)
+		}
+		if got := res.Header.Get("Content-Length"); got != tt.length {
+			t.Errorf("%s: Content-Length = %q, want %q", tt.name, got, tt.length)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, path string
+		want         bool
+	}{
+		{"/assets", "/assets", true},
+		{"/assets", "/assets/js/app.js", true},
+		{"/assets/", "/assets/app.js", true},
+		{"/assets", "/assetsfoo", false},
+		{"/", "/anything", true},
+		{"", "/anything", true},
+	}
+	for _, tt := range tests {
+		if got := matchPrefix(tt.prefix, tt.path); got != tt.want {
+			t.Errorf("matchPrefix(%q, %q) = %v, want %v", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
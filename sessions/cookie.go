@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"errors"
+	"squirrel/cookies"
+	"squirrel/core"
+	"time"
+)
+
+// CookieStore keeps the whole session in the session cookie
+// the cookie is signed (or encrypted), so clients can't change it
+// nothing is stored on the server, so sessions can't be revoked before they expire
+// and have to stay small (browsers limit cookies to about 4KB)
+type CookieStore struct {
+	// rotate keys with store.Codec.Keys.Rotate(newKey)
+	Codec *cookies.Codec
+}
+
+// name the session values are bound to by the codec
+const cookieStoreName = "session"
+
+// ErrCookieTooLarge is returned when the encoded session doesn't fit into a cookie
+var ErrCookieTooLarge = errors.New("sessions: session too large for a cookie")
+
+// NewCookieStore
+// creates a store signing the sessions with the first key
+// the other keys are only used to verify, so old keys can be rotated out:
+//
+//	sessions.NewCookieStore(newKey, oldKey)
+func NewCookieStore(keys ...[]byte) *CookieStore {
+	return &CookieStore{Codec: cookies.NewSignedCodec(keys...)}
+}
+
+// NewEncryptedCookieStore
+// creates a store encrypting the sessions, clients can't even read them
+func NewEncryptedCookieStore(keys ...[]byte) *CookieStore {
+	return &CookieStore{Codec: cookies.NewEncryptedCodec(keys...)}
+}
+
+func (c *CookieStore) Load(token string) (*core.Session, error) {
+	value, err := c.Codec.Decode(cookieStoreName, token)
+	if err != nil {
+		// tampered with, expired or signed with a retired key
+		return nil, nil
+	}
+
+	return decode([]byte(value))
+}
+
+func (c *CookieStore) Save(s *core.Session, ttl time.Duration) (string, error) {
+	data, err := encode(s, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := c.Codec.Encode(cookieStoreName, string(data))
+	if errors.Is(err, cookies.ErrValueTooLong) {
+		return "", ErrCookieTooLarge
+	}
+	return token, err
+}
+
+// the client deletes the cookie, there is nothing to remove on the server
+func (c *CookieStore) Delete(token string) error {
+	return nil
+}
@@ -0,0 +1,127 @@
+package sessions
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"squirrel/core"
+	"strings"
+	"time"
+)
+
+// FileStore keeps every session in its own file
+// sessions survive restarts and can be shared by processes on the same machine
+type FileStore struct {
+	dir string
+}
+
+const filePrefix = "sess_"
+
+// NewFileStore
+// creates a store writing the sessions to dir, which is created if needed
+// call store.Cleanup() regularly to remove the files of expired sessions
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) Load(token string) (*core.Session, error) {
+	if !validID(token) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(f.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		// expired
+		f.Delete(token)
+	}
+	return s, nil
+}
+
+func (f *FileStore) Save(s *core.Session, ttl time.Duration) (string, error) {
+	if !validID(s.ID) {
+		return "", errors.New("sessions: invalid session id")
+	}
+
+	data, err := encode(s, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	// written to a temporary file first, concurrent loads never see half a session
+	tmp, err := os.CreateTemp(f.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), f.path(s.ID)); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return s.ID, nil
+}
+
+func (f *FileStore) Delete(token string) error {
+	if !validID(token) {
+		return nil
+	}
+	err := os.Remove(f.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// store.Cleanup()
+// removes the files of expired sessions
+//
+//	go func() {
+//		for range time.Tick(10 * time.Minute) {
+//			store.Cleanup()
+//		}
+//	}()
+func (f *FileStore) Cleanup() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) {
+			continue
+		}
+		// Load removes expired sessions
+		if _, err := f.Load(strings.TrimPrefix(name, filePrefix)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FileStore) path(id string) string {
+	return filepath.Join(f.dir, filePrefix+id)
+}
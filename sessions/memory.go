@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"squirrel/core"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the sessions in memory
+// they are lost on restart and not shared between processes
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// sessions are stored encoded, so handlers never share values between requests
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore
+// creates an in memory store
+// expired sessions are removed every sweepInterval (0 means every minute)
+func NewMemoryStore(sweepInterval time.Duration) *MemoryStore {
+	if sweepInterval <= 0 {
+		sweepInterval = time.Minute
+	}
+
+	m := &MemoryStore{
+		sessions: map[string]memoryEntry{},
+		stop:     make(chan struct{}),
+	}
+	go m.sweep(sweepInterval)
+	return m
+}
+
+func (m *MemoryStore) Load(token string) (*core.Session, error) {
+	m.mu.Lock()
+	entry, ok := m.sessions[token]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		m.Delete(token)
+		return nil, nil
+	}
+	return decode(entry.data)
+}
+
+func (m *MemoryStore) Save(s *core.Session, ttl time.Duration) (string, error) {
+	data, err := encode(s, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	entry := memoryEntry{data: data}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	m.sessions[s.ID] = entry
+	m.mu.Unlock()
+	return s.ID, nil
+}
+
+func (m *MemoryStore) Delete(token string) error {
+	m.mu.Lock()
+	delete(m.sessions, token)
+	m.mu.Unlock()
+	return nil
+}
+
+// store.Len()
+// returns the number of stored sessions, including expired ones not swept yet
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+// store.Close()
+// stops sweeping expired sessions
+func (m *MemoryStore) Close() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+func (m *MemoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for token, entry := range m.sessions {
+				if !entry.expires.IsZero() && now.After(entry.expires) {
+					delete(m.sessions, token)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Package sessions provides the stores of the session middleware
+
+	server.Use(middlewares.Session(sessions.NewMemoryStore(time.Minute)))
+
+	- MemoryStore: sessions live in the memory of the process, expired ones are swept regularly
+	- FileStore:   one file per session, survives restarts
+	- CookieStore: the whole session is kept in a signed cookie, nothing is stored on the server
+
+any type implementing Store can be used, e.g. for redis or a database
+*/
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"squirrel/core"
+	"time"
+)
+
+// Store loads and saves sessions
+// the token is the value of the session cookie:
+// the session id for server side stores, the encoded session for the cookie store
+type Store interface {
+	// Load returns the session of the token
+	// nil without error if there is none or it expired
+	Load(token string) (*core.Session, error)
+	// Save stores the session for ttl (0 means no expiry)
+	// and returns the token for the session cookie
+	Save(s *core.Session, ttl time.Duration) (string, error)
+	// Delete removes the session of the token
+	Delete(token string) error
+}
+
+// length of session ids: 32 random bytes, base64 encoded
+const idLength = 43
+
+// NewID
+// returns a new random session id
+func NewID() string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("sessions: reading random bytes: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// validID reports whether the token looks like an id from NewID
+// tokens are client input, stores must not use anything else as a key or file name
+func validID(id string) bool {
+	if len(id) != idLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// record is what the stores persist: the session and when it expires
+type record struct {
+	Expires time.Time     `json:"expires,omitempty"`
+	Session *core.Session `json:"session"`
+}
+
+func encode(s *core.Session, ttl time.Duration) ([]byte, error) {
+	rec := record{Session: s}
+	if ttl > 0 {
+		rec.Expires = time.Now().Add(ttl)
+	}
+	return json.Marshal(rec)
+}
+
+// decode returns nil for expired records
+func decode(data []byte) (*core.Session, error) {
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if rec.Session == nil || (!rec.Expires.IsZero() && time.Now().After(rec.Expires)) {
+		return nil, nil
+	}
+	if rec.Session.Values == nil {
+		rec.Session.Values = map[string]any{}
+	}
+	return rec.Session, nil
+}
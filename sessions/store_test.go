@@ -0,0 +1,186 @@
+package sessions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"squirrel/core"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{NewID(), true},
+		{"", false},
+		{"short", false},
+		{"../../../../../../../../../../../etc/passwd", false},
+		{strings.Repeat("a", 40) + "/..", false},
+		{strings.Repeat("a", 42) + ".", false},
+		{strings.Repeat("a", 44), false},
+	}
+	for _, tt := range tests {
+		if got := validID(tt.id); got != tt.want {
+			t.Errorf("validID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	memory := NewMemoryStore(time.Hour)
+	defer memory.Close()
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{"memory", memory},
+		{"file", file},
+		{"cookie", cookie},
+	}
+	for _, st := range stores {
+		s := core.NewSession(NewID())
+		s.Set("user", "ada")
+		s.Set("visits", 3)
+
+		token, err := st.store.Save(s, time.Hour)
+		if err != nil {
+			t.Fatalf("%s: Save: %v", st.name, err)
+		}
+		loaded, err := st.store.Load(token)
+		if err != nil || loaded == nil {
+			t.Fatalf("%s: Load = %v, %v", st.name, loaded, err)
+		}
+		if loaded.ID != s.ID || loaded.Get("user") != "ada" || loaded.Get("visits") != float64(3) {
+			t.Errorf("%s: loaded %+v", st.name, loaded.Values)
+		}
+
+		// expired
+		token, _ = st.store.Save(s, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		if loaded, err := st.store.Load(token); loaded != nil || err != nil {
+			t.Errorf("%s: expired session loaded: %v, %v", st.name, loaded, err)
+		}
+
+		if loaded, err := st.store.Load("unknown"); loaded != nil || err != nil {
+			t.Errorf("%s: unknown token: %v, %v", st.name, loaded, err)
+		}
+	}
+
+	// server side stores forget deleted sessions
+	for _, st := range stores[:2] {
+		s := core.NewSession(NewID())
+		token, _ := st.store.Save(s, 0)
+		st.store.Delete(token)
+		if loaded, _ := st.store.Load(token); loaded != nil {
+			t.Errorf("%s: deleted session loaded", st.name)
+		}
+	}
+}
+
+func TestFileStoreTraversal(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sessions")
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a file outside of the store that looks like a session
+	outside := filepath.Join(root, "sess_x")
+	data, _ := encode(core.NewSession("x"), 0)
+	os.WriteFile(outside, data, 0o600)
+
+	for _, token := range []string{"../sess_x", "..%2fsess_x", "/etc/passwd", "x"} {
+		if s, err := store.Load(token); s != nil || err != nil {
+			t.Errorf("Load(%q) = %v, %v", token, s, err)
+		}
+		store.Delete(token)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the store was removed: %v", err)
+	}
+
+	if _, err := store.Save(core.NewSession("../../evil"), 0); err == nil {
+		t.Error("session with an invalid id saved")
+	}
+}
+
+func TestFileStoreCleanup(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+
+	live := core.NewSession(NewID())
+	store.Save(live, time.Hour)
+	store.Save(core.NewSession(NewID()), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if err := store.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != filePrefix+live.ID {
+		t.Errorf("files after cleanup: %v", entries)
+	}
+}
+
+func TestCookieStoreTampering(t *testing.T) {
+	oldKey := []byte("old key old key old key old key!")
+	newKey := []byte("new key new key new key new key!")
+
+	s := core.NewSession(NewID())
+	s.Set("role", "user")
+	token, err := NewCookieStore(oldKey).Save(s, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, sig, _ := strings.Cut(token, ".")
+
+	// the same session claiming to be admin, signature of the original
+	forged := core.NewSession(s.ID)
+	forged.Set("role", "admin")
+	forgedToken, _ := NewCookieStore([]byte("attacker key attacker key attack")).Save(forged, time.Hour)
+	forgedPayload, _, _ := strings.Cut(forgedToken, ".")
+
+	tests := []struct {
+		name  string
+		store *CookieStore
+		token string
+		valid bool
+	}{
+		{"valid", NewCookieStore(oldKey), token, true},
+		{"rotated key", NewCookieStore(newKey, oldKey), token, true},
+		{"key removed", NewCookieStore(newKey), token, false},
+		{"payload swapped", NewCookieStore(oldKey), forgedPayload + "." + sig, false},
+		{"signature missing", NewCookieStore(oldKey), payload, false},
+		{"signature cut", NewCookieStore(oldKey), payload + "." + sig[:10], false},
+		{"garbage", NewCookieStore(oldKey), "!!!.???", false},
+	}
+	for _, tt := range tests {
+		loaded, err := tt.store.Load(tt.token)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if (loaded != nil) != tt.valid {
+			t.Errorf("%s: loaded = %v, want valid %v", tt.name, loaded != nil, tt.valid)
+		}
+		if loaded != nil && loaded.Get("role") != "user" {
+			t.Errorf("%s: role = %v", tt.name, loaded.Get("role"))
+		}
+	}
+
+	big := core.NewSession(NewID())
+	big.Set("blob", strings.Repeat("x", 4096))
+	if _, err := NewCookieStore(oldKey).Save(big, 0); !errors.Is(err, ErrCookieTooLarge) {
+		t.Errorf("oversized session: err = %v", err)
+	}
+}
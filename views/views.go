@@ -0,0 +1,328 @@
+/*
+Package views renders html templates for Squirrel
+
+	views/
+		layouts/base.html      <html>... {{block "content" .}}{{end}} ...</html>
+		partials/nav.html      <nav>...</nav>
+		users/show.html        {{define "content"}} {{template "partials/nav" .}} ... {{end}}
+
+	- templates are named by their path without extension: "users/show"
+	- every template can use the partials: {{template "partials/nav" .}}
+	- pages are rendered inside the layout, the layout pulls the page in with
+	  {{block "content" .}}{{end}}  (a page without {{define "content"}} is the content itself)
+	- parsed templates are cached, in reload mode they are parsed again when a file changes
+*/
+package views
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"squirrel/core"
+	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+)
+
+// DataFunc returns data merged into the template data of every render
+// e.g. the current user or flash messages of the request
+type DataFunc func(req *core.Request) map[string]any
+
+// Engine loads, caches and renders the templates
+type Engine struct {
+	fsys        fs.FS
+	ext         string
+	layout      string
+	partialsDir string
+	funcs       template.FuncMap
+	dataFuncs   []DataFunc
+	reload      bool
+
+	mu       sync.RWMutex
+	cache    map[string]*view  // "page\x00layout" => parsed set
+	partials map[string]string // name => source, loaded once per cache generation
+	loadedAt time.Time
+}
+
+// parsed template set and the template to execute
+type view struct {
+	t     *template.Template
+	entry string
+}
+
+// views.New("templates")
+// creates an engine for the templates in the directory
+func New(dir string) *Engine {
+	return NewFS(os.DirFS(dir))
+}
+
+// views.NewFS(fsys)
+// creates an engine for the templates of any fs.FS, like embed.FS
+func NewFS(fsys fs.FS) *Engine {
+	return &Engine{
+		fsys:        fsys,
+		ext:         ".html",
+		partialsDir: "partials",
+		funcs:       template.FuncMap{},
+		cache:       map[string]*view{},
+	}
+}
+
+// engine.Extension(".tmpl")
+// sets the extension of the template files, ".html" by default
+func (e *Engine) Extension(ext string) *Engine {
+	e.ext = ext
+	return e
+}
+
+// engine.Layout("layouts/base")
+// sets the default layout every page is rendered in
+func (e *Engine) Layout(name string) *Engine {
+	e.layout = e.trimExt(name)
+	return e
+}
+
+// engine.Partials("components")
+// sets the directory of the partials, "partials" by default
+func (e *Engine) Partials(dir string) *Engine {
+	e.partialsDir = strings.Trim(dir, "/")
+	return e
+}
+
+// engine.AddFunc("upper", strings.ToUpper)
+// registers a function usable in all templates
+// must be called before the first render
+func (e *Engine) AddFunc(name string, fn any) *Engine {
+	e.funcs[name] = fn
+	return e
+}
+
+// engine.Funcs(template.FuncMap{...})
+// registers several template functions at once
+func (e *Engine) Funcs(funcs template.FuncMap) *Engine {
+	for name, fn := range funcs {
+		e.funcs[name] = fn
+	}
+	return e
+}
+
+// engine.AddData(fn)
+// registers a function providing per-request data for every render
+//
+//	engine.AddData(func(req *core.Request) map[string]any {
+//		return map[string]any{"User": req.Get("user")}
+//	})
+func (e *Engine) AddData(fn DataFunc) *Engine {
+	e.dataFuncs = append(e.dataFuncs, fn)
+	return e
+}
+
+// engine.Reload(true)
+// development mode: templates are parsed again as soon as a file changes
+// in production leave it off, the parsed templates are cached
+func (e *Engine) Reload(enabled bool) *Engine {
+	e.reload = enabled
+	return e
+}
+
+// engine.Render(w, req, name, data, layout...)
+// renders the page in its layout and writes it to w
+// implements core.Renderer, handlers use res.Render instead
+func (e *Engine) Render(w io.Writer, req *core.Request, name string, data any, layout ...string) error {
+	layoutName := e.layout
+	if len(layout) > 0 {
+		layoutName = e.trimExt(layout[0])
+	}
+
+	v, err := e.lookup(e.trimExt(name), layoutName)
+	if err != nil {
+		return err
+	}
+	return v.t.ExecuteTemplate(w, v.entry, e.mergeData(req, data))
+}
+
+// mergeData
+// per-request data comes first (request locals, then data funcs)
+// and is overridden by the data given to Render
+// data that is not a map is available as .Data
+func (e *Engine) mergeData(req *core.Request, data any) any {
+	merged := map[string]any{}
+
+	if req != nil {
+		for key, value := range req.Locals() {
+			merged[key] = value
+		}
+		for _, fn := range e.dataFuncs {
+			for key, value := range fn(req) {
+				merged[key] = value
+			}
+		}
+	}
+
+	switch d := data.(type) {
+	case nil:
+	case map[string]any:
+		for key, value := range d {
+			merged[key] = value
+		}
+	default:
+		merged["Data"] = data
+	}
+	return merged
+}
+
+// returns the parsed template set for the page and layout
+// from the cache, or parses it
+func (e *Engine) lookup(page, layout string) (*view, error) {
+	if e.reload {
+		e.invalidateIfChanged()
+	}
+
+	key := page + "\x00" + layout
+
+	e.mu.RLock()
+	v, ok := e.cache[key]
+	e.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	v, err := e.parse(page, layout)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.cache[key] = v
+	e.mu.Unlock()
+	return v, nil
+}
+
+// parse
+// builds the template set: partials + layout + page
+func (e *Engine) parse(page, layout string) (*view, error) {
+	partials, err := e.loadPartials()
+	if err != nil {
+		return nil, err
+	}
+
+	root := template.New("").Funcs(e.funcs)
+	for name, src := range partials {
+		if _, err := root.New(name).Parse(src); err != nil {
+			return nil, fmt.Errorf("views: parsing %s: %w", name, err)
+		}
+	}
+
+	if layout != "" {
+		if err := e.parseFile(root, layout); err != nil {
+			return nil, err
+		}
+	}
+
+	// content block before parsing the page, to find out if the page defines it
+	var before *parse.Tree
+	if t := root.Lookup("content"); t != nil {
+		before = t.Tree
+	}
+
+	if err := e.parseFile(root, page); err != nil {
+		return nil, err
+	}
+
+	content := root.Lookup("content")
+	definesContent := content != nil && content.Tree != before
+
+	if layout == "" {
+		// without layout the page itself is rendered
+		// or its content block if that's all it has
+		if definesContent && strings.TrimSpace(root.Lookup(page).Tree.Root.String()) == "" {
+			return &view{t: root, entry: "content"}, nil
+		}
+		return &view{t: root, entry: page}, nil
+	}
+
+	// a page without {{define "content"}} is the content itself
+	if !definesContent {
+		if _, err := root.AddParseTree("content", root.Lookup(page).Tree); err != nil {
+			return nil, err
+		}
+	}
+	return &view{t: root, entry: layout}, nil
+}
+
+func (e *Engine) parseFile(root *template.Template, name string) error {
+	src, err := fs.ReadFile(e.fsys, name+e.ext)
+	if err != nil {
+		return fmt.Errorf("views: template %q: %w", name, err)
+	}
+	if _, err := root.New(name).Parse(string(src)); err != nil {
+		return fmt.Errorf("views: parsing %s: %w", name, err)
+	}
+	return nil
+}
+
+// reads all the partials, once per cache generation
+func (e *Engine) loadPartials() (map[string]string, error) {
+	e.mu.RLock()
+	partials := e.partials
+	e.mu.RUnlock()
+	if partials != nil {
+		return partials, nil
+	}
+
+	partials = map[string]string{}
+	err := fs.WalkDir(e.fsys, e.partialsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != e.ext {
+			return nil
+		}
+		src, err := fs.ReadFile(e.fsys, p)
+		if err != nil {
+			return err
+		}
+		partials[e.trimExt(p)] = string(src)
+		return nil
+	})
+	// no partials directory is fine
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.partials = partials
+	e.mu.Unlock()
+	return partials, nil
+}
+
+// drops the cache if any template file is newer than the cached templates
+func (e *Engine) invalidateIfChanged() {
+	var latest time.Time
+	fs.WalkDir(e.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != e.ext {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if latest.After(e.loadedAt) || e.loadedAt.IsZero() {
+		e.cache = map[string]*view{}
+		e.partials = nil
+		e.loadedAt = time.Now()
+	}
+}
+
+func (e *Engine) trimExt(name string) string {
+	return strings.TrimPrefix(strings.TrimSuffix(name, e.ext), "/")
+}
@@ -0,0 +1,122 @@
+package views
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"squirrel/core"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testTemplates() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html":  {Data: []byte(`<main>{{template "partials/nav" .}}{{block "content" .}}default{{end}}</main>`)},
+		"layouts/plain.html": {Data: []byte(`[{{block "content" .}}{{end}}]`)},
+		"partials/nav.html":  {Data: []byte(`<nav>{{.User}}</nav>`)},
+		"users/show.html":    {Data: []byte(`{{define "content"}}<h1>{{.Name | upper}}</h1>{{end}}`)},
+		"about.html":         {Data: []byte(`<p>{{.Data}}</p>`)},
+		"escape.html":        {Data: []byte(`<p>{{.Name}}</p>`)},
+	}
+}
+
+func render(t *testing.T, e *Engine, req *core.Request, name string, data any, layout ...string) string {
+	t.Helper()
+	var b strings.Builder
+	if err := e.Render(&b, req, name, data, layout...); err != nil {
+		t.Fatalf("Render(%q): %v", name, err)
+	}
+	return b.String()
+}
+
+func TestRender(t *testing.T) {
+	e := NewFS(testTemplates()).Layout("layouts/base").AddFunc("upper", strings.ToUpper)
+
+	req := &core.Request{}
+	req.Set("User", "ada")
+
+	tests := []struct {
+		name   string
+		page   string
+		data   any
+		layout []string
+		want   string
+	}{
+		{"page with content block", "users/show", map[string]any{"Name": "squirrel"}, nil, "<main><nav>ada</nav><h1>SQUIRREL</h1></main>"},
+		{"page is the content", "about", "hello", nil, "<main><nav>ada</nav><p>hello</p></main>"},
+		{"extension is optional", "users/show.html", map[string]any{"Name": "x"}, nil, "<main><nav>ada</nav><h1>X</h1></main>"},
+		{"other layout", "users/show", map[string]any{"Name": "x"}, []string{"layouts/plain"}, "[<h1>X</h1>]"},
+		{"without layout", "users/show", map[string]any{"Name": "x"}, []string{""}, "<h1>X</h1>"},
+		{"data overrides locals", "users/show", map[string]any{"Name": "x", "User": "bob"}, nil, "<main><nav>bob</nav><h1>X</h1></main>"},
+		{"escaped", "escape", map[string]any{"Name": "<script>"}, []string{""}, "<p>&lt;script&gt;</p>"},
+	}
+	for _, tt := range tests {
+		if got := render(t, e, req, tt.page, tt.data, tt.layout...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDataFuncs(t *testing.T) {
+	e := NewFS(testTemplates()).Layout("layouts/base").AddFunc("upper", strings.ToUpper)
+	e.AddData(func(req *core.Request) map[string]any {
+		return map[string]any{"User": "from data func"}
+	})
+
+	req := &core.Request{}
+	req.Set("User", "from locals")
+	if got := render(t, e, req, "about", nil); got != "<main><nav>from data func</nav><p></p></main>" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRenderMissingTemplate(t *testing.T) {
+	e := NewFS(testTemplates())
+	err := e.Render(&strings.Builder{}, nil, "nope", nil)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestRenderCacheAndReload(t *testing.T) {
+	fsys := testTemplates()
+	fsys["about.html"].ModTime = time.Now().Add(-time.Hour)
+
+	cached := NewFS(fsys)
+	reloading := NewFS(fsys).Reload(true)
+	render(t, cached, nil, "about", "v1")
+	render(t, reloading, nil, "about", "v1")
+
+	fsys["about.html"] = &fstest.MapFile{Data: []byte(`<b>{{.Data}}</b>`), ModTime: time.Now().Add(time.Second)}
+
+	if got := render(t, cached, nil, "about", "v2"); got != "<p>v2</p>" {
+		t.Errorf("cached engine: got %q", got)
+	}
+	if got := render(t, reloading, nil, "about", "v2"); got != "<b>v2</b>" {
+		t.Errorf("reloading engine: got %q", got)
+	}
+}
+
+func TestResponseRender(t *testing.T) {
+	e := NewFS(testTemplates()).AddFunc("upper", strings.ToUpper)
+
+	res := core.NewResponse(new(net.Conn))
+	if err := res.Render("about", nil); !errors.Is(err, core.ErrNoRenderer) {
+		t.Errorf("without engine: err = %v", err)
+	}
+
+	res.SetRenderer(e)
+	if err := res.Render("about", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if res.GetHeader("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", res.GetHeader("Content-Type"))
+	}
+	b, _ := io.ReadAll(res.GetBody())
+	if string(b) != "<p>hi</p>" {
+		t.Errorf("body = %q", b)
+	}
+}